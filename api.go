@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/dateparse"
+	"github.com/striderjg/gator/internal/storage"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	apiTokenPrefix  = "gator_"
+)
+
+// ---------------------------------- TOKENS ------------------------------------
+
+func handlerToken(ctx context.Context, s *state, cmd command, usr database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("token expects a subcommand.  Usage: token create [NAME] | token list | token revoke ID")
+	}
+	switch cmd.args[0] {
+	case "create":
+		name := ""
+		if len(cmd.args) > 1 {
+			name = strings.Join(cmd.args[1:], " ")
+		}
+		token, err := newAPIToken()
+		if err != nil {
+			return err
+		}
+		created, err := s.db.CreateAPIToken(ctx, database.CreateAPITokenParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			Name:      name,
+			TokenHash: hashAPIToken(token),
+			UserID:    usr.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating api token: %w", err)
+		}
+		fmt.Printf("Created token %v for %v.  It won't be shown again:\n", created.ID, usr.Name)
+		fmt.Println(token)
+		return nil
+	case "list":
+		tokens, err := s.db.GetAPITokensForUser(ctx, usr.ID)
+		if err != nil {
+			return fmt.Errorf("error retrieving api tokens: %w", err)
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No api tokens")
+			return nil
+		}
+		for _, token := range tokens {
+			lastUsed := "never"
+			if token.LastUsedAt.Valid {
+				lastUsed = token.LastUsedAt.Time.Format(time.RFC1123)
+			}
+			fmt.Printf("  *  %v %v (created %v, last used %v)\n", token.ID, token.Name, token.CreatedAt.Format(time.RFC1123), lastUsed)
+		}
+		return nil
+	case "revoke":
+		if len(cmd.args) < 2 {
+			return errors.New("usage: token revoke ID")
+		}
+		id, err := uuid.Parse(cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("invalid token id: %w", err)
+		}
+		n, err := s.db.DeleteAPIToken(ctx, database.DeleteAPITokenParams{ID: id, UserID: usr.ID})
+		if err != nil {
+			return fmt.Errorf("error revoking api token: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no token %v for %v", id, usr.Name)
+		}
+		fmt.Printf("Revoked token %v\n", id)
+		return nil
+	default:
+		return fmt.Errorf("unknown token subcommand: %v", cmd.args[0])
+	}
+}
+
+func newAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating api token: %w", err)
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// Only a hash of the token is stored so a leaked database doesn't leak working tokens.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ---------------------------------- SERVER ------------------------------------
+
+func handlerServe(ctx context.Context, s *state, cmd command) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	if _, err := parseFlags(fs, cmd.args); err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newAPIMux(s),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return listenAndServe(ctx, srv)
+}
+
+// listenAndServe runs srv until ctx is cancelled, then gives open requests a few seconds to finish.
+func listenAndServe(ctx context.Context, srv *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("Listening on %v\n", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("error serving: %w", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down server: %w", err)
+	}
+	fmt.Println("server stopped")
+	return nil
+}
+
+func newAPIMux(s *state) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/healthz", func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /api/v1/me", apiAuth(s, apiGetMe))
+	mux.HandleFunc("GET /api/v1/users", apiAuth(s, apiGetUsers))
+	mux.HandleFunc("GET /api/v1/feeds", apiAuth(s, apiGetFeeds))
+	mux.HandleFunc("POST /api/v1/feeds", apiAuth(s, apiCreateFeed))
+	mux.HandleFunc("GET /api/v1/follows", apiAuth(s, apiGetFollows))
+	mux.HandleFunc("POST /api/v1/follows", apiAuth(s, apiCreateFollow))
+	mux.HandleFunc("DELETE /api/v1/follows/{feedID}", apiAuth(s, apiDeleteFollow))
+	mux.HandleFunc("GET /api/v1/posts", apiAuth(s, apiGetPosts))
+	return mux
+}
+
+// apiAuth is middlewareLoggedIn for the api.  The user comes from an "Authorization: Bearer TOKEN" header.
+func apiAuth(s *state, handler func(s *state, w http.ResponseWriter, r *http.Request, usr database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondWithError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		usr, err := s.db.GetUserByAPIToken(r.Context(), database.GetUserByAPITokenParams{
+			TokenHash:  hashAPIToken(strings.TrimSpace(token)),
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error checking token")
+			return
+		}
+		handler(s, w, r, usr)
+	}
+}
+
+type apiUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeed struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Owner string `json:"owner"`
+}
+
+type apiFollow struct {
+	FeedID      uuid.UUID `json:"feed_id"`
+	FeedName    string    `json:"feed_name"`
+	FeedURL     string    `json:"feed_url"`
+	Category    string    `json:"category"`
+	UnreadCount int64     `json:"unread_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type apiPost struct {
+	ID          uuid.UUID  `json:"id"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	FeedName    string     `json:"feed_name"`
+	FeedURL     string     `json:"feed_url"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+	Author      string     `json:"author"`
+	Categories  []string   `json:"categories"`
+	GUID        string     `json:"guid"`
+	Read        bool       `json:"read"`
+	PublishedAt *time.Time `json:"published_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type apiPostPage struct {
+	Posts      []apiPost `json:"posts"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	NextOffset *int      `json:"next_offset"`
+}
+
+// apiFeedClient fetches the feeds added through the api, when they're added and every time agg fetches them.
+// It only connects to public addresses.
+var apiFeedClient = newRestrictedClient(func(addr netip.AddrPort) bool {
+	return isPublicAddr(addr.Addr())
+})
+
+// newRestrictedClient only connects to addresses allowed accepts.  It's checked on the address actually dialed
+// so redirects and DNS names that lead somewhere else are caught too.
+func newRestrictedClient(allowed func(netip.AddrPort) bool) *http.Client {
+	return &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			// A proxy would be the address checked, not the feed
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: 30 * time.Second,
+				Control: func(network, address string, c syscall.RawConn) error {
+					addr, err := netip.ParseAddrPort(address)
+					if err != nil {
+						return err
+					}
+					if !allowed(addr) {
+						return fmt.Errorf("refusing to fetch from non-public address %v", addr.Addr().Unmap())
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Ranges netip counts as global unicast that aren't reachable on the internet.  0.0.0.0/8 reaches this host on Linux.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// isPublicAddr rejects loopback, private, link-local (including cloud metadata at 169.254.169.254),
+// multicast and unspecified addresses.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func apiGetMe(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	respondWithJSON(w, http.StatusOK, apiUser{ID: usr.ID, Name: usr.Name, CreatedAt: usr.CreatedAt})
+}
+
+func apiGetUsers(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	names, err := s.db.GetUsers(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error retrieving users")
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	respondWithJSON(w, http.StatusOK, map[string][]string{"users": names})
+}
+
+func apiGetFeeds(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	feeds, err := s.db.GetFeeds(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error retrieving feeds")
+		return
+	}
+	out := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		out = append(out, apiFeed{Name: feed.Name, URL: feed.Url, Owner: feed.Username})
+	}
+	respondWithJSON(w, http.StatusOK, map[string][]apiFeed{"feeds": out})
+}
+
+func apiCreateFeed(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	var params struct {
+		Name     string `json:"name"`
+		URL      string `json:"url"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error decoding request body")
+		return
+	}
+	if params.Name == "" || params.URL == "" {
+		respondWithError(w, http.StatusBadRequest, "name and url are required")
+		return
+	}
+
+	if scheme, _, found := strings.Cut(params.URL, "://"); found && !strings.EqualFold(scheme, "http") && !strings.EqualFold(scheme, "https") {
+		respondWithError(w, http.StatusBadRequest, "url must be http or https")
+		return
+	}
+
+	// Anyone with a token can make the server fetch a url, keep them off localhost and the private network,
+	// now and on every later fetch
+	feed, err := addFeed(withPublicOnly(r.Context()), s, usr, params.Name, params.URL, params.Category)
+	if err != nil {
+		var multiple *multipleFeedsError
+		if errors.As(err, &multiple) {
+			respondWithJSON(w, http.StatusConflict, map[string]any{
+				"error":      "the page links to more than one feed, pick one",
+				"candidates": multiple.candidates,
+			})
+			return
+		}
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, apiFeed{Name: feed.Name, URL: feed.Url, Owner: usr.Name})
+}
+
+func apiGetFollows(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	follows, err := s.db.GetFeedFollowsForUser(r.Context(), usr.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error retrieving follows")
+		return
+	}
+	out := make([]apiFollow, 0, len(follows))
+	for _, ff := range follows {
+		out = append(out, apiFollow{
+			FeedID:      ff.FeedID,
+			FeedName:    ff.FeedName,
+			FeedURL:     ff.FeedUrl,
+			Category:    ff.Category,
+			UnreadCount: ff.UnreadCount,
+			CreatedAt:   ff.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, map[string][]apiFollow{"follows": out})
+}
+
+func apiCreateFollow(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	var params struct {
+		URL      string `json:"url"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error decoding request body")
+		return
+	}
+
+	feed, err := s.db.GetFeed(r.Context(), params.URL)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "feed not found, add it with POST /api/v1/feeds")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error retrieving feed")
+		return
+	}
+
+	ff, err := s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    usr.ID,
+		FeedID:    feed.ID,
+		Category:  params.Category,
+	})
+	if storage.IsDuplicate(err) {
+		respondWithError(w, http.StatusConflict, "already following that feed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating follow")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, apiFollow{
+		FeedID:    ff.FeedID,
+		FeedName:  ff.FeedName,
+		FeedURL:   feed.Url,
+		Category:  ff.Category,
+		CreatedAt: ff.CreatedAt,
+	})
+}
+
+func apiDeleteFollow(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+	_, err = s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: usr.ID,
+		FeedID: feedID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "not following that feed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error deleting follow")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiGetPosts takes limit, offset, feed (a feed url), since, until and unread query parameters.
+func apiGetPosts(s *state, w http.ResponseWriter, r *http.Request, usr database.User) {
+	query := r.URL.Query()
+	params := database.ListPostsForUserParams{UserID: usr.ID}
+
+	limit, err := queryInt(query.Get("limit"), defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %v", maxPageSize))
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		respondWithError(w, http.StatusBadRequest, "offset must be 0 or more")
+		return
+	}
+	if unread := query.Get("unread"); unread != "" {
+		params.UnreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "unread must be true or false")
+			return
+		}
+	}
+	// One extra row tells us whether there's another page
+	params.Limit = int32(limit + 1)
+	params.Offset = int32(offset)
+
+	if feedURL := query.Get("feed"); feedURL != "" {
+		feed, err := s.db.GetFeed(r.Context(), feedURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error retrieving feed")
+			return
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	for name, dest := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := dateparse.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid %v: %v", name, err))
+			return
+		}
+		*dest = sql.NullTime{Time: t, Valid: true}
+	}
+
+	posts, err := s.db.ListPostsForUser(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error retrieving posts")
+		return
+	}
+
+	page := apiPostPage{Posts: make([]apiPost, 0, len(posts)), Limit: limit, Offset: offset}
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next := offset + limit
+		page.NextOffset = &next
+	}
+	for _, post := range posts {
+		out := apiPost{
+			ID:          post.ID,
+			FeedID:      post.FeedID,
+			FeedName:    post.FeedName,
+			FeedURL:     post.FeedUrl,
+			Title:       post.Title,
+			URL:         post.Url,
+			Description: post.Description,
+			Author:      post.Author,
+			Categories:  post.Categories,
+			GUID:        post.Guid,
+			Read:        post.Read,
+			UpdatedAt:   post.UpdatedAt,
+		}
+		// Always an array in the JSON, never null
+		if out.Categories == nil {
+			out.Categories = []string{}
+		}
+		if post.PublishedAt.Valid {
+			out.PublishedAt = &post.PublishedAt.Time
+		}
+		page.Posts = append(page.Posts, out)
+	}
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	respondWithJSON(w, code, map[string]string{"error": msg})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		// TODO:  LOG ERROR
+		fmt.Printf("error marshalling json: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiClient makes requests to the api with a bearer token.
+type apiClient struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func (c apiClient) do(method, path, body string, out any) int {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatalf("NewRequest: %v", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%v %v: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil && err != io.EOF {
+			c.t.Fatalf("%v %v: decoding response: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+// createToken runs `token create` and returns the token it printed.
+func (env *testEnv) createToken(name string) string {
+	env.t.Helper()
+	out := env.mustRun(middlewareLoggedIn(handlerToken), "create", name)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestToken(t *testing.T) {
+	env := newTestEnv(t)
+	token := middlewareLoggedIn(handlerToken)
+
+	if out := env.mustRun(token, "list"); out != "No api tokens\n" {
+		t.Errorf("token list printed %q with no tokens", out)
+	}
+	secret := env.createToken("laptop")
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		t.Errorf("token %q doesn't start with %q", secret, apiTokenPrefix)
+	}
+	out := env.mustRun(token, "list")
+	if !strings.Contains(out, " laptop (created ") || !strings.Contains(out, "last used never") || strings.Contains(out, secret) {
+		t.Errorf("token list printed %q", out)
+	}
+	tokens, _ := env.s.db.GetAPITokensForUser(env.ctx, env.usr.ID)
+	if len(tokens) != 1 || tokens[0].TokenHash != hashAPIToken(secret) {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+
+	env.runErr(token, "no token", "revoke", uuid.NewString())
+	env.runErr(token, "invalid token id", "revoke", "42")
+	env.runErr(token, "usage: token revoke ID", "revoke")
+	env.runErr(token, "unknown token subcommand", "rotate")
+	env.runErr(token, "expects a subcommand")
+
+	// Other users can't revoke it
+	env.mustRun(handlerRegister, "bob")
+	env.runErr(token, "no token", "revoke", tokens[0].ID.String())
+	env.mustRun(handlerLogin, "alice")
+	if out := env.mustRun(token, "revoke", tokens[0].ID.String()); out != "Revoked token "+tokens[0].ID.String()+"\n" {
+		t.Errorf("token revoke printed %q", out)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	env := newTestEnv(t)
+	feeds := newFeedServer(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	for i, title := range []string{"First", "Second", "Third"} {
+		env.post(blog, title, "", time.Date(2024, 3, i+1, 0, 0, 0, 0, time.UTC))
+	}
+	srv := httptest.NewServer(newAPIMux(env.s))
+	t.Cleanup(srv.Close)
+	api := apiClient{t: t, url: srv.URL, token: env.createToken("test")}
+	// The feed server is on localhost, which apiFeedClient refuses
+	public := apiFeedClient
+	apiFeedClient = feedClient
+	t.Cleanup(func() { apiFeedClient = public })
+
+	if code := (apiClient{t: t, url: srv.URL}).do("GET", "/api/v1/healthz", "", nil); code != http.StatusOK {
+		t.Errorf("healthz = %v", code)
+	}
+	if code := (apiClient{t: t, url: srv.URL}).do("GET", "/api/v1/me", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("me without a token = %v", code)
+	}
+	if code := (apiClient{t: t, url: srv.URL, token: "gator_wrong"}).do("GET", "/api/v1/me", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("me with a bad token = %v", code)
+	}
+
+	var me apiUser
+	if code := api.do("GET", "/api/v1/me", "", &me); code != http.StatusOK || me.ID != env.usr.ID || me.Name != "alice" {
+		t.Errorf("me = %v %+v", code, me)
+	}
+	var users map[string][]string
+	if code := api.do("GET", "/api/v1/users", "", &users); code != http.StatusOK || len(users["users"]) != 1 {
+		t.Errorf("users = %v %+v", code, users)
+	}
+
+	var created apiFeed
+	if code := api.do("POST", "/api/v1/feeds", `{"name": "Test Blog", "url": "`+feeds.URL+`", "category": "Go"}`, &created); code != http.StatusCreated || created.URL != feeds.URL+"/rss.xml" {
+		t.Errorf("create feed = %v %+v", code, created)
+	}
+	if code := api.do("POST", "/api/v1/feeds", `{"name": "Test Blog"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("create feed without a url = %v", code)
+	}
+	if code := api.do("POST", "/api/v1/feeds", `{"name": "Test Blog", "url": "`+feeds.URL+`/rss.xml"}`, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("create existing feed = %v", code)
+	}
+	var feedList map[string][]apiFeed
+	if code := api.do("GET", "/api/v1/feeds", "", &feedList); code != http.StatusOK || len(feedList["feeds"]) != 2 {
+		t.Errorf("feeds = %v %+v", code, feedList)
+	}
+
+	if code := api.do("DELETE", "/api/v1/follows/"+blog.ID.String(), "", nil); code != http.StatusNoContent {
+		t.Errorf("delete follow = %v", code)
+	}
+	if code := api.do("DELETE", "/api/v1/follows/"+blog.ID.String(), "", nil); code != http.StatusNotFound {
+		t.Errorf("delete missing follow = %v", code)
+	}
+	if code := api.do("DELETE", "/api/v1/follows/42", "", nil); code != http.StatusBadRequest {
+		t.Errorf("delete follow with a bad id = %v", code)
+	}
+	var follow apiFollow
+	if code := api.do("POST", "/api/v1/follows", `{"url": "`+blog.Url+`", "category": "News"}`, &follow); code != http.StatusCreated || follow.FeedID != blog.ID || follow.Category != "News" {
+		t.Errorf("create follow = %v %+v", code, follow)
+	}
+	if code := api.do("POST", "/api/v1/follows", `{"url": "`+blog.Url+`"}`, nil); code != http.StatusConflict {
+		t.Errorf("create duplicate follow = %v", code)
+	}
+	if code := api.do("POST", "/api/v1/follows", `{"url": "https://example.com/other"}`, nil); code != http.StatusNotFound {
+		t.Errorf("follow missing feed = %v", code)
+	}
+	var follows map[string][]apiFollow
+	if code := api.do("GET", "/api/v1/follows", "", &follows); code != http.StatusOK || len(follows["follows"]) != 2 {
+		t.Errorf("follows = %v %+v", code, follows)
+	}
+
+	var page apiPostPage
+	if code := api.do("GET", "/api/v1/posts?feed="+blog.Url+"&limit=2", "", &page); code != http.StatusOK || len(page.Posts) != 2 || page.Posts[0].Title != "Third" || page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Errorf("posts = %v %+v", code, page)
+	}
+	if code := api.do("GET", "/api/v1/posts?feed="+blog.Url+"&limit=2&offset=2", "", &page); code != http.StatusOK || len(page.Posts) != 1 || page.Posts[0].Title != "First" || page.NextOffset != nil {
+		t.Errorf("second page of posts = %v %+v", code, page)
+	}
+	// categories is always an array
+	res, err := http.NewRequest("GET", srv.URL+"/api/v1/posts?feed="+blog.Url+"&limit=1", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	res.Header.Set("Authorization", "Bearer "+api.token)
+	if body := readBody(t, res); !strings.Contains(body, `"categories":[]`) {
+		t.Errorf("posts without categories = %v", body)
+	}
+	for _, query := range []string{"limit=0", "limit=101", "offset=-1", "unread=maybe", "since=someday"} {
+		if code := api.do("GET", "/api/v1/posts?"+query, "", nil); code != http.StatusBadRequest {
+			t.Errorf("posts?%v = %v, want 400", query, code)
+		}
+	}
+	if code := api.do("GET", "/api/v1/posts?feed=https://example.com/other", "", nil); code != http.StatusNotFound {
+		t.Errorf("posts for a missing feed = %v", code)
+	}
+
+	tokens, _ := env.s.db.GetAPITokensForUser(env.ctx, env.usr.ID)
+	if len(tokens) != 1 || !tokens[0].LastUsedAt.Valid {
+		t.Errorf("token last used isn't recorded: %+v", tokens)
+	}
+}
+
+// A feed added through the api stays public only, agg refuses it once it redirects inside the network.
+func TestAPIFeedRedirectAtAgg(t *testing.T) {
+	env := newTestEnv(t)
+	var internalHits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits.Add(1)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	t.Cleanup(internal.Close)
+	var redirect atomic.Bool
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirect.Load() {
+			http.Redirect(w, r, internal.URL+"/rss.xml", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	t.Cleanup(public.Close)
+
+	// public stands in for a host on the internet, only its port counts as public
+	publicPort := netip.MustParseAddrPort(public.Listener.Addr().String()).Port()
+	saved := apiFeedClient
+	apiFeedClient = newRestrictedClient(func(addr netip.AddrPort) bool { return addr.Port() == publicPort })
+	t.Cleanup(func() { apiFeedClient = saved })
+
+	srv := httptest.NewServer(newAPIMux(env.s))
+	t.Cleanup(srv.Close)
+	api := apiClient{t: t, url: srv.URL, token: env.createToken("test")}
+	if code := api.do("POST", "/api/v1/feeds", `{"name": "Public", "url": "`+public.URL+`/rss.xml"}`, nil); code != http.StatusCreated {
+		t.Fatalf("create feed = %v", code)
+	}
+	feed, err := env.s.db.GetFeed(env.ctx, public.URL+"/rss.xml")
+	if err != nil || !feed.PublicOnly {
+		t.Fatalf("feed added through the api = %+v, %v, want public only", feed, err)
+	}
+	// Feeds added on the command line can be anywhere
+	env.mustRun(middlewareLoggedIn(handlerAddFeed), "Local", internal.URL+"/rss.xml")
+	local, err := env.s.db.GetFeed(env.ctx, internal.URL+"/rss.xml")
+	if err != nil || local.PublicOnly {
+		t.Fatalf("feed added on the command line = %+v, %v", local, err)
+	}
+
+	redirect.Store(true)
+	hits := internalHits.Load()
+	captureStdout(t, func() { _, err = scrapeFeed(env.ctx, env.s, feed) })
+	if err == nil || !strings.Contains(err.Error(), "non-public address 127.0.0.1") {
+		t.Errorf("scrapeFeed after a redirect to localhost = %v", err)
+	}
+	if internalHits.Load() != hits {
+		t.Errorf("agg fetched the internal server")
+	}
+	captureStdout(t, func() { _, err = scrapeFeed(env.ctx, env.s, local) })
+	if err != nil {
+		t.Errorf("scrapeFeed of the command line feed = %v", err)
+	}
+}
+
+func readBody(t *testing.T, req *http.Request) string {
+	t.Helper()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%v %v: %v", req.Method, req.URL, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return string(body)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.215.14":       true,
+		"2606:4700::6810:85e": true,
+		"127.0.0.1":           false,
+		"::1":                 false,
+		"10.1.2.3":            false,
+		"172.16.0.1":          false,
+		"192.168.1.1":         false,
+		"169.254.169.254":     false,
+		"100.64.0.1":          false,
+		"0.0.0.0":             false,
+		"0.1.2.3":             false,
+		"fd00:ec2::254":       false,
+		"fe80::1":             false,
+		"224.0.0.1":           false,
+		"::ffff:127.0.0.1":    false,
+		"::ffff:8.8.8.8":      true,
+	}
+	for addr, want := range cases {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%v) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// Feeds added through the api can't point the server at itself or the network it's on.
+func TestAPICreateFeedPrivateAddress(t *testing.T) {
+	env := newTestEnv(t)
+	feeds := newFeedServer(t)
+	srv := httptest.NewServer(newAPIMux(env.s))
+	t.Cleanup(srv.Close)
+	api := apiClient{t: t, url: srv.URL, token: env.createToken("test")}
+
+	var apiErr map[string]string
+	if code := api.do("POST", "/api/v1/feeds", `{"name": "Local", "url": "`+feeds.URL+`/rss.xml"}`, &apiErr); code != http.StatusUnprocessableEntity ||
+		!strings.Contains(apiErr["error"], "non-public address 127.0.0.1") {
+		t.Errorf("create feed on localhost = %v %v", code, apiErr)
+	}
+	for _, url := range []string{"file:///etc/passwd", "gopher://example.com/", "ftp://example.com/rss"} {
+		if code := api.do("POST", "/api/v1/feeds", `{"name": "Local", "url": "`+url+`"}`, nil); code != http.StatusBadRequest {
+			t.Errorf("create feed at %v = %v, want 400", url, code)
+		}
+	}
+	if feeds, _ := env.s.db.GetFeeds(env.ctx); len(feeds) != 0 {
+		t.Errorf("feeds were created: %+v", feeds)
+	}
+}
+
+func TestServe(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(env.ctx, 100*time.Millisecond)
+	defer cancel()
+	out, err := env.runContext(ctx, handlerServe, "--addr", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if !strings.Contains(out, "Listening on 127.0.0.1:0") || !strings.Contains(out, "server stopped") {
+		t.Errorf("serve printed %q", out)
+	}
+	env.runErr(handlerServe, "flag provided but not defined", "--port", "8080")
+}
@@ -0,0 +1,888 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/storage"
+)
+
+// A backup is gzipped NDJSON: a backupHeader line then one backupRecord per line, parents before the rows
+// that refer to them.  backupVersion goes up whenever a record changes in a way older gators can't read.
+const (
+	backupFormat  = "gator-backup"
+	backupVersion = 2
+)
+
+// postBatch is how many posts are read from the database at a time while backing up.
+const postBatch = 500
+
+type backupHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupRecord is one row, exactly one field is set.
+type backupRecord struct {
+	User   *backupUser   `json:"user,omitempty"`
+	Feed   *backupFeed   `json:"feed,omitempty"`
+	Follow *backupFollow `json:"follow,omitempty"`
+	Post   *backupPost   `json:"post,omitempty"`
+	Read   *backupRead   `json:"read,omitempty"`
+	Star   *backupStar   `json:"star,omitempty"`
+	Pruned *backupPruned `json:"pruned,omitempty"`
+	Token  *backupToken  `json:"token,omitempty"`
+}
+
+type backupUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+// backupFeed leaves out the lease, it belongs to the agg process that took it.
+type backupFeed struct {
+	ID                      uuid.UUID  `json:"id"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+	Name                    string     `json:"name"`
+	Url                     string     `json:"url"`
+	UserID                  uuid.UUID  `json:"user_id"`
+	LastFetchedAt           *time.Time `json:"last_fetched_at"`
+	Etag                    string     `json:"etag"`
+	LastModified            string     `json:"last_modified"`
+	LastBodySize            int64      `json:"last_body_size"`
+	LastError               string     `json:"last_error"`
+	LastErrorAt             *time.Time `json:"last_error_at"`
+	ConsecutiveFailures     int32      `json:"consecutive_failures"`
+	NextFetchAt             *time.Time `json:"next_fetch_at"`
+	Disabled                bool       `json:"disabled"`
+	HintedIntervalSeconds   int32      `json:"hinted_interval_seconds"`
+	SkipHours               []int32    `json:"skip_hours"`
+	SkipDays                []string   `json:"skip_days"`
+	IntervalOverrideSeconds *int32     `json:"interval_override_seconds"`
+	RetentionMaxAgeSeconds  *int32     `json:"retention_max_age_seconds"`
+	RetentionMaxPosts       *int32     `json:"retention_max_posts"`
+	RetentionKeepUnread     *bool      `json:"retention_keep_unread"`
+	PublicOnly              bool       `json:"public_only"`
+}
+
+type backupFollow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	Category  string    `json:"category"`
+}
+
+type backupPost struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Title       string     `json:"title"`
+	Url         string     `json:"url"`
+	Description string     `json:"description"`
+	PublishedAt *time.Time `json:"published_at"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	Author      string     `json:"author"`
+	Categories  []string   `json:"categories"`
+	Guid        string     `json:"guid"`
+}
+
+type backupRead struct {
+	UserID uuid.UUID `json:"user_id"`
+	PostID uuid.UUID `json:"post_id"`
+	ReadAt time.Time `json:"read_at"`
+}
+
+type backupStar struct {
+	UserID    uuid.UUID `json:"user_id"`
+	PostID    uuid.UUID `json:"post_id"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// backupPruned is an item whose post was pruned, so fetching the feed doesn't bring it back.  New in
+// version 2.
+type backupPruned struct {
+	FeedID uuid.UUID `json:"feed_id"`
+	Guid   string    `json:"guid"`
+}
+
+// backupToken carries the hash so tokens keep working after a restore, the token itself is never stored.
+type backupToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"token_hash"`
+	UserID     uuid.UUID  `json:"user_id"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// backupCounts is how many rows of each kind a backup holds.
+type backupCounts struct {
+	users, feeds, follows, posts, reads, stars, pruned, tokens int
+}
+
+func (c backupCounts) String() string {
+	return fmt.Sprintf("%v users, %v feeds, %v follows, %v posts, %v reads, %v stars, %v pruned posts and %v api tokens",
+		c.users, c.feeds, c.follows, c.posts, c.reads, c.stars, c.pruned, c.tokens)
+}
+
+func handlerBackup(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return errors.New("backup expects a file name.  Usage: backup FILE")
+	}
+	path := cmd.args[0]
+
+	// Written next to FILE and renamed over it at the end so a failed backup never replaces a good one.
+	// CreateTemp makes it readable only by its owner, the archive holds api token hashes.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// One snapshot for every table, so rows written while the backup runs can't leave it with a read or
+	// star whose post isn't in it
+	zw := gzip.NewWriter(tmp)
+	var counts backupCounts
+	err = s.db.InTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(db storage.Store) error {
+		counts, err = writeBackup(ctx, db, zw)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error writing backup file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing backup file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing backup file: %w", err)
+	}
+	fmt.Printf("Backed up %v to %v\n", counts, path)
+	return nil
+}
+
+// writeBackup writes the header and every row as NDJSON to w.
+func writeBackup(ctx context.Context, db storage.Store, w io.Writer) (backupCounts, error) {
+	var counts backupCounts
+	enc := json.NewEncoder(w)
+	write := func(v any) error {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("error writing backup file: %w", err)
+		}
+		return nil
+	}
+	if err := write(backupHeader{Format: backupFormat, Version: backupVersion, CreatedAt: time.Now().UTC()}); err != nil {
+		return counts, err
+	}
+
+	users, err := db.ListUsers(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving users: %w", err)
+	}
+	for _, usr := range users {
+		if err := write(backupRecord{User: &backupUser{ID: usr.ID, CreatedAt: usr.CreatedAt, UpdatedAt: usr.UpdatedAt, Name: usr.Name}}); err != nil {
+			return counts, err
+		}
+		counts.users++
+	}
+
+	feeds, err := db.ListFeeds(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving feeds: %w", err)
+	}
+	for _, feed := range feeds {
+		if err := write(backupRecord{Feed: &backupFeed{
+			ID:                      feed.ID,
+			CreatedAt:               feed.CreatedAt,
+			UpdatedAt:               feed.UpdatedAt,
+			Name:                    feed.Name,
+			Url:                     feed.Url,
+			UserID:                  feed.UserID,
+			LastFetchedAt:           nullTimePtr(feed.LastFetchedAt),
+			Etag:                    feed.Etag,
+			LastModified:            feed.LastModified,
+			LastBodySize:            feed.LastBodySize,
+			LastError:               feed.LastError,
+			LastErrorAt:             nullTimePtr(feed.LastErrorAt),
+			ConsecutiveFailures:     feed.ConsecutiveFailures,
+			NextFetchAt:             nullTimePtr(feed.NextFetchAt),
+			Disabled:                feed.Disabled,
+			HintedIntervalSeconds:   feed.HintedIntervalSeconds,
+			SkipHours:               feed.SkipHours,
+			SkipDays:                feed.SkipDays,
+			IntervalOverrideSeconds: nullInt32Ptr(feed.IntervalOverrideSeconds),
+			RetentionMaxAgeSeconds:  nullInt32Ptr(feed.RetentionMaxAgeSeconds),
+			RetentionMaxPosts:       nullInt32Ptr(feed.RetentionMaxPosts),
+			RetentionKeepUnread:     nullBoolPtr(feed.RetentionKeepUnread),
+			PublicOnly:              feed.PublicOnly,
+		}}); err != nil {
+			return counts, err
+		}
+		counts.feeds++
+	}
+
+	follows, err := db.ListFeedFollows(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving follows: %w", err)
+	}
+	for _, ff := range follows {
+		if err := write(backupRecord{Follow: &backupFollow{
+			ID:        ff.ID,
+			CreatedAt: ff.CreatedAt,
+			UpdatedAt: ff.UpdatedAt,
+			UserID:    ff.UserID,
+			FeedID:    ff.FeedID,
+			Category:  ff.Category,
+		}}); err != nil {
+			return counts, err
+		}
+		counts.follows++
+	}
+
+	// Posts are the bulk of the database, page through them instead of loading them all
+	var after uuid.UUID
+	for {
+		posts, err := db.ListPosts(ctx, database.ListPostsParams{After: after, Limit: postBatch})
+		if err != nil {
+			return counts, fmt.Errorf("error retrieving posts: %w", err)
+		}
+		for _, post := range posts {
+			if err := write(backupRecord{Post: &backupPost{
+				ID:          post.ID,
+				CreatedAt:   post.CreatedAt,
+				UpdatedAt:   post.UpdatedAt,
+				Title:       post.Title,
+				Url:         post.Url,
+				Description: post.Description,
+				PublishedAt: nullTimePtr(post.PublishedAt),
+				FeedID:      post.FeedID,
+				Author:      post.Author,
+				Categories:  post.Categories,
+				Guid:        post.Guid,
+			}}); err != nil {
+				return counts, err
+			}
+			counts.posts++
+		}
+		if len(posts) < postBatch {
+			break
+		}
+		after = posts[len(posts)-1].ID
+	}
+
+	reads, err := db.ListPostReads(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving read posts: %w", err)
+	}
+	for _, read := range reads {
+		if err := write(backupRecord{Read: &backupRead{UserID: read.UserID, PostID: read.PostID, ReadAt: read.ReadAt}}); err != nil {
+			return counts, err
+		}
+		counts.reads++
+	}
+
+	stars, err := db.ListPostStars(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving starred posts: %w", err)
+	}
+	for _, star := range stars {
+		if err := write(backupRecord{Star: &backupStar{
+			UserID:    star.UserID,
+			PostID:    star.PostID,
+			Note:      star.Note,
+			CreatedAt: star.CreatedAt,
+			UpdatedAt: star.UpdatedAt,
+		}}); err != nil {
+			return counts, err
+		}
+		counts.stars++
+	}
+
+	pruned, err := db.ListPrunedPosts(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving pruned posts: %w", err)
+	}
+	for _, p := range pruned {
+		if err := write(backupRecord{Pruned: &backupPruned{FeedID: p.FeedID, Guid: p.Guid}}); err != nil {
+			return counts, err
+		}
+		counts.pruned++
+	}
+
+	tokens, err := db.ListAPITokens(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error retrieving api tokens: %w", err)
+	}
+	for _, token := range tokens {
+		if err := write(backupRecord{Token: &backupToken{
+			ID:         token.ID,
+			CreatedAt:  token.CreatedAt,
+			Name:       token.Name,
+			TokenHash:  token.TokenHash,
+			UserID:     token.UserID,
+			LastUsedAt: nullTimePtr(token.LastUsedAt),
+		}}); err != nil {
+			return counts, err
+		}
+		counts.tokens++
+	}
+	return counts, nil
+}
+
+// conflictPolicy is what restore does with a row that's already in the database.
+type conflictPolicy string
+
+const (
+	conflictSkip      conflictPolicy = "skip"
+	conflictOverwrite conflictPolicy = "overwrite"
+	conflictMerge     conflictPolicy = "merge"
+)
+
+// replaces reports whether the backup's copy of a row replaces the database's.  newer is whether the
+// backup's copy was updated more recently, rows without an updated time pass false.
+func (p conflictPolicy) replaces(newer bool) bool {
+	return p == conflictOverwrite || (p == conflictMerge && newer)
+}
+
+// restoreCount is what happened to one kind of row.
+type restoreCount struct {
+	added     int
+	replaced  int
+	unchanged int
+	// orphaned rows refer to a row that's in neither the backup nor the database
+	orphaned int
+}
+
+func (c restoreCount) String() string {
+	s := fmt.Sprintf("%v added, %v replaced, %v unchanged", c.added, c.replaced, c.unchanged)
+	if c.orphaned > 0 {
+		s += fmt.Sprintf(", %v orphaned", c.orphaned)
+	}
+	return s
+}
+
+type userPostKey struct {
+	userID uuid.UUID
+	postID uuid.UUID
+}
+
+type feedGUIDKey struct {
+	feedID uuid.UUID
+	guid   string
+}
+
+// restorer loads backup records into a database.  Rows are matched to the database's by their natural
+// key, user name, feed url, post guid and so on, so ids in the backup are mapped to the ids already in use.
+type restorer struct {
+	db     storage.Store
+	policy conflictPolicy
+
+	// Backup ids to database ids
+	userIDs map[uuid.UUID]uuid.UUID
+	feedIDs map[uuid.UUID]uuid.UUID
+	postIDs map[uuid.UUID]uuid.UUID
+	// Every id already in the database, a backup row whose id is taken by a different row gets a new one
+	taken map[uuid.UUID]bool
+
+	users   map[string]database.User
+	feeds   map[string]database.Feed
+	follows map[userPostKey]database.FeedFollow // keyed by user and feed
+	posts   map[feedGUIDKey]database.ListPostsRow
+	reads   map[userPostKey]database.PostRead
+	stars   map[userPostKey]database.PostStar
+	pruned  map[feedGUIDKey]bool
+	tokens  map[string]database.ApiToken
+
+	userCount, feedCount, followCount, postCount, readCount, starCount, prunedCount, tokenCount restoreCount
+}
+
+func newRestorer(ctx context.Context, db storage.Store, policy conflictPolicy) (*restorer, error) {
+	r := &restorer{
+		db:      db,
+		policy:  policy,
+		userIDs: make(map[uuid.UUID]uuid.UUID),
+		feedIDs: make(map[uuid.UUID]uuid.UUID),
+		postIDs: make(map[uuid.UUID]uuid.UUID),
+		taken:   make(map[uuid.UUID]bool),
+		users:   make(map[string]database.User),
+		feeds:   make(map[string]database.Feed),
+		follows: make(map[userPostKey]database.FeedFollow),
+		posts:   make(map[feedGUIDKey]database.ListPostsRow),
+		reads:   make(map[userPostKey]database.PostRead),
+		stars:   make(map[userPostKey]database.PostStar),
+		pruned:  make(map[feedGUIDKey]bool),
+		tokens:  make(map[string]database.ApiToken),
+	}
+
+	users, err := db.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving users: %w", err)
+	}
+	for _, usr := range users {
+		r.users[usr.Name] = usr
+		r.taken[usr.ID] = true
+	}
+	feeds, err := db.ListFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving feeds: %w", err)
+	}
+	for _, feed := range feeds {
+		r.feeds[feed.Url] = feed
+		r.taken[feed.ID] = true
+	}
+	follows, err := db.ListFeedFollows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving follows: %w", err)
+	}
+	for _, ff := range follows {
+		r.follows[userPostKey{ff.UserID, ff.FeedID}] = ff
+		r.taken[ff.ID] = true
+	}
+	var after uuid.UUID
+	for {
+		posts, err := db.ListPosts(ctx, database.ListPostsParams{After: after, Limit: postBatch})
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving posts: %w", err)
+		}
+		for _, post := range posts {
+			r.posts[feedGUIDKey{post.FeedID, post.Guid}] = post
+			r.taken[post.ID] = true
+		}
+		if len(posts) < postBatch {
+			break
+		}
+		after = posts[len(posts)-1].ID
+	}
+	reads, err := db.ListPostReads(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving read posts: %w", err)
+	}
+	for _, read := range reads {
+		r.reads[userPostKey{read.UserID, read.PostID}] = read
+	}
+	stars, err := db.ListPostStars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving starred posts: %w", err)
+	}
+	for _, star := range stars {
+		r.stars[userPostKey{star.UserID, star.PostID}] = star
+	}
+	pruned, err := db.ListPrunedPosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving pruned posts: %w", err)
+	}
+	for _, p := range pruned {
+		r.pruned[feedGUIDKey{p.FeedID, p.Guid}] = true
+	}
+	tokens, err := db.ListAPITokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving api tokens: %w", err)
+	}
+	for _, token := range tokens {
+		r.tokens[token.TokenHash] = token
+		r.taken[token.ID] = true
+	}
+	return r, nil
+}
+
+// newID is the id a backup row is inserted with, its own unless another row has it.
+func (r *restorer) newID(id uuid.UUID) uuid.UUID {
+	if r.taken[id] {
+		id = uuid.New()
+	}
+	r.taken[id] = true
+	return id
+}
+
+func (r *restorer) restore(ctx context.Context, rec backupRecord) error {
+	switch {
+	case rec.User != nil:
+		return r.restoreUser(ctx, rec.User)
+	case rec.Feed != nil:
+		return r.restoreFeed(ctx, rec.Feed)
+	case rec.Follow != nil:
+		return r.restoreFollow(ctx, rec.Follow)
+	case rec.Post != nil:
+		return r.restorePost(ctx, rec.Post)
+	case rec.Read != nil:
+		return r.restoreRead(ctx, rec.Read)
+	case rec.Star != nil:
+		return r.restoreStar(ctx, rec.Star)
+	case rec.Pruned != nil:
+		return r.restorePruned(ctx, rec.Pruned)
+	case rec.Token != nil:
+		return r.restoreToken(ctx, rec.Token)
+	}
+	return errors.New("unknown record")
+}
+
+func (r *restorer) restoreUser(ctx context.Context, b *backupUser) error {
+	arg := database.RestoreUserParams{ID: b.ID, CreatedAt: b.CreatedAt, UpdatedAt: b.UpdatedAt, Name: b.Name}
+	if existing, ok := r.users[b.Name]; ok {
+		r.userIDs[b.ID] = existing.ID
+		if !r.policy.replaces(b.UpdatedAt.After(existing.UpdatedAt)) {
+			r.userCount.unchanged++
+			return nil
+		}
+		arg.ID = existing.ID
+		r.userCount.replaced++
+	} else {
+		arg.ID = r.newID(b.ID)
+		r.userIDs[b.ID] = arg.ID
+		r.userCount.added++
+	}
+	if err := r.db.RestoreUser(ctx, arg); err != nil {
+		return fmt.Errorf("error restoring user %v: %w", b.Name, err)
+	}
+	return nil
+}
+
+func (r *restorer) restoreFeed(ctx context.Context, b *backupFeed) error {
+	userID, ok := r.userIDs[b.UserID]
+	if !ok {
+		r.feedCount.orphaned++
+		return nil
+	}
+	arg := database.RestoreFeedParams{
+		ID:                      b.ID,
+		CreatedAt:               b.CreatedAt,
+		UpdatedAt:               b.UpdatedAt,
+		Name:                    b.Name,
+		Url:                     b.Url,
+		UserID:                  userID,
+		LastFetchedAt:           ptrNullTime(b.LastFetchedAt),
+		Etag:                    b.Etag,
+		LastModified:            b.LastModified,
+		LastBodySize:            b.LastBodySize,
+		LastError:               b.LastError,
+		LastErrorAt:             ptrNullTime(b.LastErrorAt),
+		ConsecutiveFailures:     b.ConsecutiveFailures,
+		NextFetchAt:             ptrNullTime(b.NextFetchAt),
+		Disabled:                b.Disabled,
+		HintedIntervalSeconds:   b.HintedIntervalSeconds,
+		SkipHours:               b.SkipHours,
+		SkipDays:                b.SkipDays,
+		IntervalOverrideSeconds: ptrNullInt32(b.IntervalOverrideSeconds),
+		RetentionMaxAgeSeconds:  ptrNullInt32(b.RetentionMaxAgeSeconds),
+		RetentionMaxPosts:       ptrNullInt32(b.RetentionMaxPosts),
+		RetentionKeepUnread:     ptrNullBool(b.RetentionKeepUnread),
+		PublicOnly:              b.PublicOnly,
+	}
+	if existing, ok := r.feeds[b.Url]; ok {
+		r.feedIDs[b.ID] = existing.ID
+		if !r.policy.replaces(b.UpdatedAt.After(existing.UpdatedAt)) {
+			r.feedCount.unchanged++
+			return nil
+		}
+		arg.ID = existing.ID
+		r.feedCount.replaced++
+	} else {
+		arg.ID = r.newID(b.ID)
+		r.feedIDs[b.ID] = arg.ID
+		r.feedCount.added++
+	}
+	if err := r.db.RestoreFeed(ctx, arg); err != nil {
+		return fmt.Errorf("error restoring feed %v: %w", b.Url, err)
+	}
+	return nil
+}
+
+func (r *restorer) restoreFollow(ctx context.Context, b *backupFollow) error {
+	userID, userOK := r.userIDs[b.UserID]
+	feedID, feedOK := r.feedIDs[b.FeedID]
+	if !userOK || !feedOK {
+		r.followCount.orphaned++
+		return nil
+	}
+	arg := database.RestoreFeedFollowParams{
+		CreatedAt: b.CreatedAt,
+		UpdatedAt: b.UpdatedAt,
+		UserID:    userID,
+		FeedID:    feedID,
+		Category:  b.Category,
+	}
+	if existing, ok := r.follows[userPostKey{userID, feedID}]; ok {
+		if !r.policy.replaces(b.UpdatedAt.After(existing.UpdatedAt)) {
+			r.followCount.unchanged++
+			return nil
+		}
+		arg.ID = existing.ID
+		r.followCount.replaced++
+	} else {
+		arg.ID = r.newID(b.ID)
+		r.followCount.added++
+	}
+	if err := r.db.RestoreFeedFollow(ctx, arg); err != nil {
+		return fmt.Errorf("error restoring follow: %w", err)
+	}
+	return nil
+}
+
+func (r *restorer) restorePost(ctx context.Context, b *backupPost) error {
+	feedID, ok := r.feedIDs[b.FeedID]
+	if !ok {
+		r.postCount.orphaned++
+		return nil
+	}
+	arg := database.RestorePostParams{
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+		Title:       b.Title,
+		Url:         b.Url,
+		Description: b.Description,
+		PublishedAt: ptrNullTime(b.PublishedAt),
+		FeedID:      feedID,
+		Author:      b.Author,
+		Categories:  b.Categories,
+		Guid:        b.Guid,
+	}
+	if existing, ok := r.posts[feedGUIDKey{feedID, b.Guid}]; ok {
+		r.postIDs[b.ID] = existing.ID
+		if !r.policy.replaces(b.UpdatedAt.After(existing.UpdatedAt)) {
+			r.postCount.unchanged++
+			return nil
+		}
+		arg.ID = existing.ID
+		r.postCount.replaced++
+	} else {
+		arg.ID = r.newID(b.ID)
+		r.postIDs[b.ID] = arg.ID
+		r.postCount.added++
+	}
+	if err := r.db.RestorePost(ctx, arg); err != nil {
+		return fmt.Errorf("error restoring post %v: %w", b.Url, err)
+	}
+	return nil
+}
+
+func (r *restorer) restoreRead(ctx context.Context, b *backupRead) error {
+	userID, userOK := r.userIDs[b.UserID]
+	postID, postOK := r.postIDs[b.PostID]
+	if !userOK || !postOK {
+		r.readCount.orphaned++
+		return nil
+	}
+	if _, ok := r.reads[userPostKey{userID, postID}]; ok {
+		if !r.policy.replaces(false) {
+			r.readCount.unchanged++
+			return nil
+		}
+		r.readCount.replaced++
+	} else {
+		r.readCount.added++
+	}
+	if err := r.db.RestorePostRead(ctx, database.RestorePostReadParams{UserID: userID, PostID: postID, ReadAt: b.ReadAt}); err != nil {
+		return fmt.Errorf("error restoring read post: %w", err)
+	}
+	return nil
+}
+
+func (r *restorer) restoreStar(ctx context.Context, b *backupStar) error {
+	userID, userOK := r.userIDs[b.UserID]
+	postID, postOK := r.postIDs[b.PostID]
+	if !userOK || !postOK {
+		r.starCount.orphaned++
+		return nil
+	}
+	if existing, ok := r.stars[userPostKey{userID, postID}]; ok {
+		if !r.policy.replaces(b.UpdatedAt.After(existing.UpdatedAt)) {
+			r.starCount.unchanged++
+			return nil
+		}
+		r.starCount.replaced++
+	} else {
+		r.starCount.added++
+	}
+	if err := r.db.RestorePostStar(ctx, database.RestorePostStarParams{
+		UserID:    userID,
+		PostID:    postID,
+		Note:      b.Note,
+		CreatedAt: b.CreatedAt,
+		UpdatedAt: b.UpdatedAt,
+	}); err != nil {
+		return fmt.Errorf("error restoring starred post: %w", err)
+	}
+	return nil
+}
+
+// restorePruned: a pruned item has nothing to replace, it's either there or added.
+func (r *restorer) restorePruned(ctx context.Context, b *backupPruned) error {
+	feedID, ok := r.feedIDs[b.FeedID]
+	if !ok {
+		r.prunedCount.orphaned++
+		return nil
+	}
+	key := feedGUIDKey{feedID, b.Guid}
+	if r.pruned[key] {
+		r.prunedCount.unchanged++
+		return nil
+	}
+	if err := r.db.RestorePrunedPost(ctx, database.RestorePrunedPostParams{FeedID: feedID, Guid: b.Guid}); err != nil {
+		return fmt.Errorf("error restoring pruned post: %w", err)
+	}
+	r.pruned[key] = true
+	r.prunedCount.added++
+	return nil
+}
+
+func (r *restorer) restoreToken(ctx context.Context, b *backupToken) error {
+	userID, ok := r.userIDs[b.UserID]
+	if !ok {
+		r.tokenCount.orphaned++
+		return nil
+	}
+	arg := database.RestoreAPITokenParams{
+		CreatedAt:  b.CreatedAt,
+		Name:       b.Name,
+		TokenHash:  b.TokenHash,
+		UserID:     userID,
+		LastUsedAt: ptrNullTime(b.LastUsedAt),
+	}
+	if existing, ok := r.tokens[b.TokenHash]; ok {
+		if !r.policy.replaces(false) {
+			r.tokenCount.unchanged++
+			return nil
+		}
+		arg.ID = existing.ID
+		r.tokenCount.replaced++
+	} else {
+		arg.ID = r.newID(b.ID)
+		r.tokenCount.added++
+	}
+	if err := r.db.RestoreAPIToken(ctx, arg); err != nil {
+		return fmt.Errorf("error restoring api token %v: %w", b.Name, err)
+	}
+	return nil
+}
+
+func handlerRestore(ctx context.Context, s *state, cmd command) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	onConflict := fs.String("on-conflict", string(conflictSkip), "skip, overwrite or merge rows already in the database")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("restore expects a file name.  Usage: restore [--on-conflict skip|overwrite|merge] FILE")
+	}
+	policy := conflictPolicy(*onConflict)
+	switch policy {
+	case conflictSkip, conflictOverwrite, conflictMerge:
+	default:
+		return fmt.Errorf("--on-conflict must be skip, overwrite or merge, got %q", *onConflict)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error opening backup file: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%v is not a gator backup: %w", args[0], err)
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	var header backupHeader
+	if err := dec.Decode(&header); err != nil || header.Format != backupFormat {
+		return fmt.Errorf("%v is not a gator backup", args[0])
+	}
+	if header.Version > backupVersion {
+		return fmt.Errorf("%v is a version %v backup, this gator reads up to version %v", args[0], header.Version, backupVersion)
+	}
+
+	// All or nothing, a bad line part way through leaves the database as it was
+	var r *restorer
+	err = s.db.InTx(ctx, nil, func(db storage.Store) error {
+		var err error
+		if r, err = newRestorer(ctx, db, policy); err != nil {
+			return err
+		}
+		for line := 2; ; line++ {
+			var rec backupRecord
+			err := dec.Decode(&rec)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading backup line %v: %w", line, err)
+			}
+			if err := r.restore(ctx, rec); err != nil {
+				return fmt.Errorf("line %v: %w", line, err)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("nothing was restored: %w", err)
+	}
+
+	fmt.Printf("Restored %v, backed up %v\n", args[0], header.CreatedAt.Local().Format(time.RFC1123))
+	fmt.Println("users:  ", r.userCount)
+	fmt.Println("feeds:  ", r.feedCount)
+	fmt.Println("follows:", r.followCount)
+	fmt.Println("posts:  ", r.postCount)
+	fmt.Println("reads:  ", r.readCount)
+	fmt.Println("stars:  ", r.starCount)
+	fmt.Println("pruned: ", r.prunedCount)
+	fmt.Println("tokens: ", r.tokenCount)
+	return nil
+}
+
+func ptrNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func nullInt32Ptr(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int32
+}
+
+func ptrNullInt32(n *int32) sql.NullInt32 {
+	if n == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *n, Valid: true}
+}
+
+func nullBoolPtr(b sql.NullBool) *bool {
+	if !b.Valid {
+		return nil
+	}
+	return &b.Bool
+}
+
+func ptrNullBool(b *bool) sql.NullBool {
+	if b == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *b, Valid: true}
+}
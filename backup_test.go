@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/config"
+	"github.com/striderjg/gator/internal/database"
+)
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	first := env.post(feed, "Generics in Go", "", time.Now().Add(-time.Hour))
+	second := env.post(feed, "Error handling", "", time.Now())
+	env.post(feed, "Go 1.0", "", time.Now().Add(-90*24*time.Hour))
+	env.s.cfg.Retention = &config.Retention{Max_age: "30d"}
+	env.mustRun(handlerPrune)
+	env.s.cfg.Retention = nil
+	if err := env.s.db.MarkPostRead(env.ctx, database.MarkPostReadParams{UserID: env.usr.ID, PostID: first.ID, ReadAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	env.mustRun(middlewareLoggedIn(handlerStar), second.ID.String(), "read", "later")
+	env.mustRun(handlerFeed, "set-retention", feed.Url, "--max-posts", "50")
+	secret := env.createToken("laptop")
+	env.mustRun(handlerRegister, "bob")
+
+	path := filepath.Join(t.TempDir(), "gator.backup")
+	out := env.mustRun(handlerBackup, path)
+	if want := "Backed up 2 users, 1 feeds, 1 follows, 2 posts, 1 reads, 1 stars, 1 pruned posts and 1 api tokens to " + path + "\n"; out != want {
+		t.Errorf("backup printed %q, want %q", out, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("backup file mode = %v, want 0600", info.Mode().Perm())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("backup isn't gzipped: %v", err)
+	}
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, 1<<20)
+	var header backupHeader
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil || header.Format != backupFormat || header.Version != backupVersion {
+		t.Errorf("backup header = %+v", header)
+	}
+	lines := 1
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 11 {
+		t.Errorf("backup has %v lines, want a header and 10 records", lines)
+	}
+
+	// other has its own alice, she's matched by name
+	other := newTestEnv(t)
+	out = other.mustRun(handlerRestore, path)
+	want := "users:   1 added, 0 replaced, 1 unchanged\n" +
+		"feeds:   1 added, 0 replaced, 0 unchanged\n" +
+		"follows: 1 added, 0 replaced, 0 unchanged\n" +
+		"posts:   2 added, 0 replaced, 0 unchanged\n" +
+		"reads:   1 added, 0 replaced, 0 unchanged\n" +
+		"stars:   1 added, 0 replaced, 0 unchanged\n" +
+		"pruned:  1 added, 0 replaced, 0 unchanged\n" +
+		"tokens:  1 added, 0 replaced, 0 unchanged\n"
+	if _, counts, _ := strings.Cut(out, "\n"); counts != want {
+		t.Errorf("restore printed %q, want %q", out, want)
+	}
+	follows, _ := other.s.db.GetFeedFollowsForUser(other.ctx, other.usr.ID)
+	if len(follows) != 1 || follows[0].FeedUrl != feed.Url || follows[0].UnreadCount != 1 {
+		t.Errorf("alice's follows after restore = %+v", follows)
+	}
+	starred, _ := other.s.db.GetStarredPostsForUser(other.ctx, other.usr.ID)
+	if len(starred) != 1 || starred[0].ID != second.ID || starred[0].Note != "read later" {
+		t.Errorf("alice's stars after restore = %+v", starred)
+	}
+	if usr, err := other.s.db.GetUserByAPIToken(other.ctx, database.GetUserByAPITokenParams{TokenHash: hashAPIToken(secret)}); err != nil || usr.ID != other.usr.ID {
+		t.Errorf("token after restore belongs to %+v, %v, want alice", usr, err)
+	}
+	if _, err := other.s.db.GetUser(other.ctx, "bob"); err != nil {
+		t.Errorf("bob wasn't restored: %v", err)
+	}
+	got, _ := other.s.db.GetFeed(other.ctx, feed.Url)
+	if got.RetentionMaxPosts.Int32 != 50 {
+		t.Errorf("feed retention after restore = %v", got.RetentionMaxPosts)
+	}
+	// The pruned post doesn't come back when other fetches the feed
+	if _, err := other.s.db.CreatePost(other.ctx, database.CreatePostParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Title:      "Go 1.0",
+		FeedID:     got.ID,
+		Categories: []string{},
+		Guid:       "Go 1.0",
+	}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CreatePost of the pruned item after restore: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Everything is there now
+	out = other.mustRun(handlerRestore, "--on-conflict", "skip", path)
+	if _, counts, _ := strings.Cut(out, "\n"); counts != "users:   0 added, 0 replaced, 2 unchanged\n"+
+		"feeds:   0 added, 0 replaced, 1 unchanged\n"+
+		"follows: 0 added, 0 replaced, 1 unchanged\n"+
+		"posts:   0 added, 0 replaced, 2 unchanged\n"+
+		"reads:   0 added, 0 replaced, 1 unchanged\n"+
+		"stars:   0 added, 0 replaced, 1 unchanged\n"+
+		"pruned:  0 added, 0 replaced, 1 unchanged\n"+
+		"tokens:  0 added, 0 replaced, 1 unchanged\n" {
+		t.Errorf("restoring again printed %q", out)
+	}
+
+	// merge keeps whichever copy changed last, overwrite takes the backup's
+	other.mustRun(handlerFeed, "set-retention", feed.Url, "--max-posts", "10")
+	other.mustRun(middlewareLoggedIn(handlerStar), second.ID.String(), "keep")
+	other.mustRun(handlerRestore, "--on-conflict", "merge", path)
+	starred, _ = other.s.db.GetStarredPostsForUser(other.ctx, other.usr.ID)
+	if got, _ := other.s.db.GetFeed(other.ctx, feed.Url); got.RetentionMaxPosts.Int32 != 10 || starred[0].Note != "keep" {
+		t.Errorf("merge replaced newer rows: retention %v, note %q", got.RetentionMaxPosts, starred[0].Note)
+	}
+	out = other.mustRun(handlerRestore, "--on-conflict", "overwrite", path)
+	starred, _ = other.s.db.GetStarredPostsForUser(other.ctx, other.usr.ID)
+	if got, _ := other.s.db.GetFeed(other.ctx, feed.Url); got.RetentionMaxPosts.Int32 != 50 || starred[0].Note != "read later" {
+		t.Errorf("overwrite kept rows: retention %v, note %q", got.RetentionMaxPosts, starred[0].Note)
+	}
+	if _, counts, _ := strings.Cut(out, "\n"); !strings.HasPrefix(counts, "users:   0 added, 2 replaced, 0 unchanged\n") {
+		t.Errorf("overwrite printed %q", out)
+	}
+	if usr, _ := other.s.db.GetUser(other.ctx, "alice"); usr.ID != other.usr.ID {
+		t.Errorf("overwrite changed alice's id")
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	env := newTestEnv(t)
+	dir := t.TempDir()
+
+	env.runErr(handlerBackup, "expects a file name")
+	env.runErr(handlerBackup, "error creating backup file", filepath.Join(dir, "missing", "gator.backup"))
+	env.runErr(handlerRestore, "expects a file name")
+	env.runErr(handlerRestore, "error opening backup file", filepath.Join(dir, "missing.backup"))
+
+	path := filepath.Join(dir, "gator.backup")
+	env.mustRun(handlerBackup, path)
+	env.runErr(handlerRestore, "--on-conflict must be skip, overwrite or merge", "--on-conflict", "replace", path)
+
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte(`{"format":"gator-backup","version":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env.runErr(handlerRestore, "is not a gator backup", plain)
+
+	other := filepath.Join(dir, "other.gz")
+	writeGzip(t, other, `{"name":"something else"}`+"\n")
+	env.runErr(handlerRestore, "is not a gator backup", other)
+
+	newer := filepath.Join(dir, "newer.gz")
+	writeGzip(t, newer, `{"format":"gator-backup","version":99}`+"\n")
+	env.runErr(handlerRestore, "is a version 99 backup, this gator reads up to version 2", newer)
+
+	broken := filepath.Join(dir, "broken.gz")
+	writeGzip(t, broken, `{"format":"gator-backup","version":1}`+"\n"+`{"user":`+"\n")
+	env.runErr(handlerRestore, "error reading backup line 2", broken)
+
+	unknown := filepath.Join(dir, "unknown.gz")
+	writeGzip(t, unknown, `{"format":"gator-backup","version":1}`+"\n"+`{"widget":{}}`+"\n")
+	env.runErr(handlerRestore, "line 2: unknown record", unknown)
+}
+
+// A bad line after good records fails the whole restore without writing any of them, in memory and in SQLite.
+func TestRestoreAllOrNothing(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	env.post(feed, "Generics in Go", "", time.Now())
+	env.mustRun(handlerRegister, "bob")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gator.backup")
+	env.mustRun(handlerBackup, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backup strings.Builder
+	if _, err := io.Copy(&backup, zr); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Count(backup.String(), "\n")
+	corrupt := filepath.Join(dir, "corrupt.gz")
+	writeGzip(t, corrupt, backup.String()+`{"post":{"id":`+"\n")
+
+	sqliteEnv := &testEnv{t: t, ctx: context.Background(), s: &state{cfg: &config.Config{}}}
+	sqliteEnv.s.cfg.Db_url = "sqlite://" + filepath.Join(dir, "gator.db")
+	if err := openDatabase(sqliteEnv.s); err != nil {
+		t.Fatalf("openDatabase: %v", err)
+	}
+	t.Cleanup(func() { sqliteEnv.s.conn.Close() })
+	sqliteEnv.mustRun(handlerMigrate, "up")
+
+	for name, dst := range map[string]*testEnv{"memory": newTestEnv(t), "sqlite": sqliteEnv} {
+		_, err := dst.run(handlerRestore, corrupt)
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("nothing was restored: error reading backup line %v", lines+1)) {
+			t.Errorf("%v: restore of a corrupt backup = %v", name, err)
+		}
+		if feeds, err := dst.s.db.GetFeeds(dst.ctx); err != nil || len(feeds) != 0 {
+			t.Errorf("%v: feeds after a failed restore = %+v, %v", name, feeds, err)
+		}
+		if _, err := dst.s.db.GetUser(dst.ctx, "bob"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%v: bob after a failed restore: %v, want sql.ErrNoRows", name, err)
+		}
+	}
+}
+
+// A backup moves between backends, here from the in-memory store to a SQLite file.
+func TestRestoreIntoSQLite(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	env.post(feed, "Generics in Go", "Type parameters", time.Now())
+	path := filepath.Join(t.TempDir(), "gator.backup")
+	env.mustRun(handlerBackup, path)
+
+	dst := &testEnv{t: t, ctx: context.Background(), s: &state{cfg: &config.Config{}}}
+	dst.s.cfg.Db_url = "sqlite://" + filepath.Join(t.TempDir(), "gator.db")
+	if err := openDatabase(dst.s); err != nil {
+		t.Fatalf("openDatabase: %v", err)
+	}
+	t.Cleanup(func() { dst.s.conn.Close() })
+	dst.mustRun(handlerMigrate, "up")
+
+	out := dst.mustRun(handlerRestore, path)
+	if !strings.Contains(out, "posts:   1 added") {
+		t.Errorf("restore printed %q", out)
+	}
+	results, err := dst.s.db.SearchPostsForUser(dst.ctx, database.SearchPostsForUserParams{Query: "parameters", UserID: env.usr.ID, Limit: 10})
+	if err != nil || len(results) != 1 {
+		t.Errorf("search after restoring into sqlite = %+v, %v", results, err)
+	}
+	if out := dst.mustRun(handlerBackup, path); !strings.HasPrefix(out, "Backed up 1 users, 1 feeds, 1 follows, 1 posts") {
+		t.Errorf("backing up the restored database printed %q", out)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/dateparse"
+	"github.com/striderjg/gator/internal/output"
+)
+
+// All four BrowsePostsBy* queries return the same columns, so their rows convert to this one.
+type browsePost = database.BrowsePostsByPublishedRow
+
+// browseOutput is the record `browse --output` writes for every post.
+type browseOutput struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	FeedName    string     `json:"feed_name"`
+	PublishedAt *time.Time `json:"published_at"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+	Read        bool       `json:"read"`
+	Description string     `json:"description"`
+}
+
+// stringList is a flag that can be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func handlerBrowse(ctx context.Context, s *state, cmd command, usr database.User) error {
+	fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+	limit := fs.Int("limit", 2, "number of posts to list")
+	cursor := fs.String("cursor", "", "continue from where the previous page ended")
+	feed := fs.String("feed", "", "only list posts from the feed with this url or name")
+	since := fs.String("since", "", "only list posts from this date on")
+	until := fs.String("until", "", "only list posts from before this date")
+	sortBy := fs.String("sort", "published", "published or fetched")
+	reverse := fs.Bool("reverse", false, "oldest first")
+	unread := fs.Bool("unread", false, "only list posts that haven't been read")
+	var include, exclude stringList
+	fs.Var(&include, "include", "only list posts whose title or description contain this word (repeatable)")
+	fs.Var(&exclude, "exclude", "skip posts whose title or description contain this word (repeatable)")
+	var format output.Format
+	fs.Var(&format, "output", "text, json, jsonl, csv, yaml or table")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q, use --limit for the number of posts", args[0])
+	}
+	if *limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+	if *sortBy != "published" && *sortBy != "fetched" {
+		return fmt.Errorf("unknown --sort %q, expected published or fetched", *sortBy)
+	}
+
+	params := database.BrowsePostsByPublishedParams{
+		UserID:     usr.ID,
+		UnreadOnly: *unread,
+		Include:    likePatterns(include),
+		Exclude:    likePatterns(exclude),
+		// One extra row tells us whether there's another page
+		Limit: int32(*limit + 1),
+	}
+	if *feed != "" {
+		params.Feed = sql.NullString{String: *feed, Valid: true}
+	}
+	if params.Since, err = parseDateFlag("since", *since); err != nil {
+		return err
+	}
+	if params.Until, err = parseDateFlag("until", *until); err != nil {
+		return err
+	}
+	order := browseOrder(*sortBy, *reverse)
+	if *cursor != "" {
+		at, id, err := decodeBrowseCursor(*cursor, order)
+		if err != nil {
+			return err
+		}
+		params.CursorAt = sql.NullTime{Time: at, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	posts, err := browsePosts(ctx, s, order, params)
+	if err != nil {
+		return fmt.Errorf("error retrieving posts for user: %w", err)
+	}
+	more := len(posts) > *limit
+	if more {
+		posts = posts[:*limit]
+	}
+
+	var next string
+	if more {
+		last := posts[len(posts)-1]
+		next = encodeBrowseCursor(order, last.SortAt, last.ID)
+	}
+
+	if !format.IsText() {
+		records := make([]browseOutput, 0, len(posts))
+		for _, post := range posts {
+			records = append(records, browseOutput{
+				ID:          post.ID,
+				Title:       post.Title,
+				URL:         post.Url,
+				FeedName:    post.FeedName,
+				PublishedAt: nullTimePtr(post.PublishedAt),
+				FetchedAt:   post.CreatedAt,
+				Read:        post.Read,
+				Description: post.Description,
+			})
+		}
+		if err := output.Write(os.Stdout, format, records); err != nil {
+			return err
+		}
+		// Kept off stdout so it doesn't break the records
+		if next != "" {
+			fmt.Fprintf(os.Stderr, "next page: --cursor %v\n", next)
+		}
+		return nil
+	}
+
+	for _, post := range posts {
+		fmt.Println("++++++++++++++++++++++++++++++++++++++++++++++++++++")
+		fmt.Println(post.Title)
+		fmt.Println(post.Url)
+		status := "unread"
+		if post.Read {
+			status = "read"
+		}
+		fmt.Printf("id: %v (%v, %v, %v)\n", post.ID, post.FeedName, post.SortAt.Format("2006-01-02 15:04"), status)
+		fmt.Println("====================================================")
+		fmt.Println(post.Description)
+		fmt.Println("")
+	}
+	if next != "" {
+		fmt.Printf("More posts, next page: --cursor %v\n", next)
+	}
+	return nil
+}
+
+func browseOrder(sortBy string, reverse bool) string {
+	if reverse {
+		return sortBy + "-reverse"
+	}
+	return sortBy
+}
+
+// browsePosts runs the keyset query matching order.  Each ordering has its own query so it can use its index.
+func browsePosts(ctx context.Context, s *state, order string, params database.BrowsePostsByPublishedParams) ([]browsePost, error) {
+	var posts []browsePost
+	switch order {
+	case "published":
+		return s.db.BrowsePostsByPublished(ctx, params)
+	case "published-reverse":
+		rows, err := s.db.BrowsePostsByPublishedReverse(ctx, database.BrowsePostsByPublishedReverseParams(params))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			posts = append(posts, browsePost(row))
+		}
+	case "fetched":
+		rows, err := s.db.BrowsePostsByFetched(ctx, database.BrowsePostsByFetchedParams(params))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			posts = append(posts, browsePost(row))
+		}
+	case "fetched-reverse":
+		rows, err := s.db.BrowsePostsByFetchedReverse(ctx, database.BrowsePostsByFetchedReverseParams(params))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			posts = append(posts, browsePost(row))
+		}
+	default:
+		return nil, fmt.Errorf("unknown order %v", order)
+	}
+	return posts, nil
+}
+
+// A cursor is the sort key and id of the last post on a page, tagged with the order it came from.
+func encodeBrowseCursor(order string, at time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%v|%v|%v", order, at.UTC().Format(time.RFC3339Nano), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBrowseCursor(cursor, order string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, errors.New("invalid --cursor")
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return time.Time{}, uuid.UUID{}, errors.New("invalid --cursor")
+	}
+	if parts[0] != order {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("--cursor is from a %v listing, use the same --sort and --reverse", parts[0])
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, errors.New("invalid --cursor")
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, errors.New("invalid --cursor")
+	}
+	return at, id, nil
+}
+
+func parseDateFlag(name, value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := dateparse.Parse(value)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("error parsing --%v: %w", name, err)
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// likePatterns escapes ILIKE wildcards so keywords match literally.  Never nil, the queries unnest it.
+func likePatterns(words []string) []string {
+	escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	patterns := []string{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			patterns = append(patterns, escape.Replace(word))
+		}
+	}
+	return patterns
+}
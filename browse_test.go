@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrowse(t *testing.T) {
+	env := newTestEnv(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	news := env.feed("News", "https://example.com/news")
+	env.post(blog, "First", "About generics", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	env.post(blog, "Second", "About errors", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	third := env.post(news, "Third", "About generics", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC))
+	browse := middlewareLoggedIn(handlerBrowse)
+
+	out := env.mustRun(browse)
+	if !strings.Contains(out, "Third\n") || !strings.Contains(out, "Second\n") || strings.Contains(out, "First\n") {
+		t.Errorf("browse printed %q", out)
+	}
+	if !strings.Contains(out, "id: "+third.ID.String()+" (News, 2024-03-03 00:00, unread)") {
+		t.Errorf("browse printed %q", out)
+	}
+	_, cursor, ok := strings.Cut(out, "More posts, next page: --cursor ")
+	if !ok {
+		t.Fatalf("browse printed no cursor: %q", out)
+	}
+	out = env.mustRun(browse, "--cursor", strings.TrimSpace(cursor))
+	if !strings.Contains(out, "First\n") || strings.Contains(out, "Second\n") || strings.Contains(out, "More posts") {
+		t.Errorf("second page printed %q", out)
+	}
+
+	var posts []browseOutput
+	if err := json.Unmarshal([]byte(env.mustRun(browse, "--output", "json", "--reverse", "--feed", "Blog", "--limit", "5")), &posts); err != nil {
+		t.Fatalf("decoding browse: %v", err)
+	}
+	if len(posts) != 2 || posts[0].Title != "First" || posts[1].Title != "Second" {
+		t.Errorf("browse --reverse --feed Blog = %+v", posts)
+	}
+	if err := json.Unmarshal([]byte(env.mustRun(browse, "--output", "json", "--include", "generics", "--exclude", "first", "--since", "2024-03-02")), &posts); err != nil {
+		t.Fatalf("decoding browse: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ID != third.ID {
+		t.Errorf("browse --include --exclude --since = %+v", posts)
+	}
+
+	env.mustRun(middlewareLoggedIn(handlerRead), third.ID.String())
+	if err := json.Unmarshal([]byte(env.mustRun(browse, "--output", "json", "--unread", "--sort", "fetched")), &posts); err != nil {
+		t.Fatalf("decoding browse: %v", err)
+	}
+	if len(posts) != 2 || posts[0].ID == third.ID || posts[1].ID == third.ID {
+		t.Errorf("browse --unread = %+v", posts)
+	}
+
+	env.runErr(browse, "unexpected argument", "5")
+	env.runErr(browse, "--limit must be at least 1", "--limit", "0")
+	env.runErr(browse, "unknown --sort", "--sort", "title")
+	env.runErr(browse, "since", "--since", "someday")
+	env.runErr(browse, "cursor", "--cursor", "nonsense")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Feed types advertised with <link rel="alternate" type="..."> that parseFeed can read.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// Paths probed, in order, when a page doesn't link to its feed.
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/rss",
+	"/feed.json",
+}
+
+// multipleFeedsError is returned when a page links to more than one feed.
+type multipleFeedsError struct {
+	page       string
+	candidates []string
+}
+
+func (e *multipleFeedsError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "found %v feeds at %v, rerun with one of:", len(e.candidates), e.page)
+	for _, candidate := range e.candidates {
+		sb.WriteString("\n  " + candidate)
+	}
+	return sb.String()
+}
+
+// discoverFeed resolves pageURL to the URL of a feed gator can read.  pageURL is returned as is if it's already a feed,
+// otherwise the page's <link rel="alternate"> feeds are used, falling back on probing commonFeedPaths.
+func discoverFeed(ctx context.Context, pageURL string) (string, error) {
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "https://" + pageURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error making request: %w", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	res, err := feedClientFor(ctx).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting responce: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("bad Status Code from %v: %v", pageURL, res.Status)
+	}
+	body, err := readLimited(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+
+	_, feedErr := parseFeed(body, res.Header.Get("Content-Type"))
+	if feedErr == nil {
+		return pageURL, nil
+	}
+	if !isHTML(res.Header.Get("Content-Type")) {
+		return "", feedErr
+	}
+
+	// Relative links resolve against wherever redirects left us
+	base := res.Request.URL
+	candidates := feedLinks(body, base)
+	switch len(candidates) {
+	case 0:
+	case 1:
+		if _, err := fetchFeed(ctx, candidates[0]); err != nil {
+			return "", fmt.Errorf("error fetching feed linked from %v: %w", pageURL, err)
+		}
+		return candidates[0], nil
+	default:
+		return "", &multipleFeedsError{page: pageURL, candidates: candidates}
+	}
+
+	for _, path := range commonFeedPaths {
+		probe := base.ResolveReference(&url.URL{Path: path}).String()
+		if _, err := fetchFeed(ctx, probe); err == nil {
+			return probe, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	return "", fmt.Errorf("no feed found at %v", pageURL)
+}
+
+// feedLinks returns the deduplicated, absolute hrefs of the feeds an HTML page advertises.
+func feedLinks(body []byte, base *url.URL) []string {
+	var links []string
+	seen := map[string]bool{}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		if tok.DataAtom != atom.Link {
+			continue
+		}
+
+		var rel, typ, href string
+		for _, attr := range tok.Attr {
+			switch attr.Key {
+			case "rel":
+				rel = attr.Val
+			case "type":
+				typ = attr.Val
+			case "href":
+				href = strings.TrimSpace(attr.Val)
+			}
+		}
+		if href == "" || !hasRel(rel, "alternate") {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(typ)
+		if err != nil || !feedLinkTypes[mediaType] {
+			continue
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		link := base.ResolveReference(ref).String()
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+}
+
+// hasRel reports whether the space separated rel attribute contains want.
+func hasRel(rel, want string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// No usable Content-Type, let the tokenizer have a go
+		return true
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFeedLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post.html")
+	cases := []struct {
+		name string
+		head string
+		want []string
+	}{
+		{"absolute", `<link rel="alternate" type="application/rss+xml" href="https://feeds.example.com/rss">`, []string{"https://feeds.example.com/rss"}},
+		{"relative to the page", `<link rel="alternate" type="application/atom+xml" href="atom.xml">`, []string{"https://example.com/blog/atom.xml"}},
+		{"relative to the root", `<link rel="alternate" type="application/feed+json" href="/feed.json">`, []string{"https://example.com/feed.json"}},
+		{"protocol relative", `<link rel="alternate" type="application/rss+xml" href="//cdn.example.com/rss">`, []string{"https://cdn.example.com/rss"}},
+		{"type parameters and case", `<LINK REL="Alternate home" TYPE="application/rss+xml; charset=utf-8" HREF=" /rss ">`, []string{"https://example.com/rss"}},
+		{"self closing rdf", `<link rel="alternate" type="application/rdf+xml" href="/index.rdf" />`, []string{"https://example.com/index.rdf"}},
+		{"deduplicated", `<link rel="alternate" type="application/rss+xml" href="/rss"><link rel="alternate" type="application/rss+xml" href="https://example.com/rss">`, []string{"https://example.com/rss"}},
+		{
+			"several feeds in order",
+			`<link rel="alternate" type="application/rss+xml" href="/rss"><link rel="alternate" type="application/atom+xml" href="/atom">`,
+			[]string{"https://example.com/rss", "https://example.com/atom"},
+		},
+		{"not alternate", `<link rel="stylesheet" type="text/css" href="/style.css"><link rel="feed" type="application/rss+xml" href="/rss">`, nil},
+		{"not a feed type", `<link rel="alternate" type="text/html" hreflang="fr" href="/fr/">`, nil},
+		{"no href", `<link rel="alternate" type="application/rss+xml">`, nil},
+		{"a elements don't count", `</head><body><a rel="alternate" type="application/rss+xml" href="/rss">RSS</a>`, nil},
+	}
+	for _, c := range cases {
+		got := feedLinks([]byte("<html><head>"+c.head+"</head><body></body></html>"), base)
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%v: feedLinks = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDiscoverFeed(t *testing.T) {
+	rss := `<rss version="2.0"><channel><title>Blog</title></channel></rss>`
+	pages := map[string]string{
+		"/linked/":  `<html><head><link rel="alternate" type="application/rss+xml" href="feed.xml"></head></html>`,
+		"/multiple": `<html><head><link rel="alternate" type="application/rss+xml" href="/a.xml"><link rel="alternate" type="application/atom+xml" href="/b.xml"></head></html>`,
+		"/broken":   `<html><head><link rel="alternate" type="application/rss+xml" href="/missing.xml"></head></html>`,
+		"/plain":    `<html><head><title>No feeds here</title></head></html>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.Path; {
+		case pages[path] != "":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, pages[path])
+		case path == "/redirect":
+			http.Redirect(w, r, "/linked/", http.StatusFound)
+		case path == "/huge":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html>"+strings.Repeat(" ", maxBodySize)+"</html>")
+		case path == "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "just words")
+		case path == "/rss.xml", path == "/linked/feed.xml", path == "/a.xml", path == "/b.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(w, rss)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name string
+		page string
+		want string
+		err  string
+	}{
+		{name: "already a feed", page: "/rss.xml", want: "/rss.xml"},
+		{name: "linked relative to the page", page: "/linked/", want: "/linked/feed.xml"},
+		{name: "linked after a redirect", page: "/redirect", want: "/linked/feed.xml"},
+		{name: "common path", page: "/plain", want: "/rss.xml"},
+		{name: "broken link", page: "/broken", err: "error fetching feed linked from"},
+		{name: "not html or a feed", page: "/text", err: "no root element found"},
+		{name: "missing page", page: "/missing", err: "404 Not Found"},
+		{name: "page too large", page: "/huge", err: "larger than 8 MB"},
+	}
+	for _, c := range cases {
+		got, err := discoverFeed(context.Background(), srv.URL+c.page)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%v: discoverFeed = %v, %v, want an error containing %q", c.name, got, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got != srv.URL+c.want {
+			t.Errorf("%v: discoverFeed = %v, %v, want %v", c.name, got, err, srv.URL+c.want)
+		}
+	}
+
+	_, err := discoverFeed(context.Background(), srv.URL+"/multiple")
+	var multiple *multipleFeedsError
+	if !errors.As(err, &multiple) {
+		t.Fatalf("discoverFeed of a page with two feeds = %v, want a multipleFeedsError", err)
+	}
+	if fmt.Sprint(multiple.candidates) != fmt.Sprint([]string{srv.URL + "/a.xml", srv.URL + "/b.xml"}) {
+		t.Errorf("candidates = %v", multiple.candidates)
+	}
+	if want := "found 2 feeds at " + srv.URL + "/multiple, rerun with one of:\n  " + srv.URL + "/a.xml\n  " + srv.URL + "/b.xml"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestDiscoverFeedNoFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Nothing</title></head></html>`)
+	}))
+	defer srv.Close()
+	if _, err := discoverFeed(context.Background(), srv.URL+"/"); err == nil || err.Error() != "no feed found at "+srv.URL+"/" {
+		t.Errorf("discoverFeed of a page without feeds = %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseFeed sniffs the format of a feed document and decodes it into an RSSFeed
+// so the rest of gator only has to deal with one item model.
+func parseFeed(body []byte, contentType string) (*RSSFeed, error) {
+	if looksLikeJSON(body, contentType) {
+		if !isJSONFeed(body) {
+			return nil, errors.New("not a feed: JSON document without a jsonfeed.org version")
+		}
+		var jsonFeed JSONFeed
+		if err := json.Unmarshal(body, &jsonFeed); err != nil {
+			return nil, fmt.Errorf("error decoding JSONFeed: %w", err)
+		}
+		return jsonFeedToRSS(&jsonFeed), nil
+	}
+
+	root, err := xmlRootElement(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading feed root element: %w", err)
+	}
+
+	switch root.Local {
+	case "rss":
+		var feed RSSFeed
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			return nil, fmt.Errorf("error decoding RSSFeed: %w", err)
+		}
+		applyDublinCore(feed.Channel.Item)
+		return &feed, nil
+	case "RDF":
+		var rdf RDFFeed
+		if err := xml.Unmarshal(body, &rdf); err != nil {
+			return nil, fmt.Errorf("error decoding RDFFeed: %w", err)
+		}
+		return rdfToRSS(&rdf), nil
+	case "feed":
+		var atom AtomFeed
+		if err := xml.Unmarshal(body, &atom); err != nil {
+			return nil, fmt.Errorf("error decoding AtomFeed: %w", err)
+		}
+		return atomToRSS(&atom), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%v>", root.Local)
+	}
+}
+
+func xmlRootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return xml.Name{}, errors.New("no root element found")
+			}
+			return xml.Name{}, err
+		}
+		if elem, ok := t.(xml.StartElement); ok {
+			return elem.Name, nil
+		}
+	}
+}
+
+func atomToRSS(atom *AtomFeed) *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = atom.Title
+	feed.Channel.Link = atomAlternateLink(atom.Links)
+	feed.Channel.Description = atom.Subtitle
+
+	for _, entry := range atom.Entries {
+		description := entry.Summary.value()
+		if description == "" {
+			description = entry.Content.value()
+		}
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       entry.Title,
+			Link:        atomAlternateLink(entry.Links),
+			Description: description,
+			PubDate:     entry.Published,
+			Updated:     entry.Updated,
+			Guid:        entry.ID,
+			Author:      atomAuthors(entry.Authors),
+		})
+	}
+	return &feed
+}
+
+func rdfToRSS(rdf *RDFFeed) *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = rdf.Channel.Title
+	feed.Channel.Link = rdf.Channel.Link
+	feed.Channel.Description = rdf.Channel.Description
+	feed.Channel.UpdatePeriod = rdf.Channel.UpdatePeriod
+	feed.Channel.UpdateFrequency = rdf.Channel.UpdateFrequency
+	feed.Channel.Item = rdf.Items
+	applyDublinCore(feed.Channel.Item)
+	return &feed
+}
+
+// applyDublinCore fills in the standard item fields from dc:creator and dc:subject
+// when the feed didn't provide them natively.  dc:date is a fallback handled by scrapeFeeds.
+func applyDublinCore(items []RSSItem) {
+	for i := range items {
+		item := &items[i]
+		if item.Author == "" {
+			item.Author = strings.TrimSpace(item.DcCreator)
+		}
+		for _, subject := range item.DcSubject {
+			if subject = strings.TrimSpace(subject); subject != "" {
+				item.Categories = append(item.Categories, subject)
+			}
+		}
+	}
+}
+
+// jsonFeedVersion prefixes the version URL every JSON Feed declares, e.g. https://jsonfeed.org/version/1.1
+const jsonFeedVersion = "https://jsonfeed.org/version/"
+
+func looksLikeJSON(body []byte, contentType string) bool {
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+	// Lots of servers hand out feeds as text/plain or application/octet-stream, so fall back on the body.
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// isJSONFeed reports whether body is a JSON object with a JSON Feed version, so API responses and
+// error pages served as application/json aren't mistaken for feeds.
+func isJSONFeed(body []byte) bool {
+	var doc struct {
+		Version string `json:"version"`
+	}
+	return json.Unmarshal(body, &doc) == nil && strings.HasPrefix(doc.Version, jsonFeedVersion)
+}
+
+func jsonFeedToRSS(jsonFeed *JSONFeed) *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = jsonFeed.Title
+	feed.Channel.Link = jsonFeed.HomePageURL
+	feed.Channel.Description = jsonFeed.Description
+
+	for _, item := range jsonFeed.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		description := item.Summary
+		if description == "" {
+			description = item.ContentHTML
+		}
+		if description == "" {
+			description = item.ContentText
+		}
+
+		authors := item.Authors
+		if len(authors) == 0 && item.Author != nil {
+			authors = []JSONFeedAuthor{*item.Author}
+		}
+		if len(authors) == 0 {
+			authors = jsonFeed.Authors
+		}
+		var names []string
+		for _, author := range authors {
+			if author.Name != "" {
+				names = append(names, author.Name)
+			}
+		}
+
+		var enclosures []RSSEnclosure
+		for _, attachment := range item.Attachments {
+			enclosure := RSSEnclosure{URL: attachment.URL, Type: attachment.MimeType}
+			if attachment.SizeInBytes > 0 {
+				enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			enclosures = append(enclosures, enclosure)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     item.DatePublished,
+			Updated:     item.DateModified,
+			Guid:        item.ID,
+			Author:      strings.Join(names, ", "),
+			Enclosures:  enclosures,
+		})
+	}
+	return &feed
+}
+
+// atomAlternateLink picks the rel="alternate" link (rel defaults to alternate when missing),
+// falling back on the first link with an href.
+func atomAlternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if (link.Rel == "" || link.Rel == "alternate") && link.Href != "" {
+			return link.Href
+		}
+	}
+	for _, link := range links {
+		if link.Href != "" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func atomAuthors(authors []AtomPerson) string {
+	var names []string
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func (t AtomText) value() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
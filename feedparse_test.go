@@ -0,0 +1,260 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/striderjg/gator/internal/dateparse"
+)
+
+func TestParseFeedRoot(t *testing.T) {
+	cases := []struct {
+		name  string
+		body  string
+		title string
+		err   string
+	}{
+		{"rss", `<?xml version="1.0"?><rss version="2.0"><channel><title>RSS</title></channel></rss>`, "RSS", ""},
+		{"atom", `<?xml version="1.0"?><!-- comment --><feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`, "Atom", ""},
+		{"html", `<html><head><title>Page</title></head></html>`, "", "unsupported feed format: <html>"},
+		{"empty", ``, "", "no root element found"},
+	}
+	for _, c := range cases {
+		feed, err := parseFeed([]byte(c.body), "application/xml")
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%v: error %v, want one containing %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+		if feed.Channel.Title != c.title {
+			t.Errorf("%v: title %q, want %q", c.name, feed.Channel.Title, c.title)
+		}
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Blog</title>
+  <subtitle>Notes</subtitle>
+  <link rel="self" href="https://example.com/atom.xml"/>
+  <link href="https://example.com/"/>
+  <entry>
+    <id>tag:example.com,2024:1</id>
+    <title>Summary and links</title>
+    <link rel="edit" href="https://example.com/edit/1"/>
+    <link rel="alternate" type="text/html" href="https://example.com/1"/>
+    <summary>Short version</summary>
+    <content type="html">&lt;p&gt;Long version&lt;/p&gt;</content>
+    <published>2024-01-01T10:00:00Z</published>
+    <updated>2024-01-02T10:00:00Z</updated>
+    <author><name>Ann</name></author>
+    <author><name> Bob </name></author>
+  </entry>
+  <entry>
+    <id>tag:example.com,2024:2</id>
+    <title>Content only</title>
+    <link rel="enclosure" href="https://example.com/2.mp3"/>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+    <author><name></name></author>
+  </entry>
+  <entry>
+    <id>tag:example.com,2024:3</id>
+    <title>Text content</title>
+    <content type="text">  plain words  </content>
+  </entry>
+</feed>`
+	feed, err := parseFeed([]byte(body), "application/atom+xml")
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if feed.Channel.Title != "Example Blog" || feed.Channel.Description != "Notes" || feed.Channel.Link != "https://example.com/" {
+		t.Errorf("channel = %q, %q, %q", feed.Channel.Title, feed.Channel.Description, feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 3 {
+		t.Fatalf("got %v items, want 3", len(feed.Channel.Item))
+	}
+
+	first := feed.Channel.Item[0]
+	if first.Link != "https://example.com/1" {
+		t.Errorf("first link = %q, want the alternate link", first.Link)
+	}
+	if first.Description != "Short version" {
+		t.Errorf("first description = %q, want the summary", first.Description)
+	}
+	if first.PubDate != "2024-01-01T10:00:00Z" || first.Updated != "2024-01-02T10:00:00Z" {
+		t.Errorf("first dates = %q, %q", first.PubDate, first.Updated)
+	}
+	if first.Author != "Ann, Bob" {
+		t.Errorf("first author = %q", first.Author)
+	}
+
+	// Without an alternate link the first href is used, without a summary the content
+	second := feed.Channel.Item[1]
+	if second.Link != "https://example.com/2.mp3" {
+		t.Errorf("second link = %q", second.Link)
+	}
+	if second.Description != "<p>Body</p>" {
+		t.Errorf("second description = %q", second.Description)
+	}
+	if second.Author != "" {
+		t.Errorf("second author = %q, want none", second.Author)
+	}
+
+	if third := feed.Channel.Item[2]; third.Link != "" || third.Description != "plain words" {
+		t.Errorf("third = %q, %q", third.Link, third.Description)
+	}
+}
+
+func TestAtomTextXHTML(t *testing.T) {
+	body := `<feed xmlns="http://www.w3.org/2005/Atom"><entry><title>x</title>` +
+		`<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div></content></entry></feed>`
+	feed, err := parseFeed([]byte(body), "")
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if got := feed.Channel.Item[0].Description; !strings.Contains(got, "<p>Hi</p>") {
+		t.Errorf("xhtml content = %q, want the markup kept", got)
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		author      string
+		description string
+		err         string
+	}{
+		{
+			name:        "1.1 authors and content_html",
+			contentType: "application/feed+json",
+			body: `{"version":"https://jsonfeed.org/version/1.1","title":"Blog","items":[{"id":"1","url":"https://example.com/1",
+				"content_html":"<p>Hi</p>","content_text":"Hi","authors":[{"name":"Ann"},{"name":""},{"name":"Bob"}]}]}`,
+			author:      "Ann, Bob",
+			description: "<p>Hi</p>",
+		},
+		{
+			name:        "1.0 author and content_text",
+			contentType: "text/plain",
+			body: `{"version":"https://jsonfeed.org/version/1","title":"Blog","items":[{"id":"1","url":"https://example.com/1",
+				"content_text":"Hi","author":{"name":"Ann"}}]}`,
+			author:      "Ann",
+			description: "Hi",
+		},
+		{
+			name:        "feed level authors",
+			contentType: "application/json",
+			body: `{"version":"https://jsonfeed.org/version/1.1","title":"Blog","authors":[{"name":"Cy"}],
+				"items":[{"id":"1","summary":"Short","content_html":"<p>Long</p>"}]}`,
+			author:      "Cy",
+			description: "Short",
+		},
+		{
+			name:        "not a feed",
+			contentType: "application/json",
+			body:        `{"error":"rate limited","version":"2"}`,
+			err:         "not a feed",
+		},
+		{
+			name:        "error page served as json",
+			contentType: "application/json; charset=utf-8",
+			body:        `<html><body>Internal Server Error</body></html>`,
+			err:         "not a feed",
+		},
+	}
+	for _, c := range cases {
+		feed, err := parseFeed([]byte(c.body), c.contentType)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%v: error %v, want one containing %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+		if feed.Channel.Title != "Blog" || len(feed.Channel.Item) != 1 {
+			t.Errorf("%v: channel %q with %v items", c.name, feed.Channel.Title, len(feed.Channel.Item))
+			continue
+		}
+		item := feed.Channel.Item[0]
+		if item.Author != c.author || item.Description != c.description || item.Guid != "1" {
+			t.Errorf("%v: item author %q, description %q, guid %q, want %q, %q", c.name, item.Author, item.Description, item.Guid, c.author, c.description)
+		}
+	}
+}
+
+func TestParseRDF(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+  <channel rdf:about="https://example.com/">
+    <title>Example</title>
+    <link>https://example.com/</link>
+    <description>An RSS 1.0 feed</description>
+    <sy:updatePeriod>daily</sy:updatePeriod>
+    <items><rdf:Seq><rdf:li rdf:resource="https://example.com/1"/></rdf:Seq></items>
+  </channel>
+  <item rdf:about="https://example.com/1">
+    <title>First</title>
+    <link>https://example.com/1</link>
+    <description>Hello</description>
+    <dc:date>2024-01-01T10:00:00+02:00</dc:date>
+    <dc:creator> Ann </dc:creator>
+    <dc:subject>go</dc:subject>
+    <dc:subject> </dc:subject>
+  </item>
+  <item rdf:about="https://example.com/2">
+    <title>Second</title>
+    <link>https://example.com/2</link>
+  </item>
+</rdf:RDF>`
+	feed, err := parseFeed([]byte(body), "application/rdf+xml")
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if feed.Channel.Title != "Example" || feed.Channel.Link != "https://example.com/" || feed.Channel.UpdatePeriod != "daily" {
+		t.Errorf("channel = %q, %q, %q", feed.Channel.Title, feed.Channel.Link, feed.Channel.UpdatePeriod)
+	}
+	// Items sit outside <channel> in RSS 1.0
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %v items, want 2", len(feed.Channel.Item))
+	}
+	first := feed.Channel.Item[0]
+	if first.Title != "First" || first.Link != "https://example.com/1" || first.Description != "Hello" {
+		t.Errorf("first item = %+v", first)
+	}
+	if first.Author != "Ann" {
+		t.Errorf("dc:creator became author %q, want Ann", first.Author)
+	}
+	if len(first.Categories) != 1 || first.Categories[0] != "go" {
+		t.Errorf("dc:subject became categories %q", first.Categories)
+	}
+	published, err := dateparse.ParseFirst(first.PubDate, first.Updated, first.DcDate)
+	if err != nil || !published.Equal(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("dc:date published = %v, %v", published, err)
+	}
+	if second := feed.Channel.Item[1]; second.Author != "" || second.DcDate != "" {
+		t.Errorf("second item = %+v", second)
+	}
+}
+
+func TestApplyDublinCoreKeepsNativeFields(t *testing.T) {
+	items := []RSSItem{{Author: "ann@example.com (Ann)", DcCreator: "Someone else", Categories: []string{"news"}, DcSubject: []string{"go"}}}
+	applyDublinCore(items)
+	if items[0].Author != "ann@example.com (Ann)" {
+		t.Errorf("author = %q, want the native one", items[0].Author)
+	}
+	if strings.Join(items[0].Categories, ",") != "news,go" {
+		t.Errorf("categories = %q", items[0].Categories)
+	}
+}
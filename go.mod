@@ -5,4 +5,6 @@ go 1.23.5
 require (
 	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
+	golang.org/x/net v0.35.0
 )
@@ -12,6 +12,15 @@ const cfgFileaname = ".gatorconfig.json"
 type Config struct {
 	Db_url       string
 	Current_user string
+	Retention    *Retention `json:",omitempty"`
+}
+
+// Retention is the default post retention for every feed.  Feeds can override each field with
+// gator feed set-retention.
+type Retention struct {
+	Max_age     string // e.g. 30d, 8w or 720h.  Empty keeps posts forever
+	Max_posts   int    // newest posts kept per feed, 0 for no limit
+	Keep_unread bool   // keep posts a follower of the feed hasn't read
 }
 
 func (c *Config) SetUser(currentUser string) error {
@@ -7,31 +7,113 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds SET updated_at = $1, last_fetched_at = $1,
+    lease_owner = $2, lease_expires_at = $3
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE NOT feeds.disabled
+        AND (feeds.next_fetch_at IS NULL OR feeds.next_fetch_at <= $1)
+        AND (feeds.lease_expires_at IS NULL OR feeds.lease_expires_at < $1)
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT $4
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size, lease_owner, lease_expires_at, last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days, interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only
+`
+
+type ClaimFeedsToFetchParams struct {
+	Now            time.Time
+	Owner          string
+	LeaseExpiresAt sql.NullTime
+	Batch          int32
+}
+
+// Leases the most overdue feeds to one agg process.  SKIP LOCKED keeps concurrent claimers from
+// blocking on or double claiming the same rows, and expired leases from crashed workers are fair game.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch,
+		arg.Now,
+		arg.Owner,
+		arg.LeaseExpiresAt,
+		arg.Batch,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastBodySize,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.HintedIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.IntervalOverrideSeconds,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.RetentionKeepUnread,
+			&i.PublicOnly,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, public_only)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size, lease_owner, lease_expires_at, last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days, interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	Url        string
+	UserID     uuid.UUID
+	PublicOnly bool
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -42,6 +124,7 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.PublicOnly,
 	)
 	var i Feed
 	err := row.Scan(
@@ -52,12 +135,42 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastBodySize,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.HintedIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.IntervalOverrideSeconds,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.RetentionKeepUnread,
+		&i.PublicOnly,
 	)
 	return i, err
 }
 
+const enableFeed = `-- name: EnableFeed :execrows
+UPDATE feeds SET disabled = false, consecutive_failures = 0, next_fetch_at = NULL WHERE url = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, url string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableFeed, url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds WHERE url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size, lease_owner, lease_expires_at, last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days, interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only FROM feeds WHERE url = $1
 `
 
 func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
@@ -71,10 +184,121 @@ func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastBodySize,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.HintedIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.IntervalOverrideSeconds,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.RetentionKeepUnread,
+		&i.PublicOnly,
 	)
 	return i, err
 }
 
+const getFeedErrors = `-- name: GetFeedErrors :many
+SELECT f.name, f.url, f.last_error, f.last_error_at, f.consecutive_failures, f.next_fetch_at, f.disabled
+FROM feeds f
+WHERE f.consecutive_failures > 0 OR f.disabled
+ORDER BY f.disabled DESC, f.consecutive_failures DESC, f.url
+`
+
+type GetFeedErrorsRow struct {
+	Name                string
+	Url                 string
+	LastError           string
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int32
+	NextFetchAt         sql.NullTime
+	Disabled            bool
+}
+
+func (q *Queries) GetFeedErrors(ctx context.Context) ([]GetFeedErrorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedErrorsRow
+	for rows.Next() {
+		var i GetFeedErrorsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedRetentions = `-- name: GetFeedRetentions :many
+SELECT id, name, url, retention_max_age_seconds, retention_max_posts, retention_keep_unread
+FROM feeds
+ORDER BY name, url
+`
+
+type GetFeedRetentionsRow struct {
+	ID                     uuid.UUID
+	Name                   string
+	Url                    string
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+	RetentionKeepUnread    sql.NullBool
+}
+
+func (q *Queries) GetFeedRetentions(ctx context.Context) ([]GetFeedRetentionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedRetentions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedRetentionsRow
+	for rows.Next() {
+		var i GetFeedRetentionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.RetentionKeepUnread,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeeds = `-- name: GetFeeds :many
 SELECT f.name, f.url, u.name AS username FROM feeds f INNER JOIN users u ON u.id = f.user_id
 `
@@ -108,12 +332,84 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds ORDER BY last_fetched_at NULLS FIRST LIMIT 1
+const listFeeds = `-- name: ListFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size, lease_owner, lease_expires_at, last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days, interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only FROM feeds ORDER BY created_at, id
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+func (q *Queries) ListFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, listFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastBodySize,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.HintedIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.IntervalOverrideSeconds,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.RetentionKeepUnread,
+			&i.PublicOnly,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds SET last_error = $1, last_error_at = $2,
+    consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = $3,
+    disabled = consecutive_failures + 1 >= $4::integer
+WHERE id = $5
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size, lease_owner, lease_expires_at, last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days, interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only
+`
+
+type RecordFeedFailureParams struct {
+	Error       string
+	At          sql.NullTime
+	NextFetchAt sql.NullTime
+	MaxFailures int32
+	ID          uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.Error,
+		arg.At,
+		arg.NextFetchAt,
+		arg.MaxFailures,
+		arg.ID,
+	)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -123,20 +419,223 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastBodySize,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.HintedIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.IntervalOverrideSeconds,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.RetentionKeepUnread,
+		&i.PublicOnly,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
-UPDATE feeds SET updated_at=$2, last_fetched_at=$2 WHERE id=$1
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds SET consecutive_failures = 0, next_fetch_at = $2 WHERE id = $1
 `
 
-type MarkFeedFetchedParams struct {
-	ID   uuid.UUID
-	Time time.Time
+type RecordFeedSuccessParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
 }
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.Time)
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, arg.ID, arg.NextFetchAt)
+	return err
+}
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+UPDATE feeds SET lease_owner = '', lease_expires_at = NULL WHERE id = $1 AND lease_owner = $2
+`
+
+type ReleaseFeedLeaseParams struct {
+	ID         uuid.UUID
+	LeaseOwner string
+}
+
+func (q *Queries) ReleaseFeedLease(ctx context.Context, arg ReleaseFeedLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.LeaseOwner)
+	return err
+}
+
+const restoreFeed = `-- name: RestoreFeed :exec
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size,
+    last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days,
+    interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    name = EXCLUDED.name,
+    url = EXCLUDED.url,
+    user_id = EXCLUDED.user_id,
+    last_fetched_at = EXCLUDED.last_fetched_at,
+    etag = EXCLUDED.etag,
+    last_modified = EXCLUDED.last_modified,
+    last_body_size = EXCLUDED.last_body_size,
+    last_error = EXCLUDED.last_error,
+    last_error_at = EXCLUDED.last_error_at,
+    consecutive_failures = EXCLUDED.consecutive_failures,
+    next_fetch_at = EXCLUDED.next_fetch_at,
+    disabled = EXCLUDED.disabled,
+    hinted_interval_seconds = EXCLUDED.hinted_interval_seconds,
+    skip_hours = EXCLUDED.skip_hours,
+    skip_days = EXCLUDED.skip_days,
+    interval_override_seconds = EXCLUDED.interval_override_seconds,
+    retention_max_age_seconds = EXCLUDED.retention_max_age_seconds,
+    retention_max_posts = EXCLUDED.retention_max_posts,
+    retention_keep_unread = EXCLUDED.retention_keep_unread,
+    public_only = EXCLUDED.public_only
+`
+
+type RestoreFeedParams struct {
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Name                    string
+	Url                     string
+	UserID                  uuid.UUID
+	LastFetchedAt           sql.NullTime
+	Etag                    string
+	LastModified            string
+	LastBodySize            int64
+	LastError               string
+	LastErrorAt             sql.NullTime
+	ConsecutiveFailures     int32
+	NextFetchAt             sql.NullTime
+	Disabled                bool
+	HintedIntervalSeconds   int32
+	SkipHours               []int32
+	SkipDays                []string
+	IntervalOverrideSeconds sql.NullInt32
+	RetentionMaxAgeSeconds  sql.NullInt32
+	RetentionMaxPosts       sql.NullInt32
+	RetentionKeepUnread     sql.NullBool
+	PublicOnly              bool
+}
+
+// Inserts or replaces a feed from a backup.  Leases belong to the agg process that took them and aren't restored.
+func (q *Queries) RestoreFeed(ctx context.Context, arg RestoreFeedParams) error {
+	_, err := q.db.ExecContext(ctx, restoreFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.LastFetchedAt,
+		arg.Etag,
+		arg.LastModified,
+		arg.LastBodySize,
+		arg.LastError,
+		arg.LastErrorAt,
+		arg.ConsecutiveFailures,
+		arg.NextFetchAt,
+		arg.Disabled,
+		arg.HintedIntervalSeconds,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+		arg.IntervalOverrideSeconds,
+		arg.RetentionMaxAgeSeconds,
+		arg.RetentionMaxPosts,
+		arg.RetentionKeepUnread,
+		arg.PublicOnly,
+	)
+	return err
+}
+
+const setFeedIntervalOverride = `-- name: SetFeedIntervalOverride :execrows
+UPDATE feeds SET interval_override_seconds = $2, next_fetch_at = NULL WHERE url = $1
+`
+
+type SetFeedIntervalOverrideParams struct {
+	Url                     string
+	IntervalOverrideSeconds sql.NullInt32
+}
+
+func (q *Queries) SetFeedIntervalOverride(ctx context.Context, arg SetFeedIntervalOverrideParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedIntervalOverride, arg.Url, arg.IntervalOverrideSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :execrows
+UPDATE feeds SET retention_max_age_seconds = $2, retention_max_posts = $3,
+    retention_keep_unread = $4
+WHERE url = $1
+`
+
+type SetFeedRetentionParams struct {
+	Url                    string
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+	RetentionKeepUnread    sql.NullBool
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedRetention,
+		arg.Url,
+		arg.RetentionMaxAgeSeconds,
+		arg.RetentionMaxPosts,
+		arg.RetentionKeepUnread,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedCache = `-- name: UpdateFeedCache :exec
+UPDATE feeds SET etag=$2, last_modified=$3, last_body_size=$4 WHERE id=$1
+`
+
+type UpdateFeedCacheParams struct {
+	ID           uuid.UUID
+	Etag         string
+	LastModified string
+	LastBodySize int64
+}
+
+func (q *Queries) UpdateFeedCache(ctx context.Context, arg UpdateFeedCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCache,
+		arg.ID,
+		arg.Etag,
+		arg.LastModified,
+		arg.LastBodySize,
+	)
+	return err
+}
+
+const updateFeedScheduleHints = `-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds SET hinted_interval_seconds = $2, skip_hours = $3, skip_days = $4 WHERE id = $1
+`
+
+type UpdateFeedScheduleHintsParams struct {
+	ID                    uuid.UUID
+	HintedIntervalSeconds int32
+	SkipHours             []int32
+	SkipDays              []string
+}
+
+func (q *Queries) UpdateFeedScheduleHints(ctx context.Context, arg UpdateFeedScheduleHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedScheduleHints,
+		arg.ID,
+		arg.HintedIntervalSeconds,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+	)
 	return err
 }
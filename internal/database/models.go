@@ -11,14 +11,41 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	Name       string
+	TokenHash  string
+	UserID     uuid.UUID
+	LastUsedAt sql.NullTime
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Name                    string
+	Url                     string
+	UserID                  uuid.UUID
+	LastFetchedAt           sql.NullTime
+	Etag                    string
+	LastModified            string
+	LastBodySize            int64
+	LeaseOwner              string
+	LeaseExpiresAt          sql.NullTime
+	LastError               string
+	LastErrorAt             sql.NullTime
+	ConsecutiveFailures     int32
+	NextFetchAt             sql.NullTime
+	Disabled                bool
+	HintedIntervalSeconds   int32
+	SkipHours               []int32
+	SkipDays                []string
+	IntervalOverrideSeconds sql.NullInt32
+	RetentionMaxAgeSeconds  sql.NullInt32
+	RetentionMaxPosts       sql.NullInt32
+	RetentionKeepUnread     sql.NullBool
+	PublicOnly              bool
 }
 
 type FeedFollow struct {
@@ -27,17 +54,41 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Category  string
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  string
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Author       string
+	Categories   []string
+	Guid         string
+	SearchVector interface{}
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PrunedPost struct {
+	FeedID uuid.UUID
+	Guid   string
 }
 
 type User struct {
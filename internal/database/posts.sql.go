@@ -11,12 +11,445 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const adoptLegacyPost = `-- name: AdoptLegacyPost :execrows
+UPDATE posts SET guid = $1
+WHERE feed_id = $2 AND guid = $3 AND url = $3
+    AND NOT EXISTS (SELECT 1 FROM posts taken WHERE taken.feed_id = $2 AND taken.guid = $1)
+`
+
+type AdoptLegacyPostParams struct {
+	Guid   string
+	FeedID uuid.UUID
+	Url    string
+}
+
+// Posts stored before guids were tracked got their url as guid (007_posts.sql).  Moves such a post onto the
+// item's real guid so CreatePost updates it instead of inserting the item a second time.
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const browsePostsByFetched = `-- name: BrowsePostsByFetched :many
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    posts.created_at AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND ($2::text IS NULL OR feeds.url = $2 OR lower(feeds.name) = lower($2))
+    AND ($3::timestamp IS NULL OR posts.created_at >= $3)
+    AND ($4::timestamp IS NULL OR posts.created_at < $4)
+    AND (NOT $5::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest($6::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest($7::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND ($8::timestamp IS NULL OR (posts.created_at, posts.id) < ($8, $9::uuid))
+ORDER BY posts.created_at DESC, posts.id DESC
+LIMIT $10
+`
+
+type BrowsePostsByFetchedParams struct {
+	UserID     uuid.UUID
+	Feed       sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+	UnreadOnly bool
+	Include    []string
+	Exclude    []string
+	CursorAt   sql.NullTime
+	CursorID   uuid.NullUUID
+	Limit      int32
+}
+
+type BrowsePostsByFetchedRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Read        bool
+	SortAt      time.Time
+}
+
+// Keyset paginated posts from the user's followed feeds, most recently fetched first.
+func (q *Queries) BrowsePostsByFetched(ctx context.Context, arg BrowsePostsByFetchedParams) ([]BrowsePostsByFetchedRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsByFetched,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		pq.Array(arg.Include),
+		pq.Array(arg.Exclude),
+		arg.CursorAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsByFetchedRow
+	for rows.Next() {
+		var i BrowsePostsByFetchedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Read,
+			&i.SortAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const browsePostsByFetchedReverse = `-- name: BrowsePostsByFetchedReverse :many
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    posts.created_at AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND ($2::text IS NULL OR feeds.url = $2 OR lower(feeds.name) = lower($2))
+    AND ($3::timestamp IS NULL OR posts.created_at >= $3)
+    AND ($4::timestamp IS NULL OR posts.created_at < $4)
+    AND (NOT $5::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest($6::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest($7::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND ($8::timestamp IS NULL OR (posts.created_at, posts.id) > ($8, $9::uuid))
+ORDER BY posts.created_at ASC, posts.id ASC
+LIMIT $10
+`
+
+type BrowsePostsByFetchedReverseParams struct {
+	UserID     uuid.UUID
+	Feed       sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+	UnreadOnly bool
+	Include    []string
+	Exclude    []string
+	CursorAt   sql.NullTime
+	CursorID   uuid.NullUUID
+	Limit      int32
+}
+
+type BrowsePostsByFetchedReverseRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Read        bool
+	SortAt      time.Time
+}
+
+// Keyset paginated posts from the user's followed feeds, first fetched first.
+func (q *Queries) BrowsePostsByFetchedReverse(ctx context.Context, arg BrowsePostsByFetchedReverseParams) ([]BrowsePostsByFetchedReverseRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsByFetchedReverse,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		pq.Array(arg.Include),
+		pq.Array(arg.Exclude),
+		arg.CursorAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsByFetchedReverseRow
+	for rows.Next() {
+		var i BrowsePostsByFetchedReverseRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Read,
+			&i.SortAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const browsePostsByPublished = `-- name: BrowsePostsByPublished :many
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    COALESCE(posts.published_at, posts.created_at)::timestamp AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND ($2::text IS NULL OR feeds.url = $2 OR lower(feeds.name) = lower($2))
+    AND ($3::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $3)
+    AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $4)
+    AND (NOT $5::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest($6::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest($7::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND ($8::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) < ($8, $9::uuid))
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+LIMIT $10
+`
+
+type BrowsePostsByPublishedParams struct {
+	UserID     uuid.UUID
+	Feed       sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+	UnreadOnly bool
+	Include    []string
+	Exclude    []string
+	CursorAt   sql.NullTime
+	CursorID   uuid.NullUUID
+	Limit      int32
+}
+
+type BrowsePostsByPublishedRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Read        bool
+	SortAt      time.Time
+}
+
+// Keyset paginated posts from the user's followed feeds, newest published first.
+func (q *Queries) BrowsePostsByPublished(ctx context.Context, arg BrowsePostsByPublishedParams) ([]BrowsePostsByPublishedRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsByPublished,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		pq.Array(arg.Include),
+		pq.Array(arg.Exclude),
+		arg.CursorAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsByPublishedRow
+	for rows.Next() {
+		var i BrowsePostsByPublishedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Read,
+			&i.SortAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const browsePostsByPublishedReverse = `-- name: BrowsePostsByPublishedReverse :many
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    COALESCE(posts.published_at, posts.created_at)::timestamp AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND ($2::text IS NULL OR feeds.url = $2 OR lower(feeds.name) = lower($2))
+    AND ($3::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $3)
+    AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $4)
+    AND (NOT $5::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest($6::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest($7::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND ($8::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) > ($8, $9::uuid))
+ORDER BY COALESCE(posts.published_at, posts.created_at) ASC, posts.id ASC
+LIMIT $10
+`
+
+type BrowsePostsByPublishedReverseParams struct {
+	UserID     uuid.UUID
+	Feed       sql.NullString
+	Since      sql.NullTime
+	Until      sql.NullTime
+	UnreadOnly bool
+	Include    []string
+	Exclude    []string
+	CursorAt   sql.NullTime
+	CursorID   uuid.NullUUID
+	Limit      int32
+}
+
+type BrowsePostsByPublishedReverseRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Read        bool
+	SortAt      time.Time
+}
+
+// Keyset paginated posts from the user's followed feeds, oldest published first.
+func (q *Queries) BrowsePostsByPublishedReverse(ctx context.Context, arg BrowsePostsByPublishedReverseParams) ([]BrowsePostsByPublishedReverseRow, error) {
+	rows, err := q.db.QueryContext(ctx, browsePostsByPublishedReverse,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		pq.Array(arg.Include),
+		pq.Array(arg.Exclude),
+		arg.CursorAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BrowsePostsByPublishedReverseRow
+	for rows.Next() {
+		var i BrowsePostsByPublishedReverseRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Read,
+			&i.SortAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countPrunablePosts = `-- name: CountPrunablePosts :one
+SELECT COUNT(*) FROM posts WHERE posts.id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, COALESCE(p.published_at, p.created_at) AS sort_at,
+            ROW_NUMBER() OVER (ORDER BY COALESCE(p.published_at, p.created_at) DESC, p.id DESC) AS position
+        FROM posts p
+        WHERE p.feed_id = $1
+    ) ranked
+    WHERE (($2::timestamp IS NOT NULL AND ranked.sort_at < $2)
+            OR ($3::integer IS NOT NULL AND ranked.position > $3))
+        AND NOT ($4::boolean AND EXISTS (
+            SELECT 1 FROM feed_follows ff
+            WHERE ff.feed_id = $1
+                AND NOT EXISTS (SELECT 1 FROM post_reads r WHERE r.post_id = ranked.id AND r.user_id = ff.user_id)
+        ))
+        AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = ranked.id)
+)
+`
+
+type CountPrunablePostsParams struct {
+	FeedID     uuid.UUID
+	Before     sql.NullTime
+	MaxPosts   sql.NullInt32
+	KeepUnread bool
+}
+
+// The number of posts PrunePosts would delete with the same arguments.
+func (q *Queries) CountPrunablePosts(ctx context.Context, arg CountPrunablePostsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPrunablePosts,
+		arg.FeedID,
+		arg.Before,
+		arg.MaxPosts,
+		arg.KeepUnread,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+SELECT $1::uuid, $2::timestamp, $3::timestamp, $4::text, $5::text, $6::text, $7::timestamp, $8::uuid, $9::text, $10::text[], $11::text
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = $8 AND pp.guid = $11)
+ON CONFLICT (feed_id, guid) DO UPDATE SET
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+    OR posts.url IS DISTINCT FROM EXCLUDED.url
+    OR posts.description IS DISTINCT FROM EXCLUDED.description
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid, (xmax = 0) AS inserted
 `
 
 type CreatePostParams struct {
@@ -28,9 +461,28 @@ type CreatePostParams struct {
 	Description string
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+}
+
+type CreatePostRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+	Inserted    bool
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+// Items whose posts were pruned aren't stored again, they return no row like an unchanged one.
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (CreatePostRow, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.ID,
 		arg.CreatedAt,
@@ -40,8 +492,11 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.Guid,
 	)
-	var i Post
+	var i CreatePostRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
@@ -51,23 +506,45 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.Guid,
+		&i.Inserted,
 	)
 	return i, err
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, title, url, description, published_at, posts.feed_id, feed_follows.id, feed_follows.created_at, feed_follows.updated_at, user_id, feed_follows.feed_id FROM posts 
+const getFeedPostCadence = `-- name: GetFeedPostCadence :one
+SELECT COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)) / NULLIF(COUNT(*) - 1, 0), 0)::float8 AS avg_gap_seconds
+FROM (
+    SELECT published_at FROM posts
+    WHERE feed_id = $1 AND published_at IS NOT NULL
+    ORDER BY published_at DESC LIMIT 20
+) recent
+`
+
+// Average gap in seconds between the feed's most recent posts, 0 when there isn't enough history.
+func (q *Queries) GetFeedPostCadence(ctx context.Context, feedID uuid.UUID) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPostCadence, feedID)
+	var avg_gap_seconds float64
+	err := row.Scan(&avg_gap_seconds)
+	return avg_gap_seconds, err
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.author, posts.categories, posts.guid, feeds.name AS feed_name, feeds.url AS feed_url, (post_reads.read_at IS NOT NULL)::boolean AS read FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1 
-ORDER BY posts.updated_at DESC LIMIT $2
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2
 `
 
-type GetPostsForUserParams struct {
+type GetPostForUserParams struct {
+	ID     uuid.UUID
 	UserID uuid.UUID
-	Limit  int32
 }
 
-type GetPostsForUserRow struct {
+type GetPostForUserRow struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -76,22 +553,74 @@ type GetPostsForUserRow struct {
 	Description string
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
-	ID_2        uuid.UUID
-	CreatedAt_2 time.Time
-	UpdatedAt_2 time.Time
-	UserID      uuid.UUID
-	FeedID_2    uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+	FeedName    string
+	FeedUrl     string
+	Read        bool
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+// A single post, only if it's in one of the user's followed feeds.
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
+	var i GetPostForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.Guid,
+		&i.FeedName,
+		&i.FeedUrl,
+		&i.Read,
+	)
+	return i, err
+}
+
+const listPosts = `-- name: ListPosts :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid
+FROM posts
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListPostsParams struct {
+	After uuid.UUID
+	Limit int32
+}
+
+type ListPostsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+}
+
+// Pages through every post in id order, for backups.
+func (q *Queries) ListPosts(ctx context.Context, arg ListPostsParams) ([]ListPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPosts, arg.After, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetPostsForUserRow
+	var items []ListPostsRow
 	for rows.Next() {
-		var i GetPostsForUserRow
+		var i ListPostsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -101,11 +630,316 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
-			&i.ID_2,
-			&i.CreatedAt_2,
-			&i.UpdatedAt_2,
-			&i.UserID,
-			&i.FeedID_2,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Guid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostsForUser = `-- name: ListPostsForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.author, posts.categories, posts.guid, feeds.name AS feed_name, feeds.url AS feed_url, (post_reads.read_at IS NOT NULL)::boolean AS read FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+    AND ($2::uuid IS NULL OR posts.feed_id = $2)
+    AND ($3::timestamp IS NULL OR posts.published_at >= $3)
+    AND ($4::timestamp IS NULL OR posts.published_at < $4)
+    AND (NOT $5::boolean OR post_reads.post_id IS NULL)
+ORDER BY posts.published_at DESC NULLS LAST, posts.id
+LIMIT $6 OFFSET $7
+`
+
+type ListPostsForUserParams struct {
+	UserID     uuid.UUID
+	FeedID     uuid.NullUUID
+	Since      sql.NullTime
+	Until      sql.NullTime
+	UnreadOnly bool
+	Limit      int32
+	Offset     int32
+}
+
+type ListPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+	FeedName    string
+	FeedUrl     string
+	Read        bool
+}
+
+// Posts from the feeds a user follows, newest first, with optional feed and published date filters.
+func (q *Queries) ListPostsForUser(ctx context.Context, arg ListPostsForUserParams) ([]ListPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Since,
+		arg.Until,
+		arg.UnreadOnly,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPostsForUserRow
+	for rows.Next() {
+		var i ListPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Guid,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Read,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPrunedPosts = `-- name: ListPrunedPosts :many
+SELECT feed_id, guid FROM pruned_posts ORDER BY feed_id, guid
+`
+
+func (q *Queries) ListPrunedPosts(ctx context.Context) ([]PrunedPost, error) {
+	rows, err := q.db.QueryContext(ctx, listPrunedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrunedPost
+	for rows.Next() {
+		var i PrunedPost
+		if err := rows.Scan(&i.FeedID, &i.Guid); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const prunePosts = `-- name: PrunePosts :one
+WITH pruned AS (
+    DELETE FROM posts WHERE posts.id IN (
+        SELECT ranked.id FROM (
+            SELECT p.id, COALESCE(p.published_at, p.created_at) AS sort_at,
+                ROW_NUMBER() OVER (ORDER BY COALESCE(p.published_at, p.created_at) DESC, p.id DESC) AS position
+            FROM posts p
+            WHERE p.feed_id = $1
+        ) ranked
+        WHERE (($2::timestamp IS NOT NULL AND ranked.sort_at < $2)
+                OR ($3::integer IS NOT NULL AND ranked.position > $3))
+            AND NOT ($4::boolean AND EXISTS (
+                SELECT 1 FROM feed_follows ff
+                WHERE ff.feed_id = $1
+                    AND NOT EXISTS (SELECT 1 FROM post_reads r WHERE r.post_id = ranked.id AND r.user_id = ff.user_id)
+            ))
+            AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = ranked.id)
+    )
+    RETURNING posts.feed_id, posts.guid
+), tombstones AS (
+    INSERT INTO pruned_posts (feed_id, guid)
+    SELECT feed_id, guid FROM pruned
+    ON CONFLICT DO NOTHING
+)
+SELECT COUNT(*) FROM pruned
+`
+
+type PrunePostsParams struct {
+	FeedID     uuid.UUID
+	Before     sql.NullTime
+	MaxPosts   sql.NullInt32
+	KeepUnread bool
+}
+
+// Deletes the feed's posts published (or fetched, without a date) before before, and the ones past the newest
+// max_posts.  Kept posts still count towards max_posts.  Unread means some follower of the feed hasn't read it.
+// Posts anyone starred are never deleted, the cascade would take their stars with them.  The deleted
+// posts' guids go in pruned_posts so CreatePost doesn't bring them back while they're still in the feed.
+func (q *Queries) PrunePosts(ctx context.Context, arg PrunePostsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, prunePosts,
+		arg.FeedID,
+		arg.Before,
+		arg.MaxPosts,
+		arg.KeepUnread,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const restorePost = `-- name: RestorePost :exec
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    feed_id = EXCLUDED.feed_id,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    guid = EXCLUDED.guid
+`
+
+type RestorePostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      string
+	Categories  []string
+	Guid        string
+}
+
+func (q *Queries) RestorePost(ctx context.Context, arg RestorePostParams) error {
+	_, err := q.db.ExecContext(ctx, restorePost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.Guid,
+	)
+	return err
+}
+
+const restorePrunedPost = `-- name: RestorePrunedPost :exec
+INSERT INTO pruned_posts (feed_id, guid)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type RestorePrunedPostParams struct {
+	FeedID uuid.UUID
+	Guid   string
+}
+
+func (q *Queries) RestorePrunedPost(ctx context.Context, arg RestorePrunedPostParams) error {
+	_, err := q.db.ExecContext(ctx, restorePrunedPost, arg.FeedID, arg.Guid)
+	return err
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT posts.id, posts.title, posts.url, posts.published_at, feeds.name AS feed_name,
+    ts_rank(posts.search_vector, q) AS rank,
+    ts_headline('english', posts.title, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', HighlightAll=true')::text AS title_highlight,
+    ts_headline('english', posts.description, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxFragments=2, MaxWords=25, MinWords=8')::text AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', $1) AS q
+WHERE feed_follows.user_id = $2
+    AND posts.search_vector @@ q
+    AND ($3::text IS NULL OR feeds.url = $3 OR lower(feeds.name) = lower($3))
+    AND ($4::timestamp IS NULL OR posts.published_at < $4)
+    AND ($5::timestamp IS NULL OR posts.published_at >= $5)
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT $6
+`
+
+type SearchPostsForUserParams struct {
+	Query  string
+	UserID uuid.UUID
+	Feed   sql.NullString
+	Before sql.NullTime
+	After  sql.NullTime
+	Limit  int32
+}
+
+type SearchPostsForUserRow struct {
+	ID             uuid.UUID
+	Title          string
+	Url            string
+	PublishedAt    sql.NullTime
+	FeedName       string
+	Rank           float32
+	TitleHighlight string
+	Snippet        string
+}
+
+// Full text search over the user's followed feeds, best match first.  query uses websearch syntax so
+// "quoted phrases", OR and -word work.  Matches are wrapped in \x01 \x02 in the highlight and snippet.
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.Feed,
+		arg.Before,
+		arg.After,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.TitleHighlight,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
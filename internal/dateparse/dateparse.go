@@ -0,0 +1,310 @@
+package dateparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrEmpty is returned for a blank date, so callers can tell a missing date from a malformed one.
+var ErrEmpty = errors.New("empty date")
+
+// isoLayouts are tried first since that's what Atom, JSON Feed and dc:date are supposed to use.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02Z07:00",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102T150405",
+	"20060102",
+}
+
+// Weekday-less variants of time.UnixDate, time.RubyDate and time.ANSIC.
+var unixLayouts = []string{
+	"Jan _2 15:04:05 MST 2006",
+	"Jan _2 15:04:05 -0700 2006",
+	"Jan _2 15:04:05 2006",
+}
+
+var dateParts = []string{
+	"2006-01-02",
+	"2 Jan 2006",
+	"2 Jan 06",
+	"2 January 2006",
+	"2 January 06",
+	"2-Jan-2006",
+	"2-Jan-06",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2006/01/02",
+	"2006.01.02",
+	"1/2/2006",
+	"1/2/06",
+	"2.1.2006",
+	"2.1.06",
+}
+
+var timeParts = []string{
+	"15:04:05.999999999",
+	"15:04:05",
+	"15:04",
+	"3:04:05 PM",
+	"3:04 PM",
+	"3:04:05PM",
+	"3:04PM",
+}
+
+var zoneParts = []string{
+	" -0700",
+	" -07:00",
+	" -07",
+	" MST",
+	"",
+}
+
+var layouts = buildLayouts()
+
+func buildLayouts() []string {
+	all := append([]string{}, isoLayouts...)
+	all = append(all, unixLayouts...)
+	for _, date := range dateParts {
+		for _, clock := range timeParts {
+			for _, zone := range zoneParts {
+				all = append(all, date+" "+clock+zone)
+			}
+		}
+		all = append(all, date)
+	}
+	return all
+}
+
+// Abbreviations Go can't resolve on its own.  time.Parse accepts any abbreviation for the MST
+// layout but quietly gives unknown ones a zero offset, so they get rewritten to numeric offsets.
+// Ambiguous ones (IST, BST, CST) use the meaning most common in english language feeds.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"IST":  "+0530",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"PKT":  "+0500",
+	"ICT":  "+0700",
+	"WIB":  "+0700",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"AST":  "-0400",
+	"ADT":  "-0300",
+	"NST":  "-0330",
+	"NDT":  "-0230",
+	"BRT":  "-0300",
+	"ART":  "-0300",
+	"SGT":  "+0800",
+	"HKT":  "+0800",
+	"AWST": "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"ACST": "+0930",
+	"ACDT": "+1030",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// Localized month names and abbreviations (fr, de, es, it, pt, nl) mapped to the english short form.
+var monthNames = map[string]string{
+	"janvier": "Jan", "janv": "Jan", "januar": "Jan", "jänner": "Jan", "jän": "Jan", "enero": "Jan", "ene": "Jan",
+	"gennaio": "Jan", "gen": "Jan", "janeiro": "Jan", "januari": "Jan",
+	"février": "Feb", "fevrier": "Feb", "févr": "Feb", "fevr": "Feb", "fév": "Feb", "februar": "Feb", "febrero": "Feb",
+	"febbraio": "Feb", "fevereiro": "Feb", "fev": "Feb", "februari": "Feb",
+	"mars": "Mar", "märz": "Mar", "mär": "Mar", "marzo": "Mar", "março": "Mar", "marco": "Mar", "maart": "Mar", "mrt": "Mar",
+	"avril": "Apr", "avr": "Apr", "abril": "Apr", "abr": "Apr", "aprile": "Apr",
+	"mai": "May", "mayo": "May", "maggio": "May", "mag": "May", "maio": "May", "mei": "May",
+	"juin": "Jun", "juni": "Jun", "junio": "Jun", "giugno": "Jun", "giu": "Jun", "junho": "Jun",
+	"juillet": "Jul", "juil": "Jul", "juli": "Jul", "julio": "Jul", "luglio": "Jul", "lug": "Jul", "julho": "Jul",
+	"août": "Aug", "aout": "Aug", "agosto": "Aug", "ago": "Aug", "augustus": "Aug",
+	"sept": "Sep", "septembre": "Sep", "septiembre": "Sep", "setiembre": "Sep", "settembre": "Sep", "set": "Sep", "setembro": "Sep",
+	"octobre": "Oct", "oktober": "Oct", "okt": "Oct", "octubre": "Oct", "ottobre": "Oct", "ott": "Oct", "outubro": "Oct", "out": "Oct",
+	"novembre": "Nov", "noviembre": "Nov", "novembro": "Nov",
+	"décembre": "Dec", "decembre": "Dec", "déc": "Dec", "dezember": "Dec", "dez": "Dec", "diciembre": "Dec", "dic": "Dec",
+	"dicembre": "Dec", "dezembro": "Dec",
+}
+
+// Leading weekdays are dropped.  Ambiguous abbreviations like the spanish "mar" (martes) are
+// left out since they collide with month names.
+var weekdayNames = map[string]bool{
+	"mon": true, "monday": true, "tue": true, "tues": true, "tuesday": true, "wed": true, "wednesday": true,
+	"thu": true, "thur": true, "thurs": true, "thursday": true, "fri": true, "friday": true,
+	"sat": true, "saturday": true, "sun": true, "sunday": true,
+	"lun": true, "lundi": true, "mardi": true, "mer": true, "mercredi": true, "jeu": true, "jeudi": true,
+	"ven": true, "vendredi": true, "sam": true, "samedi": true, "dim": true, "dimanche": true,
+	"mo": true, "montag": true, "di": true, "dienstag": true, "mi": true, "mittwoch": true, "do": true,
+	"donnerstag": true, "fr": true, "freitag": true, "sa": true, "samstag": true, "so": true, "sonntag": true,
+	"lunes": true, "martes": true, "miércoles": true, "miercoles": true, "jueves": true, "viernes": true,
+	"sábado": true, "sabado": true, "domingo": true, "mié": true, "mie": true, "jue": true, "vie": true, "sáb": true, "dom": true,
+	"seg": true, "ter": true, "qua": true, "qui": true, "sex": true, "sab": true,
+	"gio": true,
+	"ma":  true, "wo": true, "vr": true, "za": true, "zo": true,
+	"lunedì": true, "martedì": true, "mercoledì": true, "giovedì": true, "venerdì": true, "sabato": true, "domenica": true,
+	"maandag": true, "dinsdag": true, "woensdag": true, "donderdag": true, "vrijdag": true, "zaterdag": true, "zondag": true,
+}
+
+// Filler words in dates like "2 de enero de 2006".
+var fillerWords = map[string]bool{
+	"de": true, "del": true, "at": true, "à": true, "um": true, "le": true,
+}
+
+var (
+	// GMT+2, UTC-05:30 ...
+	reZoneOffset = regexp.MustCompile(`^(?:GMT|UTC|UT)([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	// 1st, 2nd, 23rd, 4th
+	reOrdinal = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)(,?)$`)
+)
+
+// Parse turns a feed supplied date string into a time.Time in UTC.  Dates without a zone are taken as UTC.
+// The result is always converted since TIMESTAMP columns drop the offset.
+func Parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, ErrEmpty
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	normalized := normalize(value)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format: %q", value)
+}
+
+// ParseFirst returns the first of values that parses, skipping empty ones.
+// The error from the first non-empty value is returned if none of them parse.
+func ParseFirst(values ...string) (time.Time, error) {
+	var firstErr error
+	for _, value := range values {
+		t, err := Parse(value)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil && !errors.Is(err, ErrEmpty) {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return time.Time{}, ErrEmpty
+	}
+	return time.Time{}, firstErr
+}
+
+// normalize strips weekdays and filler words, translates month names and rewrites zone
+// abbreviations so the result can be matched against the layout table.
+func normalize(value string) string {
+	value = strings.NewReplacer("(", " ", ")", " ", " ", " ").Replace(value)
+	fields := strings.Fields(value)
+
+	out := make([]string, 0, len(fields))
+	for i, field := range fields {
+		word := strings.ToLower(strings.TrimRight(field, ".,"))
+
+		if i == 0 && weekdayNames[word] {
+			continue
+		}
+		if fillerWords[word] {
+			continue
+		}
+		if month, ok := monthNames[word]; ok {
+			out = append(out, month+trailingComma(field))
+			continue
+		}
+		if m := reOrdinal.FindStringSubmatch(strings.ToLower(field)); m != nil {
+			out = append(out, m[1]+m[2])
+			continue
+		}
+		if m := reZoneOffset.FindStringSubmatch(strings.ToUpper(field)); m != nil && i > 0 {
+			hours, minutes := m[2], m[3]
+			if len(hours) == 1 {
+				hours = "0" + hours
+			}
+			if minutes == "" {
+				minutes = "00"
+			}
+			out = append(out, m[1]+hours+minutes)
+			continue
+		}
+		if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok && i > 0 {
+			// a trailing "GMT" after an explicit offset is redundant
+			if len(out) > 0 && isNumericOffset(out[len(out)-1]) {
+				continue
+			}
+			out = append(out, offset)
+			continue
+		}
+		out = append(out, field)
+	}
+	return strings.Join(out, " ")
+}
+
+func trailingComma(field string) string {
+	if strings.HasSuffix(field, ",") {
+		return ","
+	}
+	return ""
+}
+
+func isNumericOffset(s string) bool {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	est := time.FixedZone("", -5*60*60)
+	edt := time.FixedZone("", -4*60*60)
+	pst := time.FixedZone("", -8*60*60)
+	cest := time.FixedZone("", 2*60*60)
+	ist := time.FixedZone("", 5*60*60+30*60)
+
+	cases := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		// RSS 2.0 / RFC 822 family
+		{"rfc1123", "Mon, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"rfc1123z", "Mon, 02 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"rfc822 two digit year", "Mon, 02 Jan 06 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"rfc822 no seconds", "02 Jan 06 15:04 EST", time.Date(2006, 1, 2, 15, 4, 0, 0, est)},
+		{"missing weekday", "2 Jan 2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"single digit day", "Tue, 3 Jun 2008 11:05:30 GMT", time.Date(2008, 6, 3, 11, 5, 30, 0, time.UTC)},
+		{"named zone edt", "Wed, 10 Jul 2024 09:00:00 EDT", time.Date(2024, 7, 10, 9, 0, 0, 0, edt)},
+		{"named zone pst", "Fri, 01 Dec 2023 23:59:59 PST", time.Date(2023, 12, 1, 23, 59, 59, 0, pst)},
+		{"named zone cest", "Mon, 15 Apr 2024 08:30:00 CEST", time.Date(2024, 4, 15, 8, 30, 0, 0, cest)},
+		{"named zone ist", "15 Apr 2024 08:30:00 IST", time.Date(2024, 4, 15, 8, 30, 0, 0, ist)},
+		{"full weekday and month", "Monday, 02 January 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"wrong weekday", "Sun, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"offset with colon", "Mon, 02 Jan 2006 15:04:05 -05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"gmt offset", "Mon, 02 Jan 2006 15:04:05 GMT-5", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"offset then zone comment", "Mon, 02 Jan 2006 15:04:05 -0500 (EST)", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"no zone", "Mon, 02 Jan 2006 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"dashed date", "02-Jan-2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"sept abbreviation", "Thu, 14 Sept 2023 10:00:00 GMT", time.Date(2023, 9, 14, 10, 0, 0, 0, time.UTC)},
+		{"extra whitespace", "  Mon,  02 Jan 2006   15:04:05 GMT ", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+
+		// RFC 3339 / ISO 8601
+		{"rfc3339", "2006-01-02T15:04:05Z", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"rfc3339 offset", "2006-01-02T15:04:05-05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"rfc3339 nano", "2006-01-02T15:04:05.123456Z", time.Date(2006, 1, 2, 15, 4, 5, 123456000, time.UTC)},
+		{"iso compact offset", "2006-01-02T15:04:05-0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"iso no zone", "2006-01-02T15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"iso no seconds", "2006-01-02T15:04", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"iso space separated", "2006-01-02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"iso space separated offset", "2006-01-02 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"iso space named zone", "2006-01-02 15:04:05 EST", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"iso date only", "2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"iso basic", "20060102T150405Z", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"dc date with offset", "2003-12-13T18:30:02+01:00", time.Date(2003, 12, 13, 18, 30, 2, 0, time.FixedZone("", 60*60))},
+
+		// Misc formats seen in the wild
+		{"unix date", "Mon Jan  2 15:04:05 MST 2006", time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))},
+		{"ruby date", "Mon Jan 02 15:04:05 -0500 2006", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"ansic", "Mon Jan  2 15:04:05 2006", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"us long", "January 2, 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"us long with time", "January 2, 2006 3:04 PM", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"us short", "Jan 2, 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"ordinal day", "January 2nd, 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"slashes ymd", "2006/01/02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"slashes us", "1/2/2006 15:04", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"european dots", "02.01.2006 15:04", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+
+		// Localized month and weekday names
+		{"french", "lun., 02 janv. 2006 15:04:05 +0100", time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", 60*60))},
+		{"french accents", "12 février 2024 08:00:00 GMT", time.Date(2024, 2, 12, 8, 0, 0, 0, time.UTC)},
+		{"german", "Di, 05 März 2024 10:00:00 +0100", time.Date(2024, 3, 5, 10, 0, 0, 0, time.FixedZone("", 60*60))},
+		{"german long", "Montag, 2. Oktober 2023 12:00:00 MESZ", time.Time{}},
+		{"spanish", "2 de enero de 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"spanish abbreviated", "mié, 03 dic 2025 07:15:00 +0000", time.Date(2025, 12, 3, 7, 15, 0, 0, time.UTC)},
+		{"italian", "ven, 24 mag 2024 18:45:00 +0200", time.Date(2024, 5, 24, 18, 45, 0, 0, cest)},
+		{"portuguese", "Qua, 08 Out 2025 09:00:00 -0300", time.Date(2025, 10, 8, 9, 0, 0, 0, time.FixedZone("", -3*60*60))},
+		{"dutch", "vr, 1 mrt 2024 09:00:00 +0100", time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("", 60*60))},
+		{"offset converted to utc", "Mon, 01 Jan 2024 10:00 -0800", time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.input)
+			if tc.want.IsZero() {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %v, expected an error", tc.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tc.input, err)
+			}
+			if !got.Equal(tc.want) {
+				t.Fatalf("Parse(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+			if got.Location() != time.UTC {
+				t.Fatalf("Parse(%q) = %v, want it in UTC", tc.input, got)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{"", "   ", "yesterday", "not a date", "32 Jan 2006", "2006-13-45"} {
+		if got, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %v, expected an error", input, got)
+		}
+	}
+}
+
+func TestParseFirst(t *testing.T) {
+	want := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	got, err := ParseFirst("", "garbage", "2006-01-02T15:04:05Z")
+	if err != nil {
+		t.Fatalf("ParseFirst returned error: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Fatalf("ParseFirst = %v, want %v", got, want)
+	}
+
+	if _, err := ParseFirst("", " "); err != ErrEmpty {
+		t.Fatalf("ParseFirst with only empty values returned %v, want ErrEmpty", err)
+	}
+	if _, err := ParseFirst("", "garbage"); err == nil || err == ErrEmpty {
+		t.Fatalf("ParseFirst with an unparseable value returned %v, want a format error", err)
+	}
+}
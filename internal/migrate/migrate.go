@@ -0,0 +1,409 @@
+// Package migrate applies goose style migrations (NNN_name.sql files with -- +goose Up and -- +goose Down
+// sections) from an fs.FS and records them in a schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoneApplied = errors.New("no migrations have been applied")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied.  AppliedAt is nil for pending migrations.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations in dir of fsys.
+func New(db *sql.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads and sorts the .sql files in dir.  Every file needs a numeric version prefix and an Up section.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+	var migrations []Migration
+	seen := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %v doesn't start with a version number", entry.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %v and %v have the same version", other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %v: %w", entry.Name(), err)
+		}
+		up, down, err := parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("migration %v: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(entry.Name(), ".sql"),
+			Up:      up,
+			Down:    down,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parse splits a migration into its Up and Down sections.  Statement markers are only comments to us,
+// the whole section is sent in one Exec.
+func parse(src string) (up, down string, err error) {
+	var section *strings.Builder
+	var upSQL, downSQL strings.Builder
+	for _, line := range strings.SplitAfter(src, "\n") {
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			section = &upSQL
+			continue
+		case "-- +goose Down":
+			section = &downSQL
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+		} else if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			return "", "", errors.New("sql before -- +goose Up")
+		}
+	}
+	up, down = strings.TrimSpace(upSQL.String()), strings.TrimSpace(downSQL.String())
+	if up == "" {
+		return "", "", errors.New("no -- +goose Up section")
+	}
+	return up, down, nil
+}
+
+// Migrations returns the loaded migrations, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest is the version of the newest migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version is the newest applied migration, 0 for an empty database.  It doesn't create anything.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Pending lists the migrations that haven't been applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction, and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range pending {
+		if err := m.run(ctx, migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the newest applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return Migration{}, err
+	}
+	migration, err := m.newestApplied(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	if err := m.run(ctx, migration, false); err != nil {
+		return Migration{}, err
+	}
+	return migration, nil
+}
+
+// Redo rolls back the newest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	migration, err := m.Down(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	if err := m.run(ctx, migration, true); err != nil {
+		return Migration{}, err
+	}
+	return migration, nil
+}
+
+// Baseline records every migration up to version as applied without running it, for databases that were
+// set up by hand.
+func (m *Migrator) Baseline(ctx context.Context, version int64) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, migration := range pending {
+		if migration.Version > version {
+			break
+		}
+		if _, err := m.db.ExecContext(ctx, insertVersion, migration.Version, migration.Name, time.Now().UTC()); err != nil {
+			return n, fmt.Errorf("error recording migration %v: %w", migration.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (m *Migrator) newestApplied(ctx context.Context) (Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			return m.migrations[i], nil
+		}
+	}
+	return Migration{}, ErrNoneApplied
+}
+
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	direction, script := "up", migration.Up
+	if !up {
+		direction, script = "down", migration.Down
+		if script == "" {
+			return fmt.Errorf("migration %v has no down section", migration.Name)
+		}
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("error running %v %v: %w", migration.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, insertVersion, migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording migration %v: %w", migration.Name, err)
+	}
+	return tx.Commit()
+}
+
+const insertVersion = "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"
+
+// applied maps applied versions to when they were applied.  A database without schema_migrations has
+// nothing applied, unless goose set it up, then goose's record is used.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error checking for schema_migrations: %w", err)
+	}
+	if !exists {
+		return m.gooseApplied(ctx)
+	}
+	applied := map[int64]time.Time{}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// gooseApplied reads goose_db_version, goose keeps a row per up and down with is_applied telling them apart.
+func (m *Migrator) gooseApplied(ctx context.Context) (map[int64]time.Time, error) {
+	applied := map[int64]time.Time{}
+	if exists, err := m.tableExists(ctx, "goose_db_version"); err != nil || !exists {
+		return applied, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading goose_db_version: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var at time.Time
+		if err := rows.Scan(&version, &isApplied, &at); err != nil {
+			return nil, err
+		}
+		// Version 0 is the row goose creates with its table
+		if version == 0 {
+			continue
+		}
+		if isApplied {
+			applied[version] = at
+		} else {
+			delete(applied, version)
+		}
+	}
+	return applied, rows.Err()
+}
+
+// tableExists asks by selecting from the table so it works on any database with a SQL front end.
+// Only an undefined table error means it's missing, anything else is returned.
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	// A failed statement aborts a postgres transaction, this one is only ever rolled back
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, "SELECT 1 FROM "+table+" LIMIT 1")
+	if isUndefinedTable(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rows.Close()
+	return true, nil
+}
+
+// isUndefinedTable recognizes postgres' undefined_table (42P01) by its SQLSTATE, which lib/pq and pgx
+// errors both report, and sqlite's "no such table" message, without importing either driver.
+func isUndefinedTable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState() == "42P01"
+	}
+	return strings.HasPrefix(err.Error(), "no such table: ")
+}
+
+// ensureTable creates schema_migrations, carrying over what goose had applied.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return fmt.Errorf("error checking for schema_migrations: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	previous, err := m.gooseApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	for _, migration := range m.migrations {
+		if at, ok := previous[migration.Version]; ok {
+			if _, err := tx.ExecContext(ctx, insertVersion, migration.Version, migration.Name, at.UTC()); err != nil {
+				return fmt.Errorf("error recording migration %v: %w", migration.Name, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/002_feeds.sql": {Data: []byte("-- +goose Up\nCREATE TABLE feeds(id UUID);\n\n-- +goose Down\nDROP TABLE feeds;\n")},
+		"schema/001_users.sql": {Data: []byte("-- a comment before the sections is fine\n-- +goose Up\nCREATE TABLE users(id UUID);\nCREATE INDEX users_idx ON users(id);\n-- +goose Down\nDROP TABLE users;")},
+		"schema/010_posts.sql": {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE posts(id UUID);\n-- +goose StatementEnd\n")},
+		"schema/README.md":     {Data: []byte("not a migration")},
+	}
+	migrations, err := Load(fsys, "schema")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "001_users", Up: "CREATE TABLE users(id UUID);\nCREATE INDEX users_idx ON users(id);", Down: "DROP TABLE users;"},
+		{Version: 2, Name: "002_feeds", Up: "CREATE TABLE feeds(id UUID);", Down: "DROP TABLE feeds;"},
+		{Version: 10, Name: "010_posts", Up: "-- +goose StatementBegin\nCREATE TABLE posts(id UUID);\n-- +goose StatementEnd"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %v migrations, want %v", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %v:\ngot  %#v\nwant %#v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"no version", fstest.MapFS{"s/users.sql": {Data: []byte("-- +goose Up\nSELECT 1;")}}, "version number"},
+		{"duplicate version", fstest.MapFS{
+			"s/001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;")},
+			"s/001_b.sql": {Data: []byte("-- +goose Up\nSELECT 1;")},
+		}, "same version"},
+		{"no up", fstest.MapFS{"s/001_a.sql": {Data: []byte("-- +goose Down\nSELECT 1;")}}, "no -- +goose Up"},
+		{"sql outside a section", fstest.MapFS{"s/001_a.sql": {Data: []byte("SELECT 1;\n-- +goose Up\nSELECT 1;")}}, "before -- +goose Up"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Load(c.files, "s")
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("got error %v, want one containing %q", err, c.want)
+			}
+		})
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsUndefinedTable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{sqlStateError("42P01"), true},
+		{fmt.Errorf("wrapped: %w", sqlStateError("42P01")), true},
+		// Permission denied, connection failure, serialization failure
+		{sqlStateError("42501"), false},
+		{sqlStateError("08006"), false},
+		{sqlStateError("40001"), false},
+		{errors.New("no such table: schema_migrations"), true},
+		{errors.New("database is locked"), false},
+		{errors.New("driver: bad connection"), false},
+	}
+	for _, c := range cases {
+		if got := isUndefinedTable(c.err); got != c.want {
+			t.Errorf("isUndefinedTable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
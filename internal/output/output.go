@@ -0,0 +1,252 @@
+// Package output writes command results in machine readable formats.  Records are structs, their json
+// tags name the fields in every format so a field is called the same thing in json, csv and yaml.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+type Format string
+
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+	CSV   Format = "csv"
+	YAML  Format = "yaml"
+	Table Format = "table"
+)
+
+var Formats = []Format{Text, JSON, JSONL, CSV, YAML, Table}
+
+// tableCellLen keeps long descriptions from pushing every other column off the screen.
+const tableCellLen = 60
+
+// String and Set make *Format a flag.Value that rejects unknown formats.
+func (f *Format) String() string {
+	if *f == "" {
+		return string(Text)
+	}
+	return string(*f)
+}
+
+func (f *Format) Set(value string) error {
+	for _, format := range Formats {
+		if Format(value) == format {
+			*f = format
+			return nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, format := range Formats {
+		names[i] = string(format)
+	}
+	return fmt.Errorf("unknown output format %q, expected %v", value, strings.Join(names, ", "))
+}
+
+// IsText reports whether the command should print its usual human readable output.
+func (f Format) IsText() bool {
+	return f == "" || f == Text
+}
+
+// Write writes v, a slice of records or a single record, to w.  A single record is written as an object in
+// json and yaml and as one row elsewhere.  Text is left to the command since every command prints it differently.
+func Write(w io.Writer, format Format, v any) error {
+	val := reflect.ValueOf(v)
+	single := val.Kind() == reflect.Struct
+	if !single && val.Kind() != reflect.Slice {
+		return fmt.Errorf("output: can't write %T", v)
+	}
+	elem := val.Type()
+	if !single {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("output: records must be structs, not %v", elem)
+	}
+	fields := recordFields(elem)
+
+	records := []reflect.Value{val}
+	if !single {
+		records = make([]reflect.Value, val.Len())
+		for i := range records {
+			records[i] = val.Index(i)
+		}
+	}
+
+	switch format {
+	case JSON:
+		if !single && val.IsNil() {
+			v = []struct{}{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(v)
+	case JSONL:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		for _, record := range records {
+			if err := encoder.Encode(record.Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case YAML:
+		return writeYAML(w, fields, records, single)
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(fieldNames(fields)); err != nil {
+			return err
+		}
+		for _, record := range records {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = cell(record.Field(field.index))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case Table:
+		var buf bytes.Buffer
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		header := fieldNames(fields)
+		for i := range header {
+			header[i] = strings.ToUpper(header[i])
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, record := range records {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = tableCell(cell(record.Field(field.index)))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		// tabwriter pads empty trailing cells
+		for _, line := range strings.SplitAfter(buf.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			if _, err := io.WriteString(w, strings.TrimRight(line, " \n")+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Text, "":
+		return errors.New("output: text output is written by the command")
+	default:
+		return fmt.Errorf("output: unknown format %q", format)
+	}
+}
+
+type recordField struct {
+	name  string
+	index int
+}
+
+// recordFields lists the exported fields of t named by their json tags, skipping fields tagged "-".
+func recordFields(t reflect.Type) []recordField {
+	var fields []recordField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, recordField{name: name, index: i})
+	}
+	return fields
+}
+
+func fieldNames(fields []recordField) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.name
+	}
+	return names
+}
+
+// cell flattens a field for csv and table.  Nil is empty, times are RFC 3339 and lists are joined with ;.
+func cell(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case fmt.Stringer:
+		return x.String()
+	case []string:
+		return strings.Join(x, ";")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func tableCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= tableCellLen {
+		return s
+	}
+	return string([]rune(s)[:tableCellLen-1]) + "…"
+}
+
+// writeYAML writes every value in JSON form, which is valid YAML, so strings never need YAML's quoting rules.
+func writeYAML(w io.Writer, fields []recordField, records []reflect.Value, single bool) error {
+	var buf bytes.Buffer
+	if !single && len(records) == 0 {
+		buf.WriteString("[]\n")
+	}
+	for _, record := range records {
+		for i, field := range fields {
+			value, err := yamlValue(record.Field(field.index).Interface())
+			if err != nil {
+				return fmt.Errorf("output: field %v: %w", field.name, err)
+			}
+			switch {
+			case single:
+			case i == 0:
+				buf.WriteString("- ")
+			default:
+				buf.WriteString("  ")
+			}
+			fmt.Fprintf(&buf, "%v: %s\n", field.name, value)
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func yamlValue(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
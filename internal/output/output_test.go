@@ -0,0 +1,140 @@
+package output
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	Name    string     `json:"name"`
+	Count   int        `json:"count"`
+	Tags    []string   `json:"tags"`
+	At      *time.Time `json:"at"`
+	Ignored string     `json:"-"`
+	hidden  string
+}
+
+func testRecords() []testRecord {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return []testRecord{
+		{Name: "Go Blog", Count: 3, Tags: []string{"go", "news"}, At: &at, Ignored: "x", hidden: "y"},
+		{Name: `Quote "me", please`, Count: 0},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cases := []struct {
+		name   string
+		format Format
+		v      any
+		want   string
+	}{
+		{"json", JSON, testRecords(), `[
+  {
+    "name": "Go Blog",
+    "count": 3,
+    "tags": [
+      "go",
+      "news"
+    ],
+    "at": "2024-05-01T12:30:00Z"
+  },
+  {
+    "name": "Quote \"me\", please",
+    "count": 0,
+    "tags": null,
+    "at": null
+  }
+]
+`},
+		{"json empty", JSON, []testRecord(nil), "[]\n"},
+		{"json single", JSON, testRecord{Name: "a"}, `{
+  "name": "a",
+  "count": 0,
+  "tags": null,
+  "at": null
+}
+`},
+		{"jsonl", JSONL, testRecords(), `{"name":"Go Blog","count":3,"tags":["go","news"],"at":"2024-05-01T12:30:00Z"}
+{"name":"Quote \"me\", please","count":0,"tags":null,"at":null}
+`},
+		{"csv", CSV, testRecords(), `name,count,tags,at
+Go Blog,3,go;news,2024-05-01T12:30:00Z
+"Quote ""me"", please",0,,
+`},
+		{"csv empty keeps header", CSV, []testRecord{}, "name,count,tags,at\n"},
+		{"yaml", YAML, testRecords(), `- name: "Go Blog"
+  count: 3
+  tags: ["go","news"]
+  at: "2024-05-01T12:30:00Z"
+- name: "Quote \"me\", please"
+  count: 0
+  tags: null
+  at: null
+`},
+		{"yaml empty", YAML, []testRecord{}, "[]\n"},
+		{"yaml single", YAML, testRecord{Name: "a: b"}, `name: "a: b"
+count: 0
+tags: null
+at: null
+`},
+		{"table", Table, testRecords(), `NAME                COUNT  TAGS     AT
+Go Blog             3      go;news  2024-05-01T12:30:00Z
+Quote "me", please  0
+`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, c.format, c.v); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := buf.String(); got != c.want {
+				t.Errorf("got:\n%v\nwant:\n%v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteTableTruncatesCells(t *testing.T) {
+	var buf bytes.Buffer
+	long := strings.Repeat("word ", 30)
+	if err := Write(&buf, Table, []testRecord{{Name: long + "\nsecond line"}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header and one row, got %q", buf.String())
+	}
+	if !strings.Contains(lines[1], "…") {
+		t.Errorf("long cell wasn't truncated: %q", lines[1])
+	}
+}
+
+func TestWriteRejectsText(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, Text, testRecords()); err == nil {
+		t.Error("expected an error writing text")
+	}
+}
+
+func TestFormatFlag(t *testing.T) {
+	var format Format
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	fs.Var(&format, "output", "")
+	if !format.IsText() {
+		t.Error("default format should be text")
+	}
+	if err := fs.Parse([]string{"--output", "yaml"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if format != YAML {
+		t.Errorf("got %q, want yaml", format)
+	}
+	if err := fs.Parse([]string{"--output", "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
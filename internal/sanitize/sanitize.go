@@ -0,0 +1,260 @@
+package sanitize
+
+import (
+	"bytes"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedAttrs lists the elements kept by HTML and the attributes kept on each.
+// Anything else is unwrapped, its children survive but the element doesn't.
+var allowedAttrs = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         nil,
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// Elements dropped along with everything inside them.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Textarea: true,
+	atom.Select:   true,
+	atom.Button:   true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+}
+
+// Elements that don't separate words when markup is stripped by Text.
+var inlineElements = map[atom.Atom]bool{
+	atom.A:      true,
+	atom.Abbr:   true,
+	atom.B:      true,
+	atom.Code:   true,
+	atom.Del:    true,
+	atom.Em:     true,
+	atom.I:      true,
+	atom.Ins:    true,
+	atom.Q:      true,
+	atom.S:      true,
+	atom.Small:  true,
+	atom.Span:   true,
+	atom.Strong: true,
+	atom.Sub:    true,
+	atom.Sup:    true,
+	atom.U:      true,
+}
+
+var urlAttrs = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+var safeSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// HTML returns a copy of fragment that's safe to show in a page: only allowlisted elements and
+// attributes survive, URLs are resolved against base and limited to http(s)/mailto, and links open
+// in a new tab without a referrer.  base may be nil.
+func HTML(fragment string, base *url.URL) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		for _, clean := range cleanNode(n, base) {
+			html.Render(&buf, clean)
+		}
+	}
+	return buf.String()
+}
+
+// Text strips all markup from fragment and collapses whitespace, for summaries.
+func Text(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return strings.Join(strings.Fields(fragment), " ")
+	}
+	var sb strings.Builder
+	for _, n := range nodes {
+		collectText(&sb, n)
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func collectText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if droppedElements[n.DataAtom] {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectText(sb, c)
+	}
+	if n.Type == html.ElementNode && !inlineElements[n.DataAtom] {
+		// keep words in neighbouring blocks apart
+		sb.WriteByte(' ')
+	}
+}
+
+// cleanNode returns the sanitized replacement for n, which is zero or more detached nodes.
+func cleanNode(n *html.Node, base *url.URL) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: n.Data}}
+	case html.ElementNode:
+	default:
+		// comments, doctypes
+		return nil
+	}
+	if droppedElements[n.DataAtom] {
+		return nil
+	}
+
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, cleanNode(c, base)...)
+	}
+
+	allowed, ok := allowedAttrs[n.DataAtom]
+	if !ok || n.Namespace != "" {
+		return children
+	}
+
+	clean := &html.Node{Type: html.ElementNode, Data: n.Data, DataAtom: n.DataAtom}
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !slices.Contains(allowed, attr.Key) {
+			continue
+		}
+		if urlAttrs[attr.Key] {
+			safe, ok := safeURL(attr.Val, base)
+			if !ok {
+				continue
+			}
+			attr.Val = safe
+		}
+		clean.Attr = append(clean.Attr, html.Attribute{Key: attr.Key, Val: attr.Val})
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		clean.Attr = append(clean.Attr,
+			html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"},
+			html.Attribute{Key: "target", Val: "_blank"},
+		)
+	case atom.Img:
+		if !hasAttr(clean, "src") {
+			return nil
+		}
+		clean.Attr = append(clean.Attr,
+			html.Attribute{Key: "loading", Val: "lazy"},
+			html.Attribute{Key: "referrerpolicy", Val: "no-referrer"},
+		)
+	}
+
+	for _, c := range children {
+		clean.AppendChild(c)
+	}
+	return []*html.Node{clean}
+}
+
+func safeURL(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme == "" {
+		// Relative with no base to resolve against, it would point at gator itself
+		return "", false
+	}
+	if !safeSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package sanitize
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "hello & goodbye", "hello &amp; goodbye"},
+		{"allowed markup", "<p>Hi <strong>there</strong><br/>you</p>", "<p>Hi <strong>there</strong><br/>you</p>"},
+		{"script dropped with content", "<p>a</p><script>alert(1)</script><p>b</p>", "<p>a</p><p>b</p>"},
+		{"style dropped with content", "<style>body{display:none}</style>text", "text"},
+		{"unknown element unwrapped", "<custom-el><em>kept</em></custom-el>", "<em>kept</em>"},
+		{"event handler stripped", `<p onclick="alert(1)" class="x">a</p>`, "<p>a</p>"},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a rel="noopener noreferrer nofollow" target="_blank">x</a>`},
+		{"relative link resolved", `<a href="../about">x</a>`, `<a href="https://example.com/about" rel="noopener noreferrer nofollow" target="_blank">x</a>`},
+		{"image kept", `<img src="/a.png" alt="A" onerror="x()">`, `<img src="https://example.com/a.png" alt="A" loading="lazy" referrerpolicy="no-referrer"/>`},
+		{"data image dropped", `<img src="data:image/svg+xml;base64,AAAA">`, ""},
+		{"iframe dropped", `<iframe src="https://evil.example"></iframe>ok`, "ok"},
+		{"svg dropped", `<svg><script>alert(1)</script></svg>ok`, "ok"},
+		{"comment dropped", "a<!-- secret -->b", "ab"},
+		{"entity encoded script stays text", "&lt;script&gt;alert(1)&lt;/script&gt;", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"form dropped", `<form action="/x"><input name="a"></form>after`, "after"},
+		{"mailto kept", `<a href="mailto:me@example.com">me</a>`, `<a href="mailto:me@example.com" rel="noopener noreferrer nofollow" target="_blank">me</a>`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := HTML(tc.input, base)
+			if got != tc.want {
+				t.Errorf("HTML(%q)\n got: %q\nwant: %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTMLWithoutBase(t *testing.T) {
+	got := HTML(`<a href="/relative">x</a><img src="img.png">`, nil)
+	want := `<a rel="noopener noreferrer nofollow" target="_blank">x</a>`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"<p>One</p><p>Two</p>", "One Two"},
+		{"a <a href='x'>link</a>, ok", "a link, ok"},
+		{"<p>Hi <strong>there</strong>!</p>", "Hi there!"},
+		{"<script>x()</script>  spaced\n\n out ", "spaced out"},
+		{"Tom &amp; Jerry", "Tom & Jerry"},
+	}
+	for _, tc := range cases {
+		if got := Text(tc.input); got != tc.want {
+			t.Errorf("Text(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
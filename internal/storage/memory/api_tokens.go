@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[arg.ID]; ok {
+		return database.ApiToken{}, duplicate("api token id")
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.ApiToken{}, missing("user")
+	}
+	for _, token := range s.tokens {
+		if token.TokenHash == arg.TokenHash {
+			return database.ApiToken{}, duplicate("api token hash")
+		}
+	}
+	token := database.ApiToken{ID: arg.ID, CreatedAt: utc(arg.CreatedAt), Name: arg.Name, TokenHash: arg.TokenHash, UserID: arg.UserID}
+	s.tokens[token.ID] = token
+	return token, nil
+}
+
+func (s *Store) GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.ApiToken
+	for _, token := range sortedValues(s.tokens, func(a, b database.ApiToken) bool { return a.CreatedAt.Before(b.CreatedAt) }) {
+		if token.UserID == userID {
+			items = append(items, token)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) GetUserByAPIToken(ctx context.Context, arg database.GetUserByAPITokenParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, token := range s.tokens {
+		if token.TokenHash == arg.TokenHash {
+			token.LastUsedAt = nullUTC(arg.LastUsedAt)
+			s.tokens[id] = token
+			return s.users[token.UserID], nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) DeleteAPIToken(ctx context.Context, arg database.DeleteAPITokenParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token, ok := s.tokens[arg.ID]; !ok || token.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(s.tokens, arg.ID)
+	return 1, nil
+}
+
+func (s *Store) ListAPITokens(ctx context.Context) ([]database.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.tokens, func(a, b database.ApiToken) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return uuidLess(a.ID, b.ID)
+	}), nil
+}
+
+func (s *Store) RestoreAPIToken(ctx context.Context, arg database.RestoreAPITokenParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	for id, token := range s.tokens {
+		if token.TokenHash == arg.TokenHash && id != arg.ID {
+			return duplicate("api token hash")
+		}
+	}
+	s.tokens[arg.ID] = database.ApiToken{
+		ID:         arg.ID,
+		CreatedAt:  utc(arg.CreatedAt),
+		Name:       arg.Name,
+		TokenHash:  arg.TokenHash,
+		UserID:     arg.UserID,
+		LastUsedAt: nullUTC(arg.LastUsedAt),
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.follows[arg.ID]; ok {
+		return database.CreateFeedFollowRow{}, duplicate("feed follow id")
+	}
+	usr, ok := s.users[arg.UserID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, missing("user")
+	}
+	feed, ok := s.feeds[arg.FeedID]
+	if !ok {
+		return database.CreateFeedFollowRow{}, missing("feed")
+	}
+	if s.following(arg.UserID, arg.FeedID) {
+		return database.CreateFeedFollowRow{}, duplicate("feed follow")
+	}
+	follow := database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+		Category:  arg.Category,
+	}
+	s.follows[follow.ID] = follow
+	return database.CreateFeedFollowRow{
+		ID:        follow.ID,
+		CreatedAt: follow.CreatedAt,
+		UpdatedAt: follow.UpdatedAt,
+		UserID:    follow.UserID,
+		FeedID:    follow.FeedID,
+		Category:  follow.Category,
+		FeedName:  feed.Name,
+		UserName:  usr.Name,
+	}, nil
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, id uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.GetFeedFollowsForUserRow
+	for _, follow := range s.follows {
+		if follow.UserID != id {
+			continue
+		}
+		feed := s.feeds[follow.FeedID]
+		var unread int64
+		for _, post := range s.posts {
+			if post.FeedID == feed.ID && !s.read(id, post.ID) {
+				unread++
+			}
+		}
+		items = append(items, database.GetFeedFollowsForUserRow{
+			ID:          follow.ID,
+			CreatedAt:   follow.CreatedAt,
+			UpdatedAt:   follow.UpdatedAt,
+			UserID:      follow.UserID,
+			FeedID:      follow.FeedID,
+			Category:    follow.Category,
+			FeedName:    feed.Name,
+			UserName:    s.users[id].Name,
+			FeedUrl:     feed.Url,
+			UnreadCount: unread,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Category != items[j].Category {
+			return items[i].Category < items[j].Category
+		}
+		return items[i].FeedName < items[j].FeedName
+	})
+	return items, nil
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) (database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			delete(s.follows, id)
+			return follow, nil
+		}
+	}
+	return database.FeedFollow{}, sql.ErrNoRows
+}
+
+func (s *Store) SetFeedFollowCategory(ctx context.Context, arg database.SetFeedFollowCategoryParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			follow.Category = arg.Category
+			follow.UpdatedAt = utc(arg.UpdatedAt)
+			s.follows[id] = follow
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *Store) ListFeedFollows(ctx context.Context) ([]database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.follows, func(a, b database.FeedFollow) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return uuidLess(a.ID, b.ID)
+	}), nil
+}
+
+func (s *Store) RestoreFeedFollow(ctx context.Context, arg database.RestoreFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return missing("feed")
+	}
+	for id, follow := range s.follows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID && id != arg.ID {
+			return duplicate("feed follow")
+		}
+	}
+	s.follows[arg.ID] = database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+		Category:  arg.Category,
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// copyFeed returns a feed safe to hand out.
+func copyFeed(feed database.Feed) database.Feed {
+	feed.SkipHours = clone(feed.SkipHours)
+	feed.SkipDays = clone(feed.SkipDays)
+	return feed
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.feeds[arg.ID]; ok {
+		return database.Feed{}, duplicate("feed id")
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Feed{}, missing("user")
+	}
+	for _, feed := range s.feeds {
+		if feed.Url == arg.Url {
+			return database.Feed{}, duplicate("feed url")
+		}
+	}
+	feed := database.Feed{
+		ID:         arg.ID,
+		CreatedAt:  utc(arg.CreatedAt),
+		UpdatedAt:  utc(arg.UpdatedAt),
+		Name:       arg.Name,
+		Url:        arg.Url,
+		UserID:     arg.UserID,
+		SkipHours:  []int32{},
+		SkipDays:   []string{},
+		PublicOnly: arg.PublicOnly,
+	}
+	s.feeds[feed.ID] = feed
+	return copyFeed(feed), nil
+}
+
+// feedByURL returns the feed with url, callers hold mu.
+func (s *Store) feedByURL(url string) (database.Feed, bool) {
+	for _, feed := range s.feeds {
+		if feed.Url == url {
+			return feed, true
+		}
+	}
+	return database.Feed{}, false
+}
+
+func (s *Store) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feedByURL(url)
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return copyFeed(feed), nil
+}
+
+// GetFeeds lists feeds in the order they were added, the query itself has no ORDER BY.
+func (s *Store) GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.GetFeedsRow
+	for _, feed := range sortedValues(s.feeds, feedAddedLess) {
+		items = append(items, database.GetFeedsRow{Name: feed.Name, Url: feed.Url, Username: s.users[feed.UserID].Name})
+	}
+	return items, nil
+}
+
+func feedAddedLess(a, b database.Feed) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.Url < b.Url
+}
+
+func (s *Store) GetFeedErrors(ctx context.Context) ([]database.GetFeedErrorsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.GetFeedErrorsRow
+	for _, feed := range s.feeds {
+		if feed.ConsecutiveFailures > 0 || feed.Disabled {
+			items = append(items, database.GetFeedErrorsRow{
+				Name:                feed.Name,
+				Url:                 feed.Url,
+				LastError:           feed.LastError,
+				LastErrorAt:         feed.LastErrorAt,
+				ConsecutiveFailures: feed.ConsecutiveFailures,
+				NextFetchAt:         feed.NextFetchAt,
+				Disabled:            feed.Disabled,
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Disabled != b.Disabled {
+			return a.Disabled
+		}
+		if a.ConsecutiveFailures != b.ConsecutiveFailures {
+			return a.ConsecutiveFailures > b.ConsecutiveFailures
+		}
+		return a.Url < b.Url
+	})
+	return items, nil
+}
+
+// nullsFirstLess orders NULL before any time, then oldest first.
+func nullsFirstLess(a, b sql.NullTime) (less, equal bool) {
+	if a.Valid != b.Valid {
+		return !a.Valid, false
+	}
+	if !a.Valid || a.Time.Equal(b.Time) {
+		return false, true
+	}
+	return a.Time.Before(b.Time), false
+}
+
+func (s *Store) ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := utc(arg.Now)
+	var due []database.Feed
+	for _, feed := range s.feeds {
+		if feed.Disabled ||
+			(feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(now)) ||
+			(feed.LeaseExpiresAt.Valid && !feed.LeaseExpiresAt.Time.Before(now)) {
+			continue
+		}
+		due = append(due, feed)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if less, equal := nullsFirstLess(due[i].NextFetchAt, due[j].NextFetchAt); !equal {
+			return less
+		}
+		if less, equal := nullsFirstLess(due[i].LastFetchedAt, due[j].LastFetchedAt); !equal {
+			return less
+		}
+		return feedAddedLess(due[i], due[j])
+	})
+	if len(due) > int(arg.Batch) {
+		due = due[:max(arg.Batch, 0)]
+	}
+
+	var items []database.Feed
+	for _, feed := range due {
+		feed.UpdatedAt = now
+		feed.LastFetchedAt = sql.NullTime{Time: now, Valid: true}
+		feed.LeaseOwner = arg.Owner
+		feed.LeaseExpiresAt = nullUTC(arg.LeaseExpiresAt)
+		s.feeds[feed.ID] = feed
+		items = append(items, copyFeed(feed))
+	}
+	return items, nil
+}
+
+func (s *Store) ReleaseFeedLease(ctx context.Context, arg database.ReleaseFeedLeaseParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[arg.ID]; ok && feed.LeaseOwner == arg.LeaseOwner {
+		feed.LeaseOwner = ""
+		feed.LeaseExpiresAt = sql.NullTime{}
+		s.feeds[feed.ID] = feed
+	}
+	return nil
+}
+
+func (s *Store) UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[arg.ID]; ok {
+		feed.Etag = arg.Etag
+		feed.LastModified = arg.LastModified
+		feed.LastBodySize = arg.LastBodySize
+		s.feeds[feed.ID] = feed
+	}
+	return nil
+}
+
+func (s *Store) RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feeds[arg.ID]
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	feed.LastError = arg.Error
+	feed.LastErrorAt = nullUTC(arg.At)
+	feed.Disabled = feed.ConsecutiveFailures+1 >= arg.MaxFailures
+	feed.ConsecutiveFailures++
+	feed.NextFetchAt = nullUTC(arg.NextFetchAt)
+	s.feeds[feed.ID] = feed
+	return copyFeed(feed), nil
+}
+
+func (s *Store) RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[arg.ID]; ok {
+		feed.ConsecutiveFailures = 0
+		feed.NextFetchAt = nullUTC(arg.NextFetchAt)
+		s.feeds[feed.ID] = feed
+	}
+	return nil
+}
+
+func (s *Store) EnableFeed(ctx context.Context, url string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feedByURL(url)
+	if !ok {
+		return 0, nil
+	}
+	feed.Disabled = false
+	feed.ConsecutiveFailures = 0
+	feed.NextFetchAt = sql.NullTime{}
+	s.feeds[feed.ID] = feed
+	return 1, nil
+}
+
+func (s *Store) UpdateFeedScheduleHints(ctx context.Context, arg database.UpdateFeedScheduleHintsParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if feed, ok := s.feeds[arg.ID]; ok {
+		feed.HintedIntervalSeconds = arg.HintedIntervalSeconds
+		feed.SkipHours = clone(arg.SkipHours)
+		feed.SkipDays = clone(arg.SkipDays)
+		s.feeds[feed.ID] = feed
+	}
+	return nil
+}
+
+func (s *Store) SetFeedIntervalOverride(ctx context.Context, arg database.SetFeedIntervalOverrideParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return 0, nil
+	}
+	feed.IntervalOverrideSeconds = arg.IntervalOverrideSeconds
+	feed.NextFetchAt = sql.NullTime{}
+	s.feeds[feed.ID] = feed
+	return 1, nil
+}
+
+func (s *Store) SetFeedRetention(ctx context.Context, arg database.SetFeedRetentionParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feed, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return 0, nil
+	}
+	feed.RetentionMaxAgeSeconds = arg.RetentionMaxAgeSeconds
+	feed.RetentionMaxPosts = arg.RetentionMaxPosts
+	feed.RetentionKeepUnread = arg.RetentionKeepUnread
+	s.feeds[feed.ID] = feed
+	return 1, nil
+}
+
+func (s *Store) GetFeedRetentions(ctx context.Context) ([]database.GetFeedRetentionsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.GetFeedRetentionsRow
+	for _, feed := range sortedValues(s.feeds, func(a, b database.Feed) bool {
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Url < b.Url
+	}) {
+		items = append(items, database.GetFeedRetentionsRow{
+			ID:                     feed.ID,
+			Name:                   feed.Name,
+			Url:                    feed.Url,
+			RetentionMaxAgeSeconds: feed.RetentionMaxAgeSeconds,
+			RetentionMaxPosts:      feed.RetentionMaxPosts,
+			RetentionKeepUnread:    feed.RetentionKeepUnread,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) ListFeeds(ctx context.Context) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.Feed
+	for _, feed := range sortedValues(s.feeds, func(a, b database.Feed) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return uuidLess(a.ID, b.ID)
+	}) {
+		items = append(items, copyFeed(feed))
+	}
+	return items, nil
+}
+
+// RestoreFeed keeps the lease of a feed it replaces, leases aren't restored.
+func (s *Store) RestoreFeed(ctx context.Context, arg database.RestoreFeedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	if feed, ok := s.feedByURL(arg.Url); ok && feed.ID != arg.ID {
+		return duplicate("feed url")
+	}
+	old := s.feeds[arg.ID]
+	s.feeds[arg.ID] = database.Feed{
+		ID:                      arg.ID,
+		CreatedAt:               utc(arg.CreatedAt),
+		UpdatedAt:               utc(arg.UpdatedAt),
+		Name:                    arg.Name,
+		Url:                     arg.Url,
+		UserID:                  arg.UserID,
+		LastFetchedAt:           nullUTC(arg.LastFetchedAt),
+		Etag:                    arg.Etag,
+		LastModified:            arg.LastModified,
+		LastBodySize:            arg.LastBodySize,
+		LeaseOwner:              old.LeaseOwner,
+		LeaseExpiresAt:          old.LeaseExpiresAt,
+		LastError:               arg.LastError,
+		LastErrorAt:             nullUTC(arg.LastErrorAt),
+		ConsecutiveFailures:     arg.ConsecutiveFailures,
+		NextFetchAt:             nullUTC(arg.NextFetchAt),
+		Disabled:                arg.Disabled,
+		HintedIntervalSeconds:   arg.HintedIntervalSeconds,
+		SkipHours:               clone(arg.SkipHours),
+		SkipDays:                clone(arg.SkipDays),
+		IntervalOverrideSeconds: arg.IntervalOverrideSeconds,
+		RetentionMaxAgeSeconds:  arg.RetentionMaxAgeSeconds,
+		RetentionMaxPosts:       arg.RetentionMaxPosts,
+		RetentionKeepUnread:     arg.RetentionKeepUnread,
+		PublicOnly:              arg.PublicOnly,
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package memory is an in-memory storage.Store for tests.  It keeps the schema's rules: unique user names,
+// feed urls, follows and token hashes, foreign keys and ON DELETE CASCADE.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/storage"
+)
+
+// ErrForeignKey is returned, wrapped, when a row refers to one that doesn't exist.
+var ErrForeignKey = errors.New("foreign key violation")
+
+type userPost struct {
+	userID uuid.UUID
+	postID uuid.UUID
+}
+
+type feedItem struct {
+	feedID uuid.UUID
+	guid   string
+}
+
+type Store struct {
+	mu      sync.Mutex
+	users   map[uuid.UUID]database.User
+	feeds   map[uuid.UUID]database.Feed
+	follows map[uuid.UUID]database.FeedFollow
+	posts   map[uuid.UUID]database.Post
+	reads   map[userPost]database.PostRead
+	stars   map[userPost]database.PostStar
+	tokens  map[uuid.UUID]database.ApiToken
+	pruned  map[feedItem]struct{}
+}
+
+var _ storage.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:   make(map[uuid.UUID]database.User),
+		feeds:   make(map[uuid.UUID]database.Feed),
+		follows: make(map[uuid.UUID]database.FeedFollow),
+		posts:   make(map[uuid.UUID]database.Post),
+		reads:   make(map[userPost]database.PostRead),
+		stars:   make(map[userPost]database.PostStar),
+		tokens:  make(map[uuid.UUID]database.ApiToken),
+		pruned:  make(map[feedItem]struct{}),
+	}
+}
+
+// InTx runs fn against the store itself and puts every table back if fn fails.  Other callers see its
+// writes before it returns, which is fine for tests.  opts is ignored.
+func (s *Store) InTx(ctx context.Context, opts *sql.TxOptions, fn func(storage.Store) error) error {
+	s.mu.Lock()
+	saved := s.copyTables()
+	s.mu.Unlock()
+	if err := fn(s); err != nil {
+		s.mu.Lock()
+		s.users, s.feeds, s.follows, s.posts = saved.users, saved.feeds, saved.follows, saved.posts
+		s.reads, s.stars, s.tokens, s.pruned = saved.reads, saved.stars, saved.tokens, saved.pruned
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// copyTables copies every map, callers hold mu.  Rows are replaced, never changed in place, so copying
+// the maps is enough.
+func (s *Store) copyTables() *Store {
+	return &Store{
+		users:   maps.Clone(s.users),
+		feeds:   maps.Clone(s.feeds),
+		follows: maps.Clone(s.follows),
+		posts:   maps.Clone(s.posts),
+		reads:   maps.Clone(s.reads),
+		stars:   maps.Clone(s.stars),
+		tokens:  maps.Clone(s.tokens),
+		pruned:  maps.Clone(s.pruned),
+	}
+}
+
+func duplicate(what string) error {
+	return fmt.Errorf("%w: %v", storage.ErrDuplicate, what)
+}
+
+func missing(what string) error {
+	return fmt.Errorf("%w: no such %v", ErrForeignKey, what)
+}
+
+// The cascades, callers hold mu.
+
+func (s *Store) deleteUser(id uuid.UUID) {
+	delete(s.users, id)
+	for feedID, feed := range s.feeds {
+		if feed.UserID == id {
+			s.deleteFeed(feedID)
+		}
+	}
+	for followID, follow := range s.follows {
+		if follow.UserID == id {
+			delete(s.follows, followID)
+		}
+	}
+	for key := range s.reads {
+		if key.userID == id {
+			delete(s.reads, key)
+		}
+	}
+	for key := range s.stars {
+		if key.userID == id {
+			delete(s.stars, key)
+		}
+	}
+	for tokenID, token := range s.tokens {
+		if token.UserID == id {
+			delete(s.tokens, tokenID)
+		}
+	}
+}
+
+func (s *Store) deleteFeed(id uuid.UUID) {
+	delete(s.feeds, id)
+	for followID, follow := range s.follows {
+		if follow.FeedID == id {
+			delete(s.follows, followID)
+		}
+	}
+	for postID, post := range s.posts {
+		if post.FeedID == id {
+			s.deletePost(postID)
+		}
+	}
+	for key := range s.pruned {
+		if key.feedID == id {
+			delete(s.pruned, key)
+		}
+	}
+}
+
+func (s *Store) deletePost(id uuid.UUID) {
+	delete(s.posts, id)
+	for key := range s.reads {
+		if key.postID == id {
+			delete(s.reads, key)
+		}
+	}
+	for key := range s.stars {
+		if key.postID == id {
+			delete(s.stars, key)
+		}
+	}
+}
+
+// following reports whether the user follows the feed, the join every per-user post query makes.
+func (s *Store) following(userID, feedID uuid.UUID) bool {
+	for _, follow := range s.follows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) read(userID, postID uuid.UUID) bool {
+	_, ok := s.reads[userPost{userID, postID}]
+	return ok
+}
+
+// Times are stored in UTC like the SQL backends hand them back.
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func nullUTC(t sql.NullTime) sql.NullTime {
+	if !t.Valid {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.Time.UTC(), Valid: true}
+}
+
+// Slices are copied in and out so callers can't change stored rows.  nil is stored as empty, like the
+// NOT NULL DEFAULT '{}' columns.
+func clone[T any](values []T) []T {
+	return append(make([]T, 0, len(values)), values...)
+}
+
+func uuidLess(a, b uuid.UUID) bool {
+	return a.String() < b.String()
+}
+
+// sortedValues returns a map's values ordered by less, maps have no order of their own.
+func sortedValues[K comparable, V any](m map[K]V, less func(a, b V) bool) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return less(values[i], values[j]) })
+	return values
+}
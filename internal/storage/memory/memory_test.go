@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/striderjg/gator/internal/storage"
+	"github.com/striderjg/gator/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return New()
+	})
+}
+
+func TestLike(t *testing.T) {
+	cases := []struct {
+		text, pattern string
+		want          bool
+	}{
+		{"Generics in Go", "GENERIC", true},
+		{"Generics in Go", "rust", false},
+		{"Wrapping errors with %w", `\%w`, true},
+		{"Wrapping errors with w", `\%w`, false},
+		{"snake_case", `e\_c`, true},
+		{"snakeXcase", `e\_c`, false},
+		{"snakeXcase", "e_c", true},
+		{"anything", "a%g", true},
+		{`back\slash`, `k\\s`, true},
+	}
+	for _, c := range cases {
+		if got := like(c.text, c.pattern); got != c.want {
+			t.Errorf("like(%q, %q) = %v, want %v", c.text, c.pattern, got, c.want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q := parseQuery(`rust or go "error handling" -panics or`)
+	if len(q.groups) != 2 || len(q.groups[0]) != 2 || len(q.groups[1]) != 1 {
+		t.Fatalf("groups = %v, want (rust OR go) AND \"error handling\"", q.groups)
+	}
+	if got := q.groups[1][0]; len(got) != 2 || got[0] != "error" || got[1] != "handl" {
+		t.Errorf("phrase = %v", got)
+	}
+	if len(q.exclude) != 1 || q.exclude[0][0] != "panic" {
+		t.Errorf("exclude = %v, want panic", q.exclude)
+	}
+	if q := parseQuery("-rust"); len(q.groups) != 0 {
+		t.Errorf("a query of only -words has groups %v", q.groups)
+	}
+}
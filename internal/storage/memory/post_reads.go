@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	if _, ok := s.posts[arg.PostID]; !ok {
+		return missing("post")
+	}
+	key := userPost{arg.UserID, arg.PostID}
+	if _, ok := s.reads[key]; !ok {
+		s.reads[key] = database.PostRead{UserID: arg.UserID, PostID: arg.PostID, ReadAt: utc(arg.ReadAt)}
+	}
+	return nil
+}
+
+func (s *Store) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reads, userPost{arg.UserID, arg.PostID})
+	return nil
+}
+
+func (s *Store) MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, post := range s.userPosts(arg.UserID) {
+		if (arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID) ||
+			(arg.Before.Valid && !publishedKey(post).Before(arg.Before.Time)) ||
+			s.read(arg.UserID, post.ID) {
+			continue
+		}
+		s.reads[userPost{arg.UserID, post.ID}] = database.PostRead{UserID: arg.UserID, PostID: post.ID, ReadAt: utc(arg.ReadAt)}
+		n++
+	}
+	return n, nil
+}
+
+func (s *Store) ListPostReads(ctx context.Context) ([]database.PostRead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.reads, func(a, b database.PostRead) bool {
+		if a.UserID != b.UserID {
+			return uuidLess(a.UserID, b.UserID)
+		}
+		return uuidLess(a.PostID, b.PostID)
+	}), nil
+}
+
+func (s *Store) RestorePostRead(ctx context.Context, arg database.RestorePostReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	if _, ok := s.posts[arg.PostID]; !ok {
+		return missing("post")
+	}
+	s.reads[userPost{arg.UserID, arg.PostID}] = database.PostRead{UserID: arg.UserID, PostID: arg.PostID, ReadAt: utc(arg.ReadAt)}
+	return nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) (database.PostStar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.PostStar{}, missing("user")
+	}
+	if _, ok := s.posts[arg.PostID]; !ok {
+		return database.PostStar{}, missing("post")
+	}
+	key := userPost{arg.UserID, arg.PostID}
+	star, ok := s.stars[key]
+	if !ok {
+		star = database.PostStar{UserID: arg.UserID, PostID: arg.PostID, CreatedAt: utc(arg.StarredAt)}
+	}
+	// Starring an already starred post only replaces the note when a new one is given
+	if arg.Note.Valid {
+		star.Note = arg.Note.String
+	}
+	star.UpdatedAt = utc(arg.StarredAt)
+	s.stars[key] = star
+	return star, nil
+}
+
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := userPost{arg.UserID, arg.PostID}
+	if _, ok := s.stars[key]; !ok {
+		return 0, nil
+	}
+	delete(s.stars, key)
+	return 1, nil
+}
+
+func (s *Store) GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetStarredPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.GetStarredPostsForUserRow
+	for key, star := range s.stars {
+		if key.userID != userID {
+			continue
+		}
+		post := s.posts[key.postID]
+		feed := s.feeds[post.FeedID]
+		items = append(items, database.GetStarredPostsForUserRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Author:      post.Author,
+			Categories:  clone(post.Categories),
+			Guid:        post.Guid,
+			FeedName:    feed.Name,
+			FeedUrl:     feed.Url,
+			Note:        star.Note,
+			StarredAt:   star.CreatedAt,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].StarredAt.After(items[j].StarredAt) })
+	return items, nil
+}
+
+func (s *Store) ListPostStars(ctx context.Context) ([]database.PostStar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.stars, func(a, b database.PostStar) bool {
+		if a.UserID != b.UserID {
+			return uuidLess(a.UserID, b.UserID)
+		}
+		return uuidLess(a.PostID, b.PostID)
+	}), nil
+}
+
+func (s *Store) RestorePostStar(ctx context.Context, arg database.RestorePostStarParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return missing("user")
+	}
+	if _, ok := s.posts[arg.PostID]; !ok {
+		return missing("post")
+	}
+	s.stars[userPost{arg.UserID, arg.PostID}] = database.PostStar{
+		UserID:    arg.UserID,
+		PostID:    arg.PostID,
+		Note:      arg.Note,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+	}
+	return nil
+}
@@ -0,0 +1,515 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.CreatePostRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return database.CreatePostRow{}, missing("feed")
+	}
+	// A pruned item isn't stored again, like the insert's WHERE NOT EXISTS
+	if _, ok := s.pruned[feedItem{arg.FeedID, arg.Guid}]; ok {
+		return database.CreatePostRow{}, sql.ErrNoRows
+	}
+
+	for _, post := range s.posts {
+		if post.FeedID != arg.FeedID || post.Guid != arg.Guid {
+			continue
+		}
+		// An unchanged duplicate returns no row, like the upsert's WHERE
+		if post.Title == arg.Title && post.Url == arg.Url && post.Description == arg.Description {
+			return database.CreatePostRow{}, sql.ErrNoRows
+		}
+		post.UpdatedAt = utc(arg.UpdatedAt)
+		post.Title = arg.Title
+		post.Url = arg.Url
+		post.Description = arg.Description
+		post.Author = arg.Author
+		post.Categories = clone(arg.Categories)
+		s.posts[post.ID] = post
+		return createPostRow(post, false), nil
+	}
+
+	if _, ok := s.posts[arg.ID]; ok {
+		return database.CreatePostRow{}, duplicate("post id")
+	}
+	post := database.Post{
+		ID:          arg.ID,
+		CreatedAt:   utc(arg.CreatedAt),
+		UpdatedAt:   utc(arg.UpdatedAt),
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: nullUTC(arg.PublishedAt),
+		FeedID:      arg.FeedID,
+		Author:      arg.Author,
+		Categories:  clone(arg.Categories),
+		Guid:        arg.Guid,
+	}
+	s.posts[post.ID] = post
+	return createPostRow(post, true), nil
+}
+
+func (s *Store) AdoptLegacyPost(ctx context.Context, arg database.AdoptLegacyPostParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var legacy *database.Post
+	for _, post := range s.posts {
+		if post.FeedID != arg.FeedID {
+			continue
+		}
+		if post.Guid == arg.Guid {
+			return 0, nil
+		}
+		if post.Guid == arg.Url && post.Url == arg.Url {
+			legacy = &post
+		}
+	}
+	if legacy == nil {
+		return 0, nil
+	}
+	legacy.Guid = arg.Guid
+	s.posts[legacy.ID] = *legacy
+	return 1, nil
+}
+
+func createPostRow(post database.Post, inserted bool) database.CreatePostRow {
+	return database.CreatePostRow{
+		ID:          post.ID,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description,
+		PublishedAt: post.PublishedAt,
+		FeedID:      post.FeedID,
+		Author:      post.Author,
+		Categories:  clone(post.Categories),
+		Guid:        post.Guid,
+		Inserted:    inserted,
+	}
+}
+
+func (s *Store) GetFeedPostCadence(ctx context.Context, feedID uuid.UUID) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var published []time.Time
+	for _, post := range s.posts {
+		if post.FeedID == feedID && post.PublishedAt.Valid {
+			published = append(published, post.PublishedAt.Time)
+		}
+	}
+	sort.Slice(published, func(i, j int) bool { return published[i].After(published[j]) })
+	if len(published) > 20 {
+		published = published[:20]
+	}
+	if len(published) < 2 {
+		return 0, nil
+	}
+	return published[0].Sub(published[len(published)-1]).Seconds() / float64(len(published)-1), nil
+}
+
+func (s *Store) GetPostForUser(ctx context.Context, arg database.GetPostForUserParams) (database.GetPostForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[arg.ID]
+	if !ok || !s.following(arg.UserID, post.FeedID) {
+		return database.GetPostForUserRow{}, sql.ErrNoRows
+	}
+	feed := s.feeds[post.FeedID]
+	return database.GetPostForUserRow{
+		ID:          post.ID,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description,
+		PublishedAt: post.PublishedAt,
+		FeedID:      post.FeedID,
+		Author:      post.Author,
+		Categories:  clone(post.Categories),
+		Guid:        post.Guid,
+		FeedName:    feed.Name,
+		FeedUrl:     feed.Url,
+		Read:        s.read(arg.UserID, post.ID),
+	}, nil
+}
+
+// userPosts returns the posts in the feeds the user follows, callers hold mu.
+func (s *Store) userPosts(userID uuid.UUID) []database.Post {
+	var posts []database.Post
+	for _, post := range s.posts {
+		if s.following(userID, post.FeedID) {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+func (s *Store) ListPostsForUser(ctx context.Context, arg database.ListPostsForUserParams) ([]database.ListPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var posts []database.Post
+	for _, post := range s.userPosts(arg.UserID) {
+		if (arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID) ||
+			(arg.Since.Valid && (!post.PublishedAt.Valid || post.PublishedAt.Time.Before(arg.Since.Time))) ||
+			(arg.Until.Valid && (!post.PublishedAt.Valid || !post.PublishedAt.Time.Before(arg.Until.Time))) ||
+			(arg.UnreadOnly && s.read(arg.UserID, post.ID)) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	// published_at DESC NULLS LAST, id
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i].PublishedAt, posts[j].PublishedAt
+		if a.Valid != b.Valid {
+			return a.Valid
+		}
+		if a.Valid && !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+		return uuidLess(posts[i].ID, posts[j].ID)
+	})
+	posts = page(posts, int(arg.Offset), int(arg.Limit))
+
+	var items []database.ListPostsForUserRow
+	for _, post := range posts {
+		feed := s.feeds[post.FeedID]
+		items = append(items, database.ListPostsForUserRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Author:      post.Author,
+			Categories:  clone(post.Categories),
+			Guid:        post.Guid,
+			FeedName:    feed.Name,
+			FeedUrl:     feed.Url,
+			Read:        s.read(arg.UserID, post.ID),
+		})
+	}
+	return items, nil
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[max(offset, 0):]
+	if len(items) > limit {
+		items = items[:max(limit, 0)]
+	}
+	return items
+}
+
+// feedMatches is the browse and search feed filter: the url, or the name ignoring case.
+func feedMatches(feed database.Feed, filter sql.NullString) bool {
+	return !filter.Valid || feed.Url == filter.String || strings.EqualFold(feed.Name, filter.String)
+}
+
+// browse runs the four keyset browse queries.  key is the sort time of a post.
+func (s *Store) browse(arg database.BrowsePostsByPublishedParams, key func(database.Post) time.Time, reverse bool) []database.BrowsePostsByPublishedRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// (key, id) compared as a row, like the SQL
+	compare := func(at time.Time, id uuid.UUID, cursorAt time.Time, cursorID uuid.UUID) int {
+		if c := at.Compare(cursorAt); c != 0 {
+			return c
+		}
+		return strings.Compare(id.String(), cursorID.String())
+	}
+
+	var posts []database.Post
+	for _, post := range s.userPosts(arg.UserID) {
+		at := key(post)
+		text := post.Title + " " + post.Description
+		if !feedMatches(s.feeds[post.FeedID], arg.Feed) ||
+			(arg.Since.Valid && at.Before(arg.Since.Time)) ||
+			(arg.Until.Valid && !at.Before(arg.Until.Time)) ||
+			(arg.UnreadOnly && s.read(arg.UserID, post.ID)) {
+			continue
+		}
+		if arg.CursorAt.Valid {
+			c := compare(at, post.ID, arg.CursorAt.Time, arg.CursorID.UUID)
+			if (!reverse && c >= 0) || (reverse && c <= 0) {
+				continue
+			}
+		}
+		matched := true
+		for _, word := range arg.Include {
+			matched = matched && like(text, word)
+		}
+		for _, word := range arg.Exclude {
+			matched = matched && !like(text, word)
+		}
+		if matched {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		c := compare(key(posts[i]), posts[i].ID, key(posts[j]), posts[j].ID)
+		if reverse {
+			return c < 0
+		}
+		return c > 0
+	})
+	posts = page(posts, 0, int(arg.Limit))
+
+	var items []database.BrowsePostsByPublishedRow
+	for _, post := range posts {
+		items = append(items, database.BrowsePostsByPublishedRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			CreatedAt:   post.CreatedAt,
+			FeedName:    s.feeds[post.FeedID].Name,
+			Read:        s.read(arg.UserID, post.ID),
+			SortAt:      key(post),
+		})
+	}
+	return items
+}
+
+func publishedKey(post database.Post) time.Time {
+	if post.PublishedAt.Valid {
+		return post.PublishedAt.Time
+	}
+	return post.CreatedAt
+}
+
+func fetchedKey(post database.Post) time.Time {
+	return post.CreatedAt
+}
+
+func (s *Store) BrowsePostsByPublished(ctx context.Context, arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+	return s.browse(arg, publishedKey, false), nil
+}
+
+func (s *Store) BrowsePostsByPublishedReverse(ctx context.Context, arg database.BrowsePostsByPublishedReverseParams) ([]database.BrowsePostsByPublishedReverseRow, error) {
+	var items []database.BrowsePostsByPublishedReverseRow
+	for _, row := range s.browse(database.BrowsePostsByPublishedParams(arg), publishedKey, true) {
+		items = append(items, database.BrowsePostsByPublishedReverseRow(row))
+	}
+	return items, nil
+}
+
+func (s *Store) BrowsePostsByFetched(ctx context.Context, arg database.BrowsePostsByFetchedParams) ([]database.BrowsePostsByFetchedRow, error) {
+	var items []database.BrowsePostsByFetchedRow
+	for _, row := range s.browse(database.BrowsePostsByPublishedParams(arg), fetchedKey, false) {
+		items = append(items, database.BrowsePostsByFetchedRow(row))
+	}
+	return items, nil
+}
+
+func (s *Store) BrowsePostsByFetchedReverse(ctx context.Context, arg database.BrowsePostsByFetchedReverseParams) ([]database.BrowsePostsByFetchedReverseRow, error) {
+	var items []database.BrowsePostsByFetchedReverseRow
+	for _, row := range s.browse(database.BrowsePostsByPublishedParams(arg), fetchedKey, true) {
+		items = append(items, database.BrowsePostsByFetchedReverseRow(row))
+	}
+	return items, nil
+}
+
+// like reports whether text contains pattern, an ILIKE pattern without the surrounding %s: % and _ are
+// wildcards and \ escapes the next character.
+func like(text, pattern string) bool {
+	t, p := []rune(strings.ToLower(text)), []rune(strings.ToLower(pattern))
+	for start := 0; start <= len(t); start++ {
+		if likePrefix(t[start:], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// likePrefix reports whether some prefix of t matches all of p.
+func likePrefix(t, p []rune) bool {
+	for len(p) > 0 {
+		switch {
+		case p[0] == '%':
+			for i := 0; i <= len(t); i++ {
+				if likePrefix(t[i:], p[1:]) {
+					return true
+				}
+			}
+			return false
+		case p[0] == '_':
+			if len(t) == 0 {
+				return false
+			}
+		case p[0] == '\\' && len(p) > 1:
+			p = p[1:]
+			fallthrough
+		default:
+			if len(t) == 0 || t[0] != p[0] {
+				return false
+			}
+		}
+		t, p = t[1:], p[1:]
+	}
+	return true
+}
+
+// prunable returns the posts PrunePosts deletes, callers hold mu.  Positions for max posts count every post
+// of the feed, newest first, kept or not.
+func (s *Store) prunable(arg database.PrunePostsParams) []uuid.UUID {
+	var posts []database.Post
+	for _, post := range s.posts {
+		if post.FeedID == arg.FeedID {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		ki, kj := publishedKey(posts[i]), publishedKey(posts[j])
+		if !ki.Equal(kj) {
+			return ki.After(kj)
+		}
+		return uuidLess(posts[j].ID, posts[i].ID)
+	})
+
+	var ids []uuid.UUID
+	for i, post := range posts {
+		old := arg.Before.Valid && publishedKey(post).Before(arg.Before.Time)
+		over := arg.MaxPosts.Valid && i >= int(arg.MaxPosts.Int32)
+		if (!old && !over) || s.starred(post.ID) || (arg.KeepUnread && s.unread(post)) {
+			continue
+		}
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+// starred reports whether anyone starred the post.
+func (s *Store) starred(postID uuid.UUID) bool {
+	for key := range s.stars {
+		if key.postID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+// unread reports whether any follower of the post's feed hasn't read it.
+func (s *Store) unread(post database.Post) bool {
+	for _, follow := range s.follows {
+		if follow.FeedID == post.FeedID && !s.read(follow.UserID, post.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CountPrunablePosts(ctx context.Context, arg database.CountPrunablePostsParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.prunable(database.PrunePostsParams(arg)))), nil
+}
+
+func (s *Store) PrunePosts(ctx context.Context, arg database.PrunePostsParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.prunable(arg)
+	for _, id := range ids {
+		s.pruned[feedItem{arg.FeedID, s.posts[id].Guid}] = struct{}{}
+		s.deletePost(id)
+	}
+	return int64(len(ids)), nil
+}
+
+func (s *Store) ListPosts(ctx context.Context, arg database.ListPostsParams) ([]database.ListPostsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.ListPostsRow
+	for _, post := range sortedValues(s.posts, func(a, b database.Post) bool { return uuidLess(a.ID, b.ID) }) {
+		if !uuidLess(arg.After, post.ID) {
+			continue
+		}
+		if len(items) == int(arg.Limit) {
+			break
+		}
+		items = append(items, database.ListPostsRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Author:      post.Author,
+			Categories:  clone(post.Categories),
+			Guid:        post.Guid,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) RestorePost(ctx context.Context, arg database.RestorePostParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return missing("feed")
+	}
+	for id, post := range s.posts {
+		if post.FeedID == arg.FeedID && post.Guid == arg.Guid && id != arg.ID {
+			return duplicate("post guid")
+		}
+	}
+	s.posts[arg.ID] = database.Post{
+		ID:          arg.ID,
+		CreatedAt:   utc(arg.CreatedAt),
+		UpdatedAt:   utc(arg.UpdatedAt),
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: nullUTC(arg.PublishedAt),
+		FeedID:      arg.FeedID,
+		Author:      arg.Author,
+		Categories:  clone(arg.Categories),
+		Guid:        arg.Guid,
+	}
+	return nil
+}
+
+func (s *Store) ListPrunedPosts(ctx context.Context) ([]database.PrunedPost, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.PrunedPost
+	for key := range s.pruned {
+		items = append(items, database.PrunedPost{FeedID: key.feedID, Guid: key.guid})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].FeedID != items[j].FeedID {
+			return uuidLess(items[i].FeedID, items[j].FeedID)
+		}
+		return items[i].Guid < items[j].Guid
+	})
+	return items, nil
+}
+
+func (s *Store) RestorePrunedPost(ctx context.Context, arg database.RestorePrunedPostParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.feeds[arg.FeedID]; !ok {
+		return missing("feed")
+	}
+	s.pruned[feedItem{arg.FeedID, arg.Guid}] = struct{}{}
+	return nil
+}
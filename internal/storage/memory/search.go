@@ -0,0 +1,263 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// Marks put around matches, the same ones the Postgres query asks ts_headline for.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// Title matches count for more than description matches, like the A and B weights of the search vector.
+const (
+	titleWeight       = 1.0
+	descriptionWeight = 0.4
+	snippetWords      = 25
+)
+
+// word is a word of a post and where it is in the text.
+type word struct {
+	start, end int
+	stem       string
+}
+
+// words splits text on anything that isn't a letter or digit.
+func words(text string) []word {
+	var ws []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			ws = append(ws, word{start, i, stem(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ws = append(ws, word{start, len(text), stem(text[start:])})
+	}
+	return ws
+}
+
+// stem is a light suffix stripper, enough for "channel" to find "channels" the way Postgres' english
+// stemmer does.  Queries and posts go through the same one so they agree with each other.
+func stem(w string) string {
+	w = strings.ToLower(w)
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ing") && len(w) > 5:
+		return w[:len(w)-3]
+	case strings.HasSuffix(w, "ed") && len(w) > 4:
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// phrase is the stems of a search term, matched as consecutive words.
+type phrase []string
+
+// query is websearch syntax parsed: every group must match one of its phrases, no exclude phrase may match.
+type query struct {
+	groups  [][]phrase
+	exclude []phrase
+}
+
+func parseQuery(q string) query {
+	var parsed query
+	or := false
+	for _, token := range splitQuery(q) {
+		if strings.EqualFold(token, "or") {
+			or = len(parsed.groups) > 0
+			continue
+		}
+		negate := strings.HasPrefix(token, "-")
+		var p phrase
+		for _, w := range words(strings.TrimPrefix(token, "-")) {
+			p = append(p, w.stem)
+		}
+		if len(p) == 0 {
+			continue
+		}
+		switch {
+		case negate:
+			parsed.exclude = append(parsed.exclude, p)
+		case or:
+			parsed.groups[len(parsed.groups)-1] = append(parsed.groups[len(parsed.groups)-1], p)
+		default:
+			parsed.groups = append(parsed.groups, []phrase{p})
+		}
+		or = false
+	}
+	return parsed
+}
+
+// splitQuery splits on spaces outside of double quotes.
+func splitQuery(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// matches returns the index of the first word of every occurrence of p in ws.
+func (p phrase) matches(ws []word) []int {
+	var at []int
+	for i := 0; i+len(p) <= len(ws); i++ {
+		found := true
+		for j, s := range p {
+			if ws[i+j].stem != s {
+				found = false
+				break
+			}
+		}
+		if found {
+			at = append(at, i)
+		}
+	}
+	return at
+}
+
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	q := parseQuery(arg.Query)
+	if len(q.groups) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []database.SearchPostsForUserRow
+	for _, post := range s.userPosts(arg.UserID) {
+		if !feedMatches(s.feeds[post.FeedID], arg.Feed) ||
+			(arg.Before.Valid && (!post.PublishedAt.Valid || !post.PublishedAt.Time.Before(arg.Before.Time))) ||
+			(arg.After.Valid && (!post.PublishedAt.Valid || post.PublishedAt.Time.Before(arg.After.Time))) {
+			continue
+		}
+		title, description := words(post.Title), words(post.Description)
+		excluded := false
+		for _, p := range q.exclude {
+			excluded = excluded || len(p.matches(title)) > 0 || len(p.matches(description)) > 0
+		}
+		if excluded {
+			continue
+		}
+
+		// Which words of the title and description are part of a match
+		titleHits, descriptionHits := make([]bool, len(title)), make([]bool, len(description))
+		rank := 0.0
+		matched := true
+		for _, group := range q.groups {
+			groupMatched := false
+			for _, p := range group {
+				for _, at := range p.matches(title) {
+					groupMatched = true
+					rank += titleWeight
+					for i := range p {
+						titleHits[at+i] = true
+					}
+				}
+				for _, at := range p.matches(description) {
+					groupMatched = true
+					rank += descriptionWeight
+					for i := range p {
+						descriptionHits[at+i] = true
+					}
+				}
+			}
+			matched = matched && groupMatched
+		}
+		if !matched {
+			continue
+		}
+
+		items = append(items, database.SearchPostsForUserRow{
+			ID:             post.ID,
+			Title:          post.Title,
+			Url:            post.Url,
+			PublishedAt:    post.PublishedAt,
+			FeedName:       s.feeds[post.FeedID].Name,
+			Rank:           float32(rank),
+			TitleHighlight: highlight(post.Title, title, titleHits, 0, len(title)),
+			Snippet:        snippet(post.Description, description, descriptionHits),
+		})
+	}
+
+	sort.SliceStable(items, func(a, b int) bool {
+		if items[a].Rank != items[b].Rank {
+			return items[a].Rank > items[b].Rank
+		}
+		pa, pb := items[a].PublishedAt, items[b].PublishedAt
+		if pa.Valid != pb.Valid {
+			return pa.Valid
+		}
+		return pa.Time.After(pb.Time)
+	})
+	return page(items, 0, int(arg.Limit)), nil
+}
+
+// highlight returns text from word first up to word last with the hit words marked.
+func highlight(text string, ws []word, hits []bool, first, last int) string {
+	if first >= last {
+		return text
+	}
+	var sb strings.Builder
+	pos := ws[first].start
+	if first == 0 && last == len(ws) {
+		pos = 0
+	}
+	for i := first; i < last; i++ {
+		if !hits[i] {
+			continue
+		}
+		sb.WriteString(text[pos:ws[i].start])
+		sb.WriteString(highlightStart + text[ws[i].start:ws[i].end] + highlightStop)
+		pos = ws[i].end
+	}
+	end := ws[last-1].end
+	if first == 0 && last == len(ws) {
+		end = len(text)
+	}
+	sb.WriteString(text[pos:end])
+	return sb.String()
+}
+
+// snippet is up to snippetWords words of the description starting a little before the first match.
+func snippet(text string, ws []word, hits []bool) string {
+	first := 0
+	for i, hit := range hits {
+		if hit {
+			first = max(i-3, 0)
+			break
+		}
+	}
+	return highlight(text, ws, hits, first, min(first+snippetWords, len(ws)))
+}
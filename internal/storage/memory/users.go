@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.ID]; ok {
+		return database.User{}, duplicate("user id")
+	}
+	for _, usr := range s.users {
+		if usr.Name == arg.Name {
+			return database.User{}, duplicate("user name")
+		}
+	}
+	usr := database.User{ID: arg.ID, CreatedAt: utc(arg.CreatedAt), UpdatedAt: utc(arg.UpdatedAt), Name: arg.Name}
+	s.users[usr.ID] = usr
+	return usr, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, usr := range s.users {
+		if usr.Name == name {
+			return usr, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+// GetUsers lists users in the order they were created, the query itself has no ORDER BY.
+func (s *Store) GetUsers(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, usr := range sortedValues(s.users, func(a, b database.User) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Name < b.Name
+	}) {
+		names = append(names, usr.Name)
+	}
+	return names, nil
+}
+
+func (s *Store) ClearDB(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.users {
+		s.deleteUser(id)
+	}
+	return nil
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.users, func(a, b database.User) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return uuidLess(a.ID, b.ID)
+	}), nil
+}
+
+func (s *Store) RestoreUser(ctx context.Context, arg database.RestoreUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, usr := range s.users {
+		if usr.Name == arg.Name && usr.ID != arg.ID {
+			return duplicate("user name")
+		}
+	}
+	s.users[arg.ID] = database.User{ID: arg.ID, CreatedAt: utc(arg.CreatedAt), UpdatedAt: utc(arg.UpdatedAt), Name: arg.Name}
+	return nil
+}
@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const apiTokenColumns = `id, created_at, name, token_hash, user_id, last_used_at`
+
+func scanAPIToken(row scanner) (database.ApiToken, error) {
+	var i database.ApiToken
+	err := row.Scan(
+		&i.ID,
+		(*scanTime)(&i.CreatedAt),
+		&i.Name,
+		&i.TokenHash,
+		&i.UserID,
+		(*scanNullTime)(&i.LastUsedAt),
+	)
+	return i, err
+}
+
+const createAPIToken = `INSERT INTO api_tokens (id, created_at, name, token_hash, user_id)
+VALUES (?1, ?2, ?3, ?4, ?5)
+RETURNING ` + apiTokenColumns
+
+func (s *Store) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	row := s.db.QueryRowContext(ctx, createAPIToken, arg.ID, timestamp(arg.CreatedAt), arg.Name, arg.TokenHash, arg.UserID)
+	i, err := scanAPIToken(row)
+	return i, translateError(err)
+}
+
+const getAPITokensForUser = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ?1 ORDER BY created_at`
+
+func (s *Store) GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	rows, err := s.db.QueryContext(ctx, getAPITokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.ApiToken
+	for rows.Next() {
+		i, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const touchAPIToken = `UPDATE api_tokens SET last_used_at = ?2 WHERE token_hash = ?1 RETURNING user_id`
+
+const getUserByID = `SELECT id, created_at, updated_at, name FROM users WHERE id = ?1`
+
+func (s *Store) GetUserByAPIToken(ctx context.Context, arg database.GetUserByAPITokenParams) (database.User, error) {
+	var usr database.User
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var userID uuid.UUID
+		if err := tx.QueryRowContext(ctx, touchAPIToken, arg.TokenHash, nullTimestamp(arg.LastUsedAt)).Scan(&userID); err != nil {
+			return err
+		}
+		var err error
+		usr, err = scanUser(tx.QueryRowContext(ctx, getUserByID, userID))
+		return err
+	})
+	return usr, err
+}
+
+const deleteAPIToken = `DELETE FROM api_tokens WHERE id = ?1 AND user_id = ?2`
+
+func (s *Store) DeleteAPIToken(ctx context.Context, arg database.DeleteAPITokenParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, deleteAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listAPITokens = `SELECT ` + apiTokenColumns + ` FROM api_tokens ORDER BY created_at, id`
+
+func (s *Store) ListAPITokens(ctx context.Context) ([]database.ApiToken, error) {
+	rows, err := s.db.QueryContext(ctx, listAPITokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.ApiToken
+	for rows.Next() {
+		i, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restoreAPIToken = `INSERT INTO api_tokens (id, created_at, name, token_hash, user_id, last_used_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = excluded.created_at,
+    name = excluded.name,
+    token_hash = excluded.token_hash,
+    user_id = excluded.user_id,
+    last_used_at = excluded.last_used_at`
+
+func (s *Store) RestoreAPIToken(ctx context.Context, arg database.RestoreAPITokenParams) error {
+	_, err := s.db.ExecContext(ctx, restoreAPIToken,
+		arg.ID, timestamp(arg.CreatedAt), arg.Name, arg.TokenHash, arg.UserID, nullTimestamp(arg.LastUsedAt))
+	return translateError(err)
+}
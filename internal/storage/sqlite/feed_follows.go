@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// SQLite has no INSERT in a WITH, so the names are looked up after the insert in the same transaction.
+const createFeedFollow = `INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)`
+
+const getCreatedFeedFollow = `SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id,
+    feed_follows.feed_id, feed_follows.category, feeds.name AS feed_name, users.name AS user_name
+FROM feed_follows
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+INNER JOIN users ON feed_follows.user_id = users.id
+WHERE feed_follows.id = ?1`
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	var i database.CreateFeedFollowRow
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, createFeedFollow,
+			arg.ID, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt), arg.UserID, arg.FeedID, arg.Category,
+		); err != nil {
+			return translateError(err)
+		}
+		return tx.QueryRowContext(ctx, getCreatedFeedFollow, arg.ID).Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.UserID,
+			&i.FeedID,
+			&i.Category,
+			&i.FeedName,
+			&i.UserName,
+		)
+	})
+	return i, err
+}
+
+const getFeedFollowsForUser = `SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id,
+    feed_follows.feed_id, feed_follows.category, feeds.name AS feed_name, users.name AS user_name, feeds.url AS feed_url,
+    (
+        SELECT COUNT(*) FROM posts
+        LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+        WHERE posts.feed_id = feed_follows.feed_id AND post_reads.post_id IS NULL
+    ) AS unread_count
+FROM feed_follows
+INNER JOIN users ON feed_follows.user_id = users.id
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+WHERE users.id = ?1
+ORDER BY feed_follows.category, feeds.name`
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, id uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	rows, err := s.db.QueryContext(ctx, getFeedFollowsForUser, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetFeedFollowsForUserRow
+	for rows.Next() {
+		var i database.GetFeedFollowsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.UserID,
+			&i.FeedID,
+			&i.Category,
+			&i.FeedName,
+			&i.UserName,
+			&i.FeedUrl,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const deleteFeedFollow = `DELETE FROM feed_follows
+WHERE user_id = ?1 AND feed_id = ?2
+RETURNING id, created_at, updated_at, user_id, feed_id, category`
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) (database.FeedFollow, error) {
+	var i database.FeedFollow
+	err := s.db.QueryRowContext(ctx, deleteFeedFollow, arg.UserID, arg.FeedID).Scan(
+		&i.ID,
+		(*scanTime)(&i.CreatedAt),
+		(*scanTime)(&i.UpdatedAt),
+		&i.UserID,
+		&i.FeedID,
+		&i.Category,
+	)
+	return i, err
+}
+
+const setFeedFollowCategory = `UPDATE feed_follows SET category = ?3, updated_at = ?4
+WHERE user_id = ?1 AND feed_id = ?2`
+
+func (s *Store) SetFeedFollowCategory(ctx context.Context, arg database.SetFeedFollowCategoryParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, setFeedFollowCategory, arg.UserID, arg.FeedID, arg.Category, timestamp(arg.UpdatedAt))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listFeedFollows = `SELECT id, created_at, updated_at, user_id, feed_id, category FROM feed_follows ORDER BY created_at, id`
+
+func (s *Store) ListFeedFollows(ctx context.Context) ([]database.FeedFollow, error) {
+	rows, err := s.db.QueryContext(ctx, listFeedFollows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.FeedFollow
+	for rows.Next() {
+		var i database.FeedFollow
+		if err := rows.Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.UserID,
+			&i.FeedID,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restoreFeedFollow = `INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = excluded.created_at,
+    updated_at = excluded.updated_at,
+    user_id = excluded.user_id,
+    feed_id = excluded.feed_id,
+    category = excluded.category`
+
+func (s *Store) RestoreFeedFollow(ctx context.Context, arg database.RestoreFeedFollowParams) error {
+	_, err := s.db.ExecContext(ctx, restoreFeedFollow,
+		arg.ID, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt), arg.UserID, arg.FeedID, arg.Category)
+	return translateError(err)
+}
@@ -0,0 +1,335 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const feedColumns = `feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at,
+    feeds.etag, feeds.last_modified, feeds.last_body_size, feeds.lease_owner, feeds.lease_expires_at, feeds.last_error,
+    feeds.last_error_at, feeds.consecutive_failures, feeds.next_fetch_at, feeds.disabled, feeds.hinted_interval_seconds,
+    feeds.skip_hours, feeds.skip_days, feeds.interval_override_seconds, feeds.retention_max_age_seconds,
+    feeds.retention_max_posts, feeds.retention_keep_unread, feeds.public_only`
+
+func scanFeed(row scanner) (database.Feed, error) {
+	var i database.Feed
+	err := row.Scan(
+		&i.ID,
+		(*scanTime)(&i.CreatedAt),
+		(*scanTime)(&i.UpdatedAt),
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		(*scanNullTime)(&i.LastFetchedAt),
+		&i.Etag,
+		&i.LastModified,
+		&i.LastBodySize,
+		&i.LeaseOwner,
+		(*scanNullTime)(&i.LeaseExpiresAt),
+		&i.LastError,
+		(*scanNullTime)(&i.LastErrorAt),
+		&i.ConsecutiveFailures,
+		(*scanNullTime)(&i.NextFetchAt),
+		&i.Disabled,
+		&i.HintedIntervalSeconds,
+		(*scanInt32s)(&i.SkipHours),
+		(*scanStrings)(&i.SkipDays),
+		&i.IntervalOverrideSeconds,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.RetentionKeepUnread,
+		&i.PublicOnly,
+	)
+	return i, err
+}
+
+const createFeed = `INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, public_only)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING ` + feedColumns
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	row := s.db.QueryRowContext(ctx, createFeed,
+		arg.ID, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt), arg.Name, arg.Url, arg.UserID, arg.PublicOnly)
+	i, err := scanFeed(row)
+	return i, translateError(err)
+}
+
+const getFeed = `SELECT ` + feedColumns + ` FROM feeds WHERE url = ?1`
+
+func (s *Store) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	return scanFeed(s.db.QueryRowContext(ctx, getFeed, url))
+}
+
+const getFeeds = `SELECT f.name, f.url, u.name AS username FROM feeds f INNER JOIN users u ON u.id = f.user_id`
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error) {
+	rows, err := s.db.QueryContext(ctx, getFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetFeedsRow
+	for rows.Next() {
+		var i database.GetFeedsRow
+		if err := rows.Scan(&i.Name, &i.Url, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const getFeedErrors = `SELECT f.name, f.url, f.last_error, f.last_error_at, f.consecutive_failures, f.next_fetch_at, f.disabled
+FROM feeds f
+WHERE f.consecutive_failures > 0 OR f.disabled
+ORDER BY f.disabled DESC, f.consecutive_failures DESC, f.url`
+
+func (s *Store) GetFeedErrors(ctx context.Context) ([]database.GetFeedErrorsRow, error) {
+	rows, err := s.db.QueryContext(ctx, getFeedErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetFeedErrorsRow
+	for rows.Next() {
+		var i database.GetFeedErrorsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.LastError,
+			(*scanNullTime)(&i.LastErrorAt),
+			&i.ConsecutiveFailures,
+			(*scanNullTime)(&i.NextFetchAt),
+			&i.Disabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// SQLite has a single writer so the UPDATE is atomic without Postgres' FOR UPDATE SKIP LOCKED.
+const claimFeedsToFetch = `UPDATE feeds SET updated_at = ?1, last_fetched_at = ?1, lease_owner = ?2, lease_expires_at = ?3
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE NOT feeds.disabled
+        AND (feeds.next_fetch_at IS NULL OR feeds.next_fetch_at <= ?1)
+        AND (feeds.lease_expires_at IS NULL OR feeds.lease_expires_at < ?1)
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT ?4
+)
+RETURNING ` + feedColumns
+
+func (s *Store) ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error) {
+	rows, err := s.db.QueryContext(ctx, claimFeedsToFetch, timestamp(arg.Now), arg.Owner, nullTimestamp(arg.LeaseExpiresAt), arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.Feed
+	for rows.Next() {
+		i, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const releaseFeedLease = `UPDATE feeds SET lease_owner = '', lease_expires_at = NULL WHERE id = ?1 AND lease_owner = ?2`
+
+func (s *Store) ReleaseFeedLease(ctx context.Context, arg database.ReleaseFeedLeaseParams) error {
+	_, err := s.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.LeaseOwner)
+	return err
+}
+
+const updateFeedCache = `UPDATE feeds SET etag = ?2, last_modified = ?3, last_body_size = ?4 WHERE id = ?1`
+
+func (s *Store) UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error {
+	_, err := s.db.ExecContext(ctx, updateFeedCache, arg.ID, arg.Etag, arg.LastModified, arg.LastBodySize)
+	return err
+}
+
+const recordFeedFailure = `UPDATE feeds SET last_error = ?1, last_error_at = ?2,
+    consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = ?3,
+    disabled = consecutive_failures + 1 >= ?4
+WHERE id = ?5
+RETURNING ` + feedColumns
+
+func (s *Store) RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.Feed, error) {
+	row := s.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.Error, nullTimestamp(arg.At), nullTimestamp(arg.NextFetchAt), arg.MaxFailures, arg.ID)
+	return scanFeed(row)
+}
+
+const recordFeedSuccess = `UPDATE feeds SET consecutive_failures = 0, next_fetch_at = ?2 WHERE id = ?1`
+
+func (s *Store) RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error {
+	_, err := s.db.ExecContext(ctx, recordFeedSuccess, arg.ID, nullTimestamp(arg.NextFetchAt))
+	return err
+}
+
+const enableFeed = `UPDATE feeds SET disabled = false, consecutive_failures = 0, next_fetch_at = NULL WHERE url = ?1`
+
+func (s *Store) EnableFeed(ctx context.Context, url string) (int64, error) {
+	result, err := s.db.ExecContext(ctx, enableFeed, url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedScheduleHints = `UPDATE feeds SET hinted_interval_seconds = ?2, skip_hours = ?3, skip_days = ?4 WHERE id = ?1`
+
+func (s *Store) UpdateFeedScheduleHints(ctx context.Context, arg database.UpdateFeedScheduleHintsParams) error {
+	skipHours, err := jsonArray(arg.SkipHours)
+	if err != nil {
+		return err
+	}
+	skipDays, err := jsonArray(arg.SkipDays)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, updateFeedScheduleHints, arg.ID, arg.HintedIntervalSeconds, skipHours, skipDays)
+	return err
+}
+
+const setFeedIntervalOverride = `UPDATE feeds SET interval_override_seconds = ?2, next_fetch_at = NULL WHERE url = ?1`
+
+func (s *Store) SetFeedIntervalOverride(ctx context.Context, arg database.SetFeedIntervalOverrideParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, setFeedIntervalOverride, arg.Url, arg.IntervalOverrideSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedRetention = `UPDATE feeds SET retention_max_age_seconds = ?2, retention_max_posts = ?3,
+    retention_keep_unread = ?4
+WHERE url = ?1`
+
+func (s *Store) SetFeedRetention(ctx context.Context, arg database.SetFeedRetentionParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, setFeedRetention,
+		arg.Url, arg.RetentionMaxAgeSeconds, arg.RetentionMaxPosts, arg.RetentionKeepUnread)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedRetentions = `SELECT id, name, url, retention_max_age_seconds, retention_max_posts, retention_keep_unread
+FROM feeds
+ORDER BY name, url`
+
+func (s *Store) GetFeedRetentions(ctx context.Context) ([]database.GetFeedRetentionsRow, error) {
+	rows, err := s.db.QueryContext(ctx, getFeedRetentions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetFeedRetentionsRow
+	for rows.Next() {
+		var i database.GetFeedRetentionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.RetentionKeepUnread,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const listFeeds = `SELECT ` + feedColumns + ` FROM feeds ORDER BY created_at, id`
+
+func (s *Store) ListFeeds(ctx context.Context) ([]database.Feed, error) {
+	rows, err := s.db.QueryContext(ctx, listFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.Feed
+	for rows.Next() {
+		i, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// Leases belong to the agg process that took them and aren't restored.
+const restoreFeed = `INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size,
+    last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days,
+    interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18, ?19, ?20, ?21, ?22, ?23)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = excluded.created_at,
+    updated_at = excluded.updated_at,
+    name = excluded.name,
+    url = excluded.url,
+    user_id = excluded.user_id,
+    last_fetched_at = excluded.last_fetched_at,
+    etag = excluded.etag,
+    last_modified = excluded.last_modified,
+    last_body_size = excluded.last_body_size,
+    last_error = excluded.last_error,
+    last_error_at = excluded.last_error_at,
+    consecutive_failures = excluded.consecutive_failures,
+    next_fetch_at = excluded.next_fetch_at,
+    disabled = excluded.disabled,
+    hinted_interval_seconds = excluded.hinted_interval_seconds,
+    skip_hours = excluded.skip_hours,
+    skip_days = excluded.skip_days,
+    interval_override_seconds = excluded.interval_override_seconds,
+    retention_max_age_seconds = excluded.retention_max_age_seconds,
+    retention_max_posts = excluded.retention_max_posts,
+    retention_keep_unread = excluded.retention_keep_unread,
+    public_only = excluded.public_only`
+
+func (s *Store) RestoreFeed(ctx context.Context, arg database.RestoreFeedParams) error {
+	skipHours, err := jsonArray(arg.SkipHours)
+	if err != nil {
+		return err
+	}
+	skipDays, err := jsonArray(arg.SkipDays)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, restoreFeed,
+		arg.ID,
+		timestamp(arg.CreatedAt),
+		timestamp(arg.UpdatedAt),
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		nullTimestamp(arg.LastFetchedAt),
+		arg.Etag,
+		arg.LastModified,
+		arg.LastBodySize,
+		arg.LastError,
+		nullTimestamp(arg.LastErrorAt),
+		arg.ConsecutiveFailures,
+		nullTimestamp(arg.NextFetchAt),
+		arg.Disabled,
+		arg.HintedIntervalSeconds,
+		skipHours,
+		skipDays,
+		arg.IntervalOverrideSeconds,
+		arg.RetentionMaxAgeSeconds,
+		arg.RetentionMaxPosts,
+		arg.RetentionKeepUnread,
+		arg.PublicOnly,
+	)
+	return translateError(err)
+}
@@ -0,0 +1,121 @@
+-- +goose Up
+-- The Postgres schema in sql/schema as of 017.  Times are stored as UTC text that sorts in time order,
+-- arrays as JSON and uuids as text.
+CREATE TABLE users(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE feeds(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    user_id TEXT NOT NULL,
+    last_fetched_at TIMESTAMP,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    last_body_size INTEGER NOT NULL DEFAULT 0,
+    lease_owner TEXT NOT NULL DEFAULT '',
+    lease_expires_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_error_at TIMESTAMP,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    next_fetch_at TIMESTAMP,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    hinted_interval_seconds INTEGER NOT NULL DEFAULT 0,
+    skip_hours TEXT NOT NULL DEFAULT '[]',
+    skip_days TEXT NOT NULL DEFAULT '[]',
+    interval_override_seconds INTEGER,
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE feed_follows(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    feed_id TEXT NOT NULL,
+    category TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_feeds FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    UNIQUE(user_id, feed_id)
+);
+
+-- seq is a stable rowid for posts_fts to point at, VACUUM can renumber an implicit rowid
+CREATE TABLE posts(
+    seq INTEGER PRIMARY KEY,
+    id TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT NOT NULL,
+    published_at TIMESTAMP,
+    feed_id TEXT NOT NULL,
+    author TEXT NOT NULL DEFAULT '',
+    categories TEXT NOT NULL DEFAULT '[]',
+    guid TEXT NOT NULL,
+    CONSTRAINT fk_feeds FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    UNIQUE(feed_id, guid)
+);
+CREATE INDEX posts_published_sort_idx ON posts (COALESCE(published_at, created_at), id);
+CREATE INDEX posts_created_at_sort_idx ON posts (created_at, id);
+
+CREATE TABLE api_tokens(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    user_id TEXT NOT NULL,
+    last_used_at TIMESTAMP,
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE post_reads(
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    read_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, post_id),
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_posts FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE post_stars(
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, post_id),
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_posts FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Full text search, the counterpart of posts.search_vector.  Kept in step with posts by the triggers.
+CREATE VIRTUAL TABLE posts_fts USING fts4(title, description, tokenize=porter);
+
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (docid, title, description) VALUES (new.seq, new.title, new.description);
+END;
+
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, description ON posts BEGIN
+    UPDATE posts_fts SET title = new.title, description = new.description WHERE docid = old.seq;
+END;
+
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_fts WHERE docid = old.seq;
+END;
+
+-- +goose Down
+DROP TABLE posts_fts;
+DROP TABLE post_stars;
+DROP TABLE post_reads;
+DROP TABLE api_tokens;
+DROP TABLE posts;
+DROP TABLE feed_follows;
+DROP TABLE feeds;
+DROP TABLE users;
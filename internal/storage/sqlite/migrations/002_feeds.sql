@@ -0,0 +1,10 @@
+-- +goose Up
+-- sql/schema/018_feeds.sql, per-feed retention overrides
+ALTER TABLE feeds ADD retention_max_age_seconds INTEGER;
+ALTER TABLE feeds ADD retention_max_posts INTEGER;
+ALTER TABLE feeds ADD retention_keep_unread BOOLEAN;
+
+-- +goose Down
+ALTER TABLE feeds DROP retention_keep_unread;
+ALTER TABLE feeds DROP retention_max_posts;
+ALTER TABLE feeds DROP retention_max_age_seconds;
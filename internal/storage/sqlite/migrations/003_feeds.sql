@@ -0,0 +1,6 @@
+-- +goose Up
+-- sql/schema/019_feeds.sql, feeds only fetched from public addresses
+ALTER TABLE feeds ADD public_only BOOLEAN NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds DROP public_only;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- sql/schema/020_pruned_posts.sql, items prune deleted that aren't stored again
+CREATE TABLE pruned_posts(
+    feed_id TEXT NOT NULL,
+    guid TEXT NOT NULL,
+    PRIMARY KEY(feed_id, guid),
+    CONSTRAINT fk_feeds FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const markPostRead = `INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES (?1, ?2, ?3)
+ON CONFLICT (user_id, post_id) DO NOTHING`
+
+func (s *Store) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	_, err := s.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID, timestamp(arg.ReadAt))
+	return err
+}
+
+const markPostUnread = `DELETE FROM post_reads WHERE user_id = ?1 AND post_id = ?2`
+
+func (s *Store) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error {
+	_, err := s.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	return err
+}
+
+const markPostsRead = `INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, ?1
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = ?2
+    AND (?3 IS NULL OR posts.feed_id = ?3)
+    AND (?4 IS NULL OR COALESCE(posts.published_at, posts.created_at) < ?4)
+ON CONFLICT (user_id, post_id) DO NOTHING`
+
+func (s *Store) MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, markPostsRead, timestamp(arg.ReadAt), arg.UserID, arg.FeedID, nullTimestamp(arg.Before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listPostReads = `SELECT user_id, post_id, read_at FROM post_reads ORDER BY user_id, post_id`
+
+func (s *Store) ListPostReads(ctx context.Context) ([]database.PostRead, error) {
+	rows, err := s.db.QueryContext(ctx, listPostReads)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.PostRead
+	for rows.Next() {
+		var i database.PostRead
+		if err := rows.Scan(&i.UserID, &i.PostID, (*scanTime)(&i.ReadAt)); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restorePostRead = `INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES (?1, ?2, ?3)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = excluded.read_at`
+
+func (s *Store) RestorePostRead(ctx context.Context, arg database.RestorePostReadParams) error {
+	_, err := s.db.ExecContext(ctx, restorePostRead, arg.UserID, arg.PostID, timestamp(arg.ReadAt))
+	return err
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const starPost = `INSERT INTO post_stars (user_id, post_id, note, created_at, updated_at)
+VALUES (?1, ?2, COALESCE(?3, ''), ?4, ?4)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+    note = COALESCE(?3, post_stars.note),
+    updated_at = excluded.updated_at
+RETURNING user_id, post_id, note, created_at, updated_at`
+
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) (database.PostStar, error) {
+	var i database.PostStar
+	err := s.db.QueryRowContext(ctx, starPost, arg.UserID, arg.PostID, arg.Note, timestamp(arg.StarredAt)).Scan(
+		&i.UserID,
+		&i.PostID,
+		&i.Note,
+		(*scanTime)(&i.CreatedAt),
+		(*scanTime)(&i.UpdatedAt),
+	)
+	return i, err
+}
+
+const unstarPost = `DELETE FROM post_stars WHERE user_id = ?1 AND post_id = ?2`
+
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getStarredPostsForUser = `SELECT ` + postColumns + `, feeds.name AS feed_name, feeds.url AS feed_url, post_stars.note,
+    post_stars.created_at AS starred_at
+FROM post_stars
+INNER JOIN posts ON post_stars.post_id = posts.id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_stars.user_id = ?1
+ORDER BY post_stars.created_at DESC`
+
+func (s *Store) GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetStarredPostsForUserRow, error) {
+	rows, err := s.db.QueryContext(ctx, getStarredPostsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetStarredPostsForUserRow
+	for rows.Next() {
+		var i database.GetStarredPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			(*scanNullTime)(&i.PublishedAt),
+			&i.FeedID,
+			&i.Author,
+			(*scanStrings)(&i.Categories),
+			&i.Guid,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Note,
+			(*scanTime)(&i.StarredAt),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const listPostStars = `SELECT user_id, post_id, note, created_at, updated_at FROM post_stars ORDER BY user_id, post_id`
+
+func (s *Store) ListPostStars(ctx context.Context) ([]database.PostStar, error) {
+	rows, err := s.db.QueryContext(ctx, listPostStars)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.PostStar
+	for rows.Next() {
+		var i database.PostStar
+		if err := rows.Scan(
+			&i.UserID,
+			&i.PostID,
+			&i.Note,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restorePostStar = `INSERT INTO post_stars (user_id, post_id, note, created_at, updated_at)
+VALUES (?1, ?2, ?3, ?4, ?5)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+    note = excluded.note,
+    created_at = excluded.created_at,
+    updated_at = excluded.updated_at`
+
+func (s *Store) RestorePostStar(ctx context.Context, arg database.RestorePostStarParams) error {
+	_, err := s.db.ExecContext(ctx, restorePostStar,
+		arg.UserID, arg.PostID, arg.Note, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt))
+	return err
+}
@@ -0,0 +1,435 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const postColumns = `posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at,
+    posts.feed_id, posts.author, posts.categories, posts.guid`
+
+// An unchanged duplicate returns no row, like the Postgres upsert, and so does an item whose post was pruned.
+// There's no xmax to tell an insert from an update, but an insert keeps the new id while an update keeps the
+// stored one.
+const createPost = `INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = ?8 AND pp.guid = ?11)
+ON CONFLICT (feed_id, guid) DO UPDATE SET
+    updated_at = excluded.updated_at,
+    title = excluded.title,
+    url = excluded.url,
+    description = excluded.description,
+    author = excluded.author,
+    categories = excluded.categories
+WHERE posts.title IS NOT excluded.title
+    OR posts.url IS NOT excluded.url
+    OR posts.description IS NOT excluded.description
+RETURNING ` + postColumns
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.CreatePostRow, error) {
+	categories, err := jsonArray(arg.Categories)
+	if err != nil {
+		return database.CreatePostRow{}, err
+	}
+	row := s.db.QueryRowContext(ctx, createPost,
+		arg.ID,
+		timestamp(arg.CreatedAt),
+		timestamp(arg.UpdatedAt),
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		nullTimestamp(arg.PublishedAt),
+		arg.FeedID,
+		arg.Author,
+		categories,
+		arg.Guid,
+	)
+	var i database.CreatePostRow
+	err = row.Scan(
+		&i.ID,
+		(*scanTime)(&i.CreatedAt),
+		(*scanTime)(&i.UpdatedAt),
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		(*scanNullTime)(&i.PublishedAt),
+		&i.FeedID,
+		&i.Author,
+		(*scanStrings)(&i.Categories),
+		&i.Guid,
+	)
+	i.Inserted = err == nil && i.ID == arg.ID
+	return i, translateError(err)
+}
+
+const adoptLegacyPost = `UPDATE posts SET guid = ?1
+WHERE feed_id = ?2 AND guid = ?3 AND url = ?3
+    AND NOT EXISTS (SELECT 1 FROM posts taken WHERE taken.feed_id = ?2 AND taken.guid = ?1)`
+
+func (s *Store) AdoptLegacyPost(ctx context.Context, arg database.AdoptLegacyPostParams) (int64, error) {
+	result, err := s.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected()
+}
+
+const getFeedPostCadence = `SELECT COALESCE((julianday(MAX(published_at)) - julianday(MIN(published_at))) * 86400.0 / NULLIF(COUNT(*) - 1, 0), 0.0) AS avg_gap_seconds
+FROM (
+    SELECT published_at FROM posts
+    WHERE feed_id = ?1 AND published_at IS NOT NULL
+    ORDER BY published_at DESC LIMIT 20
+) recent`
+
+func (s *Store) GetFeedPostCadence(ctx context.Context, feedID uuid.UUID) (float64, error) {
+	var avgGapSeconds float64
+	err := s.db.QueryRowContext(ctx, getFeedPostCadence, feedID).Scan(&avgGapSeconds)
+	return avgGapSeconds, err
+}
+
+// userPosts joins posts to the user's follows with their read state, like the Postgres queries.
+const userPosts = `FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id`
+
+const getPostForUser = `SELECT ` + postColumns + `, feeds.name AS feed_name, feeds.url AS feed_url, post_reads.read_at IS NOT NULL AS read
+` + userPosts + `
+WHERE posts.id = ?1 AND feed_follows.user_id = ?2`
+
+func (s *Store) GetPostForUser(ctx context.Context, arg database.GetPostForUserParams) (database.GetPostForUserRow, error) {
+	var i database.GetPostForUserRow
+	err := s.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID).Scan(
+		&i.ID,
+		(*scanTime)(&i.CreatedAt),
+		(*scanTime)(&i.UpdatedAt),
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		(*scanNullTime)(&i.PublishedAt),
+		&i.FeedID,
+		&i.Author,
+		(*scanStrings)(&i.Categories),
+		&i.Guid,
+		&i.FeedName,
+		&i.FeedUrl,
+		&i.Read,
+	)
+	return i, err
+}
+
+const listPostsForUser = `SELECT ` + postColumns + `, feeds.name AS feed_name, feeds.url AS feed_url, post_reads.read_at IS NOT NULL AS read
+` + userPosts + `
+WHERE feed_follows.user_id = ?1
+    AND (?2 IS NULL OR posts.feed_id = ?2)
+    AND (?3 IS NULL OR posts.published_at >= ?3)
+    AND (?4 IS NULL OR posts.published_at < ?4)
+    AND (NOT ?5 OR post_reads.post_id IS NULL)
+ORDER BY posts.published_at DESC NULLS LAST, posts.id
+LIMIT ?6 OFFSET ?7`
+
+func (s *Store) ListPostsForUser(ctx context.Context, arg database.ListPostsForUserParams) ([]database.ListPostsForUserRow, error) {
+	rows, err := s.db.QueryContext(ctx, listPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		nullTimestamp(arg.Since),
+		nullTimestamp(arg.Until),
+		arg.UnreadOnly,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.ListPostsForUserRow
+	for rows.Next() {
+		var i database.ListPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			(*scanNullTime)(&i.PublishedAt),
+			&i.FeedID,
+			&i.Author,
+			(*scanStrings)(&i.Categories),
+			&i.Guid,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Read,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// browseQuery builds one of the four keyset browse queries.  key is the sort expression, it has an index
+// on (key, id) just like in Postgres.
+func browseQuery(key, direction string) string {
+	cmp := "<"
+	if direction == "ASC" {
+		cmp = ">"
+	}
+	return `SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at,
+    feeds.name AS feed_name, post_reads.post_id IS NOT NULL AS read, ` + key + ` AS sort_at
+` + userPosts + `
+WHERE feed_follows.user_id = ?1
+    AND (?2 IS NULL OR feeds.url = ?2 OR lower(feeds.name) = lower(?2))
+    AND (?3 IS NULL OR ` + key + ` >= ?3)
+    AND (?4 IS NULL OR ` + key + ` < ?4)
+    AND (NOT ?5 OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM json_each(?6) AS word WHERE posts.title || ' ' || posts.description NOT LIKE '%' || word.value || '%' ESCAPE '\')
+    AND NOT EXISTS (SELECT 1 FROM json_each(?7) AS word WHERE posts.title || ' ' || posts.description LIKE '%' || word.value || '%' ESCAPE '\')
+    AND (?8 IS NULL OR (` + key + `, posts.id) ` + cmp + ` (?8, ?9))
+ORDER BY ` + key + ` ` + direction + `, posts.id ` + direction + `
+LIMIT ?10`
+}
+
+const (
+	publishedKey = "COALESCE(posts.published_at, posts.created_at)"
+	fetchedKey   = "posts.created_at"
+)
+
+var (
+	browsePostsByPublished        = browseQuery(publishedKey, "DESC")
+	browsePostsByPublishedReverse = browseQuery(publishedKey, "ASC")
+	browsePostsByFetched          = browseQuery(fetchedKey, "DESC")
+	browsePostsByFetchedReverse   = browseQuery(fetchedKey, "ASC")
+)
+
+// All four browse queries share their params and rows, they're handled as the published ones.
+func (s *Store) browse(ctx context.Context, query string, arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+	include, err := jsonArray(arg.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := jsonArray(arg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query,
+		arg.UserID,
+		arg.Feed,
+		nullTimestamp(arg.Since),
+		nullTimestamp(arg.Until),
+		arg.UnreadOnly,
+		include,
+		exclude,
+		nullTimestamp(arg.CursorAt),
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.BrowsePostsByPublishedRow
+	for rows.Next() {
+		var i database.BrowsePostsByPublishedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			(*scanNullTime)(&i.PublishedAt),
+			(*scanTime)(&i.CreatedAt),
+			&i.FeedName,
+			&i.Read,
+			(*scanTime)(&i.SortAt),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func (s *Store) BrowsePostsByPublished(ctx context.Context, arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+	return s.browse(ctx, browsePostsByPublished, arg)
+}
+
+func (s *Store) BrowsePostsByPublishedReverse(ctx context.Context, arg database.BrowsePostsByPublishedReverseParams) ([]database.BrowsePostsByPublishedReverseRow, error) {
+	rows, err := s.browse(ctx, browsePostsByPublishedReverse, database.BrowsePostsByPublishedParams(arg))
+	items := make([]database.BrowsePostsByPublishedReverseRow, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, database.BrowsePostsByPublishedReverseRow(row))
+	}
+	return nilIfEmpty(items), err
+}
+
+func (s *Store) BrowsePostsByFetched(ctx context.Context, arg database.BrowsePostsByFetchedParams) ([]database.BrowsePostsByFetchedRow, error) {
+	rows, err := s.browse(ctx, browsePostsByFetched, database.BrowsePostsByPublishedParams(arg))
+	items := make([]database.BrowsePostsByFetchedRow, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, database.BrowsePostsByFetchedRow(row))
+	}
+	return nilIfEmpty(items), err
+}
+
+func (s *Store) BrowsePostsByFetchedReverse(ctx context.Context, arg database.BrowsePostsByFetchedReverseParams) ([]database.BrowsePostsByFetchedReverseRow, error) {
+	rows, err := s.browse(ctx, browsePostsByFetchedReverse, database.BrowsePostsByPublishedParams(arg))
+	items := make([]database.BrowsePostsByFetchedReverseRow, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, database.BrowsePostsByFetchedReverseRow(row))
+	}
+	return nilIfEmpty(items), err
+}
+
+// prunable selects the posts PrunePosts deletes, the same rules as the Postgres query.
+const prunable = `SELECT ranked.id FROM (
+        SELECT p.id, COALESCE(p.published_at, p.created_at) AS sort_at,
+            ROW_NUMBER() OVER (ORDER BY COALESCE(p.published_at, p.created_at) DESC, p.id DESC) AS position
+        FROM posts p
+        WHERE p.feed_id = ?1
+    ) ranked
+    WHERE ((?2 IS NOT NULL AND ranked.sort_at < ?2)
+            OR (?3 IS NOT NULL AND ranked.position > ?3))
+        AND NOT (?4 AND EXISTS (
+            SELECT 1 FROM feed_follows ff
+            WHERE ff.feed_id = ?1
+                AND NOT EXISTS (SELECT 1 FROM post_reads r WHERE r.post_id = ranked.id AND r.user_id = ff.user_id)
+        ))
+        AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = ranked.id)`
+
+const countPrunablePosts = `SELECT COUNT(*) FROM posts WHERE posts.id IN (` + prunable + `)`
+
+func (s *Store) CountPrunablePosts(ctx context.Context, arg database.CountPrunablePostsParams) (int64, error) {
+	var count int64
+	err := s.db.QueryRowContext(ctx, countPrunablePosts,
+		arg.FeedID, nullTimestamp(arg.Before), arg.MaxPosts, arg.KeepUnread).Scan(&count)
+	return count, err
+}
+
+const (
+	tombstonePosts = `INSERT OR IGNORE INTO pruned_posts (feed_id, guid)
+SELECT feed_id, guid FROM posts WHERE posts.id IN (` + prunable + `)`
+	prunePosts = `DELETE FROM posts WHERE posts.id IN (` + prunable + `)`
+)
+
+// PrunePosts records the guids before deleting, in one transaction so both see the same posts.
+func (s *Store) PrunePosts(ctx context.Context, arg database.PrunePostsParams) (int64, error) {
+	var n int64
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		args := []any{arg.FeedID, nullTimestamp(arg.Before), arg.MaxPosts, arg.KeepUnread}
+		if _, err := tx.ExecContext(ctx, tombstonePosts, args...); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, prunePosts, args...)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// nilIfEmpty matches sqlc, which returns a nil slice when there are no rows.
+func nilIfEmpty[T any](items []T) []T {
+	if len(items) == 0 {
+		return nil
+	}
+	return items
+}
+
+const listPosts = `SELECT ` + postColumns + ` FROM posts WHERE id > ?1 ORDER BY id LIMIT ?2`
+
+func (s *Store) ListPosts(ctx context.Context, arg database.ListPostsParams) ([]database.ListPostsRow, error) {
+	rows, err := s.db.QueryContext(ctx, listPosts, arg.After, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.ListPostsRow
+	for rows.Next() {
+		var i database.ListPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			(*scanTime)(&i.CreatedAt),
+			(*scanTime)(&i.UpdatedAt),
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			(*scanNullTime)(&i.PublishedAt),
+			&i.FeedID,
+			&i.Author,
+			(*scanStrings)(&i.Categories),
+			&i.Guid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// posts_fts follows along through the insert and update triggers.
+const restorePost = `INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = excluded.created_at,
+    updated_at = excluded.updated_at,
+    title = excluded.title,
+    url = excluded.url,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    feed_id = excluded.feed_id,
+    author = excluded.author,
+    categories = excluded.categories,
+    guid = excluded.guid`
+
+func (s *Store) RestorePost(ctx context.Context, arg database.RestorePostParams) error {
+	categories, err := jsonArray(arg.Categories)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, restorePost,
+		arg.ID,
+		timestamp(arg.CreatedAt),
+		timestamp(arg.UpdatedAt),
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		nullTimestamp(arg.PublishedAt),
+		arg.FeedID,
+		arg.Author,
+		categories,
+		arg.Guid,
+	)
+	return translateError(err)
+}
+
+const listPrunedPosts = `SELECT feed_id, guid FROM pruned_posts ORDER BY feed_id, guid`
+
+func (s *Store) ListPrunedPosts(ctx context.Context) ([]database.PrunedPost, error) {
+	rows, err := s.db.QueryContext(ctx, listPrunedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.PrunedPost
+	for rows.Next() {
+		var i database.PrunedPost
+		if err := rows.Scan(&i.FeedID, &i.Guid); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restorePrunedPost = `INSERT INTO pruned_posts (feed_id, guid) VALUES (?1, ?2) ON CONFLICT DO NOTHING`
+
+func (s *Store) RestorePrunedPost(ctx context.Context, arg database.RestorePrunedPostParams) error {
+	_, err := s.db.ExecContext(ctx, restorePrunedPost, arg.FeedID, arg.Guid)
+	return translateError(err)
+}
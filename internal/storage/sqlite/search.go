@@ -0,0 +1,211 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// Marks put around matches, the same ones the Postgres query asks ts_headline for.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// Column weights for ranking, ts_rank's defaults for the A (title) and B (description) weights.
+var columnWeights = []float64{1.0, 0.4}
+
+// Posts are ranked in Go, so every match is read before the limit is applied.
+const searchPostsForUser = `SELECT posts.id, posts.title, posts.url, posts.published_at, feeds.name AS feed_name,
+    matchinfo(posts_fts, 'pcx'), offsets(posts_fts), snippet(posts_fts, char(1), char(2), '', 1, 25)
+FROM posts_fts
+INNER JOIN posts ON posts.seq = posts_fts.docid
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts_fts MATCH ?1
+    AND feed_follows.user_id = ?2
+    AND (?3 IS NULL OR feeds.url = ?3 OR lower(feeds.name) = lower(?3))
+    AND (?4 IS NULL OR posts.published_at < ?4)
+    AND (?5 IS NULL OR posts.published_at >= ?5)`
+
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	match := matchExpression(arg.Query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, searchPostsForUser,
+		match, arg.UserID, arg.Feed, nullTimestamp(arg.Before), nullTimestamp(arg.After))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.SearchPostsForUserRow
+	for rows.Next() {
+		var i database.SearchPostsForUserRow
+		var matchInfo []byte
+		var offsets string
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			(*scanNullTime)(&i.PublishedAt),
+			&i.FeedName,
+			&matchInfo,
+			&offsets,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		i.Rank = float32(rank(matchInfo))
+		i.TitleHighlight = highlightTitle(i.Title, offsets)
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(a, b int) bool {
+		if items[a].Rank != items[b].Rank {
+			return items[a].Rank > items[b].Rank
+		}
+		pa, pb := items[a].PublishedAt, items[b].PublishedAt
+		if pa.Valid != pb.Valid {
+			return pa.Valid
+		}
+		return pa.Time.After(pb.Time)
+	})
+	if len(items) > int(arg.Limit) {
+		items = items[:arg.Limit]
+	}
+	return items, nil
+}
+
+// matchExpression turns websearch syntax into an FTS4 query: "quoted phrases", OR and -word.  Every term
+// is sent as a quoted phrase so punctuation can't be read as FTS syntax.  FTS4 can't search for posts
+// that only lack words, so a query with nothing but -words matches nothing.
+func matchExpression(query string) string {
+	var groups [][]string
+	var exclude []string
+	or := false
+	for _, token := range splitQuery(query) {
+		if strings.EqualFold(token, "or") {
+			or = len(groups) > 0
+			continue
+		}
+		negate := strings.HasPrefix(token, "-")
+		term := strings.TrimSpace(strings.ReplaceAll(strings.TrimPrefix(token, "-"), `"`, " "))
+		if term == "" {
+			continue
+		}
+		phrase := `"` + term + `"`
+		switch {
+		case negate:
+			exclude = append(exclude, phrase)
+		case or:
+			groups[len(groups)-1] = append(groups[len(groups)-1], phrase)
+		default:
+			groups = append(groups, []string{phrase})
+		}
+		or = false
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		parts[i] = strings.Join(group, " OR ")
+		if len(group) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	expr := strings.Join(parts, " AND ")
+	for _, phrase := range exclude {
+		expr += " NOT " + phrase
+	}
+	return expr
+}
+
+// splitQuery splits on spaces outside of double quotes, keeping the quotes.
+func splitQuery(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// rank scores a row from matchinfo's 'pcx' blob: for every phrase and column the share of the phrase's hits
+// across all posts that are in this one, weighted by column.
+func rank(matchInfo []byte) float64 {
+	values := make([]uint32, len(matchInfo)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(matchInfo[i*4:])
+	}
+	if len(values) < 2 {
+		return 0
+	}
+	phrases, columns := int(values[0]), int(values[1])
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns && c < len(columnWeights); c++ {
+			at := 2 + 3*(p*columns+c)
+			if at+1 >= len(values) || values[at+1] == 0 {
+				continue
+			}
+			score += columnWeights[c] * float64(values[at]) / float64(values[at+1])
+		}
+	}
+	return score
+}
+
+// highlightTitle marks the matches offsets() found in the title.  offsets() is a list of
+// "column term byte-offset size" for every match.
+func highlightTitle(title, offsets string) string {
+	fields := strings.Fields(offsets)
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i+3 < len(fields); i += 4 {
+		column, err1 := strconv.Atoi(fields[i])
+		start, err2 := strconv.Atoi(fields[i+2])
+		size, err3 := strconv.Atoi(fields[i+3])
+		if err1 != nil || err2 != nil || err3 != nil || column != 0 || start+size > len(title) {
+			continue
+		}
+		spans = append(spans, span{start, start + size})
+	}
+	sort.Slice(spans, func(a, b int) bool { return spans[a].start < spans[b].start })
+
+	var sb strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		sb.WriteString(title[last:s.start])
+		sb.WriteString(highlightStart + title[s.start:s.end] + highlightStop)
+		last = s.end
+	}
+	sb.WriteString(title[last:])
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+// Package sqlite is the SQLite backend, for single user installs that don't want to run a Postgres server.
+// It implements storage.Store with the same results and ordering as the Postgres queries in sql/queries.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/storage"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migrations is the SQLite schema, the counterpart of sql/schema.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrationFS, "migrations")
+	if err != nil {
+		// migrations is embedded, it can't be missing
+		panic(err)
+	}
+	return sub
+}
+
+// Open opens or creates the database file at path.
+func Open(path string) (*sql.DB, error) {
+	// Foreign keys are off by default in SQLite and the cascades depend on them.  Immediate transactions
+	// take the write lock up front so concurrent writers wait on busy_timeout instead of failing.
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer anyway, a single connection keeps agg's workers from tripping over each other
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+type Store struct {
+	db database.DBTX // the *sql.DB, or the *sql.Tx in the Store InTx hands out
+}
+
+var _ storage.Store = (*Store)(nil)
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// withTx runs fn in a transaction, committing if it returns nil.  Inside InTx fn runs in InTx's transaction.
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if tx, ok := s.db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	tx, err := s.db.(*sql.DB).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InTx: the driver ignores opts, SQLite transactions are always serializable.
+func (s *Store) InTx(ctx context.Context, opts *sql.TxOptions, fn func(storage.Store) error) error {
+	if _, ok := s.db.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.(*sql.DB).BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&Store{db: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// translateError turns unique constraint failures into storage.ErrDuplicate.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("%w: %v", storage.ErrDuplicate, err)
+	}
+	return err
+}
+
+// timeLayout has a fixed width fraction so stored times compare correctly as text.
+const timeLayout = "2006-01-02 15:04:05.000000000"
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func nullTimestamp(t sql.NullTime) any {
+	if !t.Valid {
+		return nil
+	}
+	return timestamp(t.Time)
+}
+
+// scanTime reads a timestamp whether the driver already parsed it, as it does for TIMESTAMP columns,
+// or hands it back as text, as it does for expressions like COALESCE.
+type scanTime time.Time
+
+func (t *scanTime) Scan(v any) error {
+	var nt scanNullTime
+	if err := nt.Scan(v); err != nil {
+		return err
+	}
+	if !nt.Valid {
+		return errors.New("sqlite: unexpected NULL time")
+	}
+	*t = scanTime(nt.Time)
+	return nil
+}
+
+type scanNullTime sql.NullTime
+
+func (t *scanNullTime) Scan(v any) error {
+	switch x := v.(type) {
+	case nil:
+		*t = scanNullTime{}
+	case time.Time:
+		*t = scanNullTime{Time: x.UTC(), Valid: true}
+	case string:
+		return t.parse(x)
+	case []byte:
+		return t.parse(string(x))
+	default:
+		return fmt.Errorf("sqlite: can't scan %T into a time", v)
+	}
+	return nil
+}
+
+func (t *scanNullTime) parse(s string) error {
+	parsed, err := time.ParseInLocation(timeLayout, s, time.UTC)
+	if err != nil {
+		return fmt.Errorf("sqlite: bad time %q: %w", s, err)
+	}
+	*t = scanNullTime{Time: parsed, Valid: true}
+	return nil
+}
+
+// Arrays are stored as JSON.  A nil slice is stored as [] since the columns are NOT NULL.
+func jsonArray[T any](values []T) (string, error) {
+	if values == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
+
+type scanStrings []string
+
+func (a *scanStrings) Scan(v any) error {
+	return scanJSON(v, (*[]string)(a))
+}
+
+type scanInt32s []int32
+
+func (a *scanInt32s) Scan(v any) error {
+	return scanJSON(v, (*[]int32)(a))
+}
+
+func scanJSON[T any](v any, dest *[]T) error {
+	var data []byte
+	switch x := v.(type) {
+	case string:
+		data = []byte(x)
+	case []byte:
+		data = x
+	default:
+		return fmt.Errorf("sqlite: can't scan %T into an array", v)
+	}
+	values := []T{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("sqlite: bad array %q: %w", data, err)
+	}
+	*dest = values
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/striderjg/gator/internal/migrate"
+	"github.com/striderjg/gator/internal/storage"
+	"github.com/striderjg/gator/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		db, err := Open(filepath.Join(t.TempDir(), "gator.db"))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		m, err := migrate.New(db, Migrations(), ".")
+		if err != nil {
+			t.Fatalf("migrate.New: %v", err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+		return New(db)
+	})
+}
+
+func TestMatchExpression(t *testing.T) {
+	cases := []struct {
+		query, want string
+	}{
+		{"go", `"go"`},
+		{"go generics", `"go" AND "generics"`},
+		{`"error handling" go`, `"error handling" AND "go"`},
+		{"rust or go generics", `("rust" OR "go") AND "generics"`},
+		{"go -rust -\"c plus\"", `"go" NOT "rust" NOT "c plus"`},
+		{"or go", `"go"`},
+		{"-rust", ""},
+		{`c++ "`, `"c++"`},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := matchExpression(c.query); got != c.want {
+			t.Errorf("matchExpression(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+const createUser = `INSERT INTO users (id, created_at, updated_at, name)
+VALUES (?1, ?2, ?3, ?4)
+RETURNING id, created_at, updated_at, name`
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	row := s.db.QueryRowContext(ctx, createUser, arg.ID, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt), arg.Name)
+	i, err := scanUser(row)
+	return i, translateError(err)
+}
+
+const getUser = `SELECT id, created_at, updated_at, name FROM users WHERE name = ?1`
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, getUser, name))
+}
+
+const getUsers = `SELECT name FROM users`
+
+func (s *Store) GetUsers(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, getUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	return items, rows.Err()
+}
+
+const clearDB = `DELETE FROM users`
+
+func (s *Store) ClearDB(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, clearDB)
+	return err
+}
+
+func scanUser(row scanner) (database.User, error) {
+	var i database.User
+	err := row.Scan(&i.ID, (*scanTime)(&i.CreatedAt), (*scanTime)(&i.UpdatedAt), &i.Name)
+	return i, err
+}
+
+const listUsers = `SELECT id, created_at, updated_at, name FROM users ORDER BY created_at, id`
+
+func (s *Store) ListUsers(ctx context.Context) ([]database.User, error) {
+	rows, err := s.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.User
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const restoreUser = `INSERT INTO users (id, created_at, updated_at, name)
+VALUES (?1, ?2, ?3, ?4)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = excluded.created_at,
+    updated_at = excluded.updated_at,
+    name = excluded.name`
+
+func (s *Store) RestoreUser(ctx context.Context, arg database.RestoreUserParams) error {
+	_, err := s.db.ExecContext(ctx, restoreUser, arg.ID, timestamp(arg.CreatedAt), timestamp(arg.UpdatedAt), arg.Name)
+	return translateError(err)
+}
@@ -0,0 +1,138 @@
+// Package storage is what gator needs from a database.  Postgres wraps the sqlc generated *database.Queries,
+// other backends implement the same methods with the same params and rows.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// ErrDuplicate is returned, wrapped, by backends other than Postgres when a unique constraint is hit.
+var ErrDuplicate = errors.New("already exists")
+
+// Store is implemented by every backend.  Lookups that find nothing return sql.ErrNoRows like database/sql.
+type Store interface {
+	// Users
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUser(ctx context.Context, name string) (database.User, error)
+	GetUsers(ctx context.Context) ([]string, error)
+	ClearDB(ctx context.Context) error
+
+	// Feeds and fetch scheduling
+	CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error)
+	GetFeed(ctx context.Context, url string) (database.Feed, error)
+	GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error)
+	GetFeedErrors(ctx context.Context) ([]database.GetFeedErrorsRow, error)
+	ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error)
+	ReleaseFeedLease(ctx context.Context, arg database.ReleaseFeedLeaseParams) error
+	UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error
+	RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.Feed, error)
+	RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error
+	EnableFeed(ctx context.Context, url string) (int64, error)
+	UpdateFeedScheduleHints(ctx context.Context, arg database.UpdateFeedScheduleHintsParams) error
+	SetFeedIntervalOverride(ctx context.Context, arg database.SetFeedIntervalOverrideParams) (int64, error)
+
+	// Retention
+	SetFeedRetention(ctx context.Context, arg database.SetFeedRetentionParams) (int64, error)
+	GetFeedRetentions(ctx context.Context) ([]database.GetFeedRetentionsRow, error)
+	CountPrunablePosts(ctx context.Context, arg database.CountPrunablePostsParams) (int64, error)
+	PrunePosts(ctx context.Context, arg database.PrunePostsParams) (int64, error)
+
+	// Feed follows
+	CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error)
+	GetFeedFollowsForUser(ctx context.Context, id uuid.UUID) ([]database.GetFeedFollowsForUserRow, error)
+	DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) (database.FeedFollow, error)
+	SetFeedFollowCategory(ctx context.Context, arg database.SetFeedFollowCategoryParams) (int64, error)
+
+	// Posts
+	CreatePost(ctx context.Context, arg database.CreatePostParams) (database.CreatePostRow, error)
+	AdoptLegacyPost(ctx context.Context, arg database.AdoptLegacyPostParams) (int64, error)
+	GetFeedPostCadence(ctx context.Context, feedID uuid.UUID) (float64, error)
+	GetPostForUser(ctx context.Context, arg database.GetPostForUserParams) (database.GetPostForUserRow, error)
+	ListPostsForUser(ctx context.Context, arg database.ListPostsForUserParams) ([]database.ListPostsForUserRow, error)
+	BrowsePostsByPublished(ctx context.Context, arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error)
+	BrowsePostsByPublishedReverse(ctx context.Context, arg database.BrowsePostsByPublishedReverseParams) ([]database.BrowsePostsByPublishedReverseRow, error)
+	BrowsePostsByFetched(ctx context.Context, arg database.BrowsePostsByFetchedParams) ([]database.BrowsePostsByFetchedRow, error)
+	BrowsePostsByFetchedReverse(ctx context.Context, arg database.BrowsePostsByFetchedReverseParams) ([]database.BrowsePostsByFetchedReverseRow, error)
+	SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error)
+
+	// Read and starred posts
+	MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error
+	MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error
+	MarkPostsRead(ctx context.Context, arg database.MarkPostsReadParams) (int64, error)
+	StarPost(ctx context.Context, arg database.StarPostParams) (database.PostStar, error)
+	UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error)
+	GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetStarredPostsForUserRow, error)
+
+	// API tokens
+	CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error)
+	GetAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error)
+	GetUserByAPIToken(ctx context.Context, arg database.GetUserByAPITokenParams) (database.User, error)
+	DeleteAPIToken(ctx context.Context, arg database.DeleteAPITokenParams) (int64, error)
+
+	// Backup and restore.  The List queries return every row, the Restore queries insert a row with its
+	// own id and timestamps or replace the row with that primary key.
+	ListUsers(ctx context.Context) ([]database.User, error)
+	ListFeeds(ctx context.Context) ([]database.Feed, error)
+	ListFeedFollows(ctx context.Context) ([]database.FeedFollow, error)
+	ListPosts(ctx context.Context, arg database.ListPostsParams) ([]database.ListPostsRow, error)
+	ListPostReads(ctx context.Context) ([]database.PostRead, error)
+	ListPostStars(ctx context.Context) ([]database.PostStar, error)
+	ListPrunedPosts(ctx context.Context) ([]database.PrunedPost, error)
+	ListAPITokens(ctx context.Context) ([]database.ApiToken, error)
+	RestoreUser(ctx context.Context, arg database.RestoreUserParams) error
+	RestoreFeed(ctx context.Context, arg database.RestoreFeedParams) error
+	RestoreFeedFollow(ctx context.Context, arg database.RestoreFeedFollowParams) error
+	RestorePost(ctx context.Context, arg database.RestorePostParams) error
+	RestorePostRead(ctx context.Context, arg database.RestorePostReadParams) error
+	RestorePostStar(ctx context.Context, arg database.RestorePostStarParams) error
+	RestorePrunedPost(ctx context.Context, arg database.RestorePrunedPostParams) error
+	RestoreAPIToken(ctx context.Context, arg database.RestoreAPITokenParams) error
+
+	// InTx calls fn with a Store whose calls all run in one transaction, committed if fn returns nil and
+	// rolled back otherwise.  The Store is only good until fn returns.  InTx on that Store joins the
+	// transaction already open.
+	InTx(ctx context.Context, opts *sql.TxOptions, fn func(Store) error) error
+}
+
+// Postgres is the Postgres Store, the sqlc queries plus the connection InTx starts transactions on.
+type Postgres struct {
+	*database.Queries
+	db *sql.DB // nil in the Store InTx hands out
+}
+
+var _ Store = (*Postgres)(nil)
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{Queries: database.New(db), db: db}
+}
+
+func (p *Postgres) InTx(ctx context.Context, opts *sql.TxOptions, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&Postgres{Queries: p.Queries.WithTx(tx)}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsDuplicate reports whether err is a unique constraint violation from any backend.
+func IsDuplicate(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return errors.Is(err, ErrDuplicate)
+}
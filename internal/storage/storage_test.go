@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/migrate"
+	"github.com/striderjg/gator/internal/storage"
+	"github.com/striderjg/gator/internal/storage/storagetest"
+)
+
+// TestPostgres runs the conformance suite against the sqlc queries.  It needs a throwaway database, everything
+// in it is deleted: GATOR_TEST_DATABASE_URL=postgres://localhost/gator_test?sslmode=disable go test ./internal/storage
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("GATOR_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("GATOR_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer db.Close()
+	m, err := migrate.New(db, os.DirFS("../../sql/schema"), ".")
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		q := storage.NewPostgres(db)
+		// Every table hangs off users
+		if err := q.ClearDB(context.Background()); err != nil {
+			t.Fatalf("ClearDB: %v", err)
+		}
+		return q
+	})
+}
+
+// TestPostgresLegacyGuid checks a post stored before 007_posts.sql is updated, not duplicated, the next time
+// its item is scraped.  Same database requirements as TestPostgres.
+func TestPostgresLegacyGuid(t *testing.T) {
+	url := os.Getenv("GATOR_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("GATOR_TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer db.Close()
+	m, err := migrate.New(db, os.DirFS("../../sql/schema"), ".")
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	q := database.New(db)
+	if err := q.ClearDB(ctx); err != nil {
+		t.Fatalf("ClearDB: %v", err)
+	}
+	for {
+		version, err := m.Version(ctx)
+		if err != nil {
+			t.Fatalf("Version: %v", err)
+		}
+		if version <= 6 {
+			break
+		}
+		if _, err := m.Down(ctx); err != nil {
+			t.Fatalf("migrating down from %v: %v", version, err)
+		}
+	}
+
+	userID, feedID, postID := uuid.New(), uuid.New(), uuid.New()
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO users (id, created_at, updated_at, name) VALUES ($1, now(), now(), 'alice')", []any{userID}},
+		{"INSERT INTO feeds (id, created_at, updated_at, name, url, user_id) VALUES ($1, now(), now(), 'Blog', 'https://example.com/rss', $2)", []any{feedID, userID}},
+		{"INSERT INTO posts (id, created_at, updated_at, title, url, description, feed_id) VALUES ($1, now(), now(), 'Hello', 'https://example.com/hello', '', $2)", []any{postID, feedID}},
+	} {
+		if _, err := db.ExecContext(ctx, stmt.query, stmt.args...); err != nil {
+			t.Fatalf("%v: %v", stmt.query, err)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	// What scrapeFeed does with the item, which has a <guid> of its own
+	guid := "tag:example.com,2024:hello"
+	if n, err := q.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{Guid: guid, FeedID: feedID, Url: "https://example.com/hello"}); err != nil || n != 1 {
+		t.Fatalf("AdoptLegacyPost = %v, %v, want 1", n, err)
+	}
+	post, err := q.CreatePost(ctx, database.CreatePostParams{
+		ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Title: "Hello", Url: "https://example.com/hello",
+		Description: "now with a description", FeedID: feedID, Categories: []string{}, Guid: guid,
+	})
+	if err != nil || post.Inserted || post.ID != postID {
+		t.Errorf("CreatePost of the legacy item = %+v, %v, want an update of %v", post, err, postID)
+	}
+	if err := q.ClearDB(ctx); err != nil {
+		t.Fatalf("ClearDB: %v", err)
+	}
+}
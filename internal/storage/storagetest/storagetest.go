@@ -0,0 +1,1364 @@
+// Package storagetest is the conformance suite for storage.Store.  Every backend runs it from its own tests
+// so they all dedupe, order and page posts the same way.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/storage"
+)
+
+// Run runs the suite.  open must return an empty store with the schema applied, a new one for every call.
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f *fixture)
+	}{
+		{"Users", testUsers},
+		{"Feeds", testFeeds},
+		{"FeedRetention", testFeedRetention},
+		{"ClaimFeeds", testClaimFeeds},
+		{"FeedFailures", testFeedFailures},
+		{"FeedFollows", testFeedFollows},
+		{"CreatePost", testCreatePost},
+		{"AdoptLegacyPost", testAdoptLegacyPost},
+		{"ListPosts", testListPosts},
+		{"BrowsePosts", testBrowsePosts},
+		{"BrowseFilters", testBrowseFilters},
+		{"Reads", testReads},
+		{"Stars", testStars},
+		{"APITokens", testAPITokens},
+		{"Search", testSearch},
+		{"Prune", testPrune},
+		{"Restore", testRestore},
+		{"InTx", testInTx},
+		{"ClearDB", testClearDB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, &fixture{t: t, ctx: context.Background(), db: open(t)})
+		})
+	}
+}
+
+// base is a fixed time to build test data around, whole seconds so every backend stores it exactly.
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+type fixture struct {
+	t   *testing.T
+	ctx context.Context
+	db  storage.Store
+}
+
+func (f *fixture) user(name string) database.User {
+	f.t.Helper()
+	usr, err := f.db.CreateUser(f.ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, Name: name})
+	if err != nil {
+		f.t.Fatalf("CreateUser(%v): %v", name, err)
+	}
+	return usr
+}
+
+func (f *fixture) feed(usr database.User, name, url string) database.Feed {
+	f.t.Helper()
+	feed, err := f.db.CreateFeed(f.ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, Name: name, Url: url, UserID: usr.ID})
+	if err != nil {
+		f.t.Fatalf("CreateFeed(%v): %v", url, err)
+	}
+	return feed
+}
+
+func (f *fixture) follow(usr database.User, feed database.Feed) {
+	f.t.Helper()
+	_, err := f.db.CreateFeedFollow(f.ctx, database.CreateFeedFollowParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, UserID: usr.ID, FeedID: feed.ID})
+	if err != nil {
+		f.t.Fatalf("CreateFeedFollow: %v", err)
+	}
+}
+
+// post adds a post fetched at fetched minutes and published at published minutes, or with no date if published is nil.
+func (f *fixture) post(feed database.Feed, title, description string, fetched int, published *int) database.CreatePostRow {
+	f.t.Helper()
+	var publishedAt sql.NullTime
+	if published != nil {
+		publishedAt = nullTime(at(*published))
+	}
+	post, err := f.db.CreatePost(f.ctx, database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   at(fetched),
+		UpdatedAt:   at(fetched),
+		Title:       title,
+		Url:         "https://example.com/" + strings.ReplaceAll(strings.ToLower(title), " ", "-"),
+		Description: description,
+		PublishedAt: publishedAt,
+		FeedID:      feed.ID,
+		Categories:  []string{},
+		Guid:        uuid.NewString(),
+	})
+	if err != nil {
+		f.t.Fatalf("CreatePost(%v): %v", title, err)
+	}
+	return post
+}
+
+func minutes(m int) *int {
+	return &m
+}
+
+func testUsers(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	f.user("bob")
+
+	got, err := f.db.GetUser(f.ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.ID != alice.ID || got.Name != "alice" || !got.CreatedAt.Equal(base) {
+		t.Errorf("GetUser = %+v, want %+v", got, alice)
+	}
+	if _, err := f.db.GetUser(f.ctx, "carol"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUser of a missing user: got %v, want sql.ErrNoRows", err)
+	}
+
+	_, err = f.db.CreateUser(f.ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, Name: "alice"})
+	if !storage.IsDuplicate(err) {
+		t.Errorf("CreateUser with a taken name: got %v, want a duplicate error", err)
+	}
+
+	names, err := f.db.GetUsers(f.ctx)
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(names) != 2 || !contains(names, "alice") || !contains(names, "bob") {
+		t.Errorf("GetUsers = %v, want alice and bob", names)
+	}
+}
+
+func testFeeds(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	if feed.Disabled || feed.ConsecutiveFailures != 0 || feed.NextFetchAt.Valid || feed.IntervalOverrideSeconds.Valid || feed.PublicOnly {
+		t.Errorf("new feed has scheduling state: %+v", feed)
+	}
+	if len(feed.SkipHours) != 0 || len(feed.SkipDays) != 0 {
+		t.Errorf("new feed has skip hints: %v %v", feed.SkipHours, feed.SkipDays)
+	}
+
+	_, err := f.db.CreateFeed(f.ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, Name: "Again", Url: feed.Url, UserID: alice.ID})
+	if !storage.IsDuplicate(err) {
+		t.Errorf("CreateFeed with a taken url: got %v, want a duplicate error", err)
+	}
+	if _, err := f.db.GetFeed(f.ctx, "https://example.com/missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFeed of a missing feed: got %v, want sql.ErrNoRows", err)
+	}
+
+	feeds, err := f.db.GetFeeds(f.ctx)
+	if err != nil {
+		t.Fatalf("GetFeeds: %v", err)
+	}
+	if len(feeds) != 1 || feeds[0] != (database.GetFeedsRow{Name: "Blog", Url: feed.Url, Username: "alice"}) {
+		t.Errorf("GetFeeds = %+v", feeds)
+	}
+
+	if err := f.db.UpdateFeedCache(f.ctx, database.UpdateFeedCacheParams{ID: feed.ID, Etag: `"v1"`, LastModified: "Fri, 01 Mar 2024 12:00:00 GMT", LastBodySize: 1234}); err != nil {
+		t.Fatalf("UpdateFeedCache: %v", err)
+	}
+	err = f.db.UpdateFeedScheduleHints(f.ctx, database.UpdateFeedScheduleHintsParams{ID: feed.ID, HintedIntervalSeconds: 3600, SkipHours: []int32{0, 1, 23}, SkipDays: []string{"Saturday"}})
+	if err != nil {
+		t.Fatalf("UpdateFeedScheduleHints: %v", err)
+	}
+	n, err := f.db.SetFeedIntervalOverride(f.ctx, database.SetFeedIntervalOverrideParams{Url: feed.Url, IntervalOverrideSeconds: sql.NullInt32{Int32: 900, Valid: true}})
+	if err != nil || n != 1 {
+		t.Fatalf("SetFeedIntervalOverride = %v, %v, want 1 row", n, err)
+	}
+
+	got, err := f.db.GetFeed(f.ctx, feed.Url)
+	if err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	if got.ID != feed.ID || got.Etag != `"v1"` || got.LastModified != "Fri, 01 Mar 2024 12:00:00 GMT" || got.LastBodySize != 1234 {
+		t.Errorf("cache headers not stored: %+v", got)
+	}
+	if got.HintedIntervalSeconds != 3600 || fmt.Sprint(got.SkipHours) != "[0 1 23]" || fmt.Sprint(got.SkipDays) != "[Saturday]" {
+		t.Errorf("schedule hints not stored: %v %v %v", got.HintedIntervalSeconds, got.SkipHours, got.SkipDays)
+	}
+	if got.IntervalOverrideSeconds != (sql.NullInt32{Int32: 900, Valid: true}) {
+		t.Errorf("interval override = %v, want 900", got.IntervalOverrideSeconds)
+	}
+
+	public, err := f.db.CreateFeed(f.ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, Name: "Api", Url: "https://example.com/api", UserID: alice.ID, PublicOnly: true})
+	if err != nil || !public.PublicOnly {
+		t.Errorf("CreateFeed public only = %+v, %v", public, err)
+	}
+	if got, err := f.db.GetFeed(f.ctx, public.Url); err != nil || !got.PublicOnly {
+		t.Errorf("GetFeed of a public only feed = %+v, %v", got, err)
+	}
+}
+
+func testClaimFeeds(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	first := f.feed(alice, "First", "https://example.com/first")
+	second := f.feed(alice, "Second", "https://example.com/second")
+	later := f.feed(alice, "Later", "https://example.com/later")
+	if err := f.db.RecordFeedSuccess(f.ctx, database.RecordFeedSuccessParams{ID: later.ID, NextFetchAt: nullTime(at(60))}); err != nil {
+		t.Fatalf("RecordFeedSuccess: %v", err)
+	}
+
+	claim := func(owner string, now int, batch int32) []database.Feed {
+		t.Helper()
+		feeds, err := f.db.ClaimFeedsToFetch(f.ctx, database.ClaimFeedsToFetchParams{Now: at(now), Owner: owner, LeaseExpiresAt: nullTime(at(now + 5)), Batch: batch})
+		if err != nil {
+			t.Fatalf("ClaimFeedsToFetch: %v", err)
+		}
+		return feeds
+	}
+
+	claimed := claim("a", 0, 10)
+	if len(claimed) != 2 || !containsFeed(claimed, first.ID) || !containsFeed(claimed, second.ID) {
+		t.Fatalf("claimed %v, want the two due feeds", feedURLs(claimed))
+	}
+	for _, feed := range claimed {
+		if feed.LeaseOwner != "a" || !feed.LeaseExpiresAt.Time.Equal(at(5)) || !feed.LastFetchedAt.Time.Equal(at(0)) {
+			t.Errorf("claimed feed not leased: %+v", feed)
+		}
+	}
+	if again := claim("b", 1, 10); len(again) != 0 {
+		t.Errorf("leased feeds claimed again: %v", feedURLs(again))
+	}
+
+	if err := f.db.ReleaseFeedLease(f.ctx, database.ReleaseFeedLeaseParams{ID: first.ID, LeaseOwner: "someone else"}); err != nil {
+		t.Fatalf("ReleaseFeedLease: %v", err)
+	}
+	if again := claim("b", 1, 10); len(again) != 0 {
+		t.Errorf("lease released by the wrong owner: %v", feedURLs(again))
+	}
+	if err := f.db.ReleaseFeedLease(f.ctx, database.ReleaseFeedLeaseParams{ID: first.ID, LeaseOwner: "a"}); err != nil {
+		t.Fatalf("ReleaseFeedLease: %v", err)
+	}
+	if again := claim("b", 1, 10); len(again) != 1 || again[0].ID != first.ID {
+		t.Errorf("after release claimed %v, want first", feedURLs(again))
+	}
+
+	// second's lease has expired by 10, later is due at 60 but second was fetched longer ago
+	if expired := claim("c", 10, 1); len(expired) != 1 || expired[0].ID != second.ID {
+		t.Errorf("with an expired lease claimed %v, want second", feedURLs(expired))
+	}
+	if due := claim("c", 60, 10); !containsFeed(due, later.ID) {
+		t.Errorf("at its next fetch time claimed %v, want later", feedURLs(due))
+	}
+}
+
+func testFeedFailures(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Flaky", "https://example.com/flaky")
+	healthy := f.feed(alice, "Healthy", "https://example.com/healthy")
+
+	fail := func(n int) database.Feed {
+		t.Helper()
+		got, err := f.db.RecordFeedFailure(f.ctx, database.RecordFeedFailureParams{
+			Error: fmt.Sprintf("failure %v", n), At: nullTime(at(n)), NextFetchAt: nullTime(at(n + 10)), MaxFailures: 3, ID: feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("RecordFeedFailure: %v", err)
+		}
+		return got
+	}
+	if got := fail(1); got.ConsecutiveFailures != 1 || got.Disabled || got.LastError != "failure 1" || !got.NextFetchAt.Time.Equal(at(11)) {
+		t.Errorf("after one failure: %+v", got)
+	}
+	fail(2)
+	if got := fail(3); got.ConsecutiveFailures != 3 || !got.Disabled {
+		t.Errorf("after max failures the feed should be disabled: %+v", got)
+	}
+
+	errs, err := f.db.GetFeedErrors(f.ctx)
+	if err != nil {
+		t.Fatalf("GetFeedErrors: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Url != feed.Url || !errs[0].Disabled || errs[0].LastError != "failure 3" || !errs[0].LastErrorAt.Time.Equal(at(3)) {
+		t.Errorf("GetFeedErrors = %+v", errs)
+	}
+
+	feeds, err := f.db.ClaimFeedsToFetch(f.ctx, database.ClaimFeedsToFetchParams{Now: at(100), Owner: "a", Batch: 10})
+	if err != nil {
+		t.Fatalf("ClaimFeedsToFetch: %v", err)
+	}
+	if len(feeds) != 1 || feeds[0].ID != healthy.ID {
+		t.Errorf("claimed %v, disabled feeds should be skipped", feedURLs(feeds))
+	}
+
+	n, err := f.db.EnableFeed(f.ctx, feed.Url)
+	if err != nil || n != 1 {
+		t.Fatalf("EnableFeed = %v, %v, want 1 row", n, err)
+	}
+	if n, _ := f.db.EnableFeed(f.ctx, "https://example.com/missing"); n != 0 {
+		t.Errorf("EnableFeed of a missing feed changed %v rows", n)
+	}
+	got, err := f.db.GetFeed(f.ctx, feed.Url)
+	if err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	if got.Disabled || got.ConsecutiveFailures != 0 || got.NextFetchAt.Valid {
+		t.Errorf("enabled feed kept its failure state: %+v", got)
+	}
+
+	fail(4)
+	if err := f.db.RecordFeedSuccess(f.ctx, database.RecordFeedSuccessParams{ID: feed.ID, NextFetchAt: nullTime(at(200))}); err != nil {
+		t.Fatalf("RecordFeedSuccess: %v", err)
+	}
+	if errs, _ := f.db.GetFeedErrors(f.ctx); len(errs) != 0 {
+		t.Errorf("a success should clear the failure count: %+v", errs)
+	}
+}
+
+func testFeedFollows(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	bob := f.user("bob")
+	feed := f.feed(bob, "Blog", "https://example.com/feed")
+	other := f.feed(bob, "Other", "https://example.com/other")
+
+	follow, err := f.db.CreateFeedFollow(f.ctx, database.CreateFeedFollowParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, UserID: alice.ID, FeedID: feed.ID, Category: "tech"})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+	if follow.FeedName != "Blog" || follow.UserName != "alice" || follow.Category != "tech" {
+		t.Errorf("CreateFeedFollow = %+v", follow)
+	}
+	_, err = f.db.CreateFeedFollow(f.ctx, database.CreateFeedFollowParams{ID: uuid.New(), CreatedAt: base, UpdatedAt: base, UserID: alice.ID, FeedID: feed.ID})
+	if !storage.IsDuplicate(err) {
+		t.Errorf("following a feed twice: got %v, want a duplicate error", err)
+	}
+	f.follow(alice, other)
+	f.post(feed, "One", "", 0, nil)
+	read := f.post(feed, "Two", "", 1, nil)
+	if err := f.db.MarkPostRead(f.ctx, database.MarkPostReadParams{UserID: alice.ID, PostID: read.ID, ReadAt: at(2)}); err != nil {
+		t.Fatalf("MarkPostRead: %v", err)
+	}
+
+	follows, err := f.db.GetFeedFollowsForUser(f.ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetFeedFollowsForUser: %v", err)
+	}
+	if len(follows) != 2 {
+		t.Fatalf("GetFeedFollowsForUser returned %v follows, want 2", len(follows))
+	}
+	for _, follow := range follows {
+		want := int64(0)
+		if follow.FeedID == feed.ID {
+			want = 1
+		}
+		if follow.UserName != "alice" || follow.UnreadCount != want {
+			t.Errorf("follow of %v: %+v, want %v unread", follow.FeedUrl, follow, want)
+		}
+	}
+	if follows, _ := f.db.GetFeedFollowsForUser(f.ctx, bob.ID); len(follows) != 0 {
+		t.Errorf("bob follows %v feeds, want none", len(follows))
+	}
+
+	moved := database.SetFeedFollowCategoryParams{UserID: alice.ID, FeedID: feed.ID, Category: "tech/go", UpdatedAt: at(5)}
+	if n, err := f.db.SetFeedFollowCategory(f.ctx, moved); err != nil || n != 1 {
+		t.Errorf("SetFeedFollowCategory = %v, %v, want 1", n, err)
+	}
+	moved.UserID = bob.ID
+	if n, err := f.db.SetFeedFollowCategory(f.ctx, moved); err != nil || n != 0 {
+		t.Errorf("SetFeedFollowCategory of a feed bob doesn't follow = %v, %v, want 0", n, err)
+	}
+
+	deleted, err := f.db.DeleteFeedFollow(f.ctx, database.DeleteFeedFollowParams{UserID: alice.ID, FeedID: feed.ID})
+	if err != nil || deleted.FeedID != feed.ID || deleted.Category != "tech/go" || !deleted.UpdatedAt.Equal(at(5)) {
+		t.Errorf("DeleteFeedFollow = %+v, %v", deleted, err)
+	}
+	if _, err := f.db.DeleteFeedFollow(f.ctx, database.DeleteFeedFollowParams{UserID: alice.ID, FeedID: feed.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting a missing follow: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testCreatePost(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	arg := database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   at(0),
+		UpdatedAt:   at(0),
+		Title:       "Hello",
+		Url:         "https://example.com/hello",
+		Description: "first version",
+		PublishedAt: nullTime(at(-60)),
+		FeedID:      feed.ID,
+		Author:      "alice",
+		Categories:  []string{"go", "sql"},
+		Guid:        "hello",
+	}
+	post, err := f.db.CreatePost(f.ctx, arg)
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if !post.Inserted || post.ID != arg.ID || post.Author != "alice" || fmt.Sprint(post.Categories) != "[go sql]" || !post.PublishedAt.Time.Equal(at(-60)) {
+		t.Errorf("CreatePost = %+v", post)
+	}
+
+	// The same post fetched again is a no-op
+	again := arg
+	again.ID = uuid.New()
+	again.UpdatedAt = at(10)
+	if _, err := f.db.CreatePost(f.ctx, again); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CreatePost of an unchanged post: got %v, want sql.ErrNoRows", err)
+	}
+
+	// An edited post is updated in place
+	again.Description = "second version"
+	updated, err := f.db.CreatePost(f.ctx, again)
+	if err != nil {
+		t.Fatalf("CreatePost of an edited post: %v", err)
+	}
+	if updated.Inserted || updated.ID != arg.ID || updated.Description != "second version" || !updated.UpdatedAt.Equal(at(10)) || !updated.CreatedAt.Equal(at(0)) {
+		t.Errorf("CreatePost of an edited post = %+v", updated)
+	}
+
+	// The guid is only unique within a feed
+	other := f.feed(alice, "Other", "https://example.com/other")
+	again.ID = uuid.New()
+	again.FeedID = other.ID
+	if post, err := f.db.CreatePost(f.ctx, again); err != nil || !post.Inserted {
+		t.Errorf("CreatePost of the same guid in another feed = %+v, %v", post, err)
+	}
+
+	hourly := f.feed(alice, "Hourly", "https://example.com/hourly")
+	for i := 1; i <= 3; i++ {
+		f.post(hourly, fmt.Sprintf("Hourly %v", i), "", 0, minutes(i*60))
+	}
+	f.post(hourly, "Undated", "", 0, nil)
+	cadence, err := f.db.GetFeedPostCadence(f.ctx, hourly.ID)
+	if err != nil {
+		t.Fatalf("GetFeedPostCadence: %v", err)
+	}
+	if cadence < 3599 || cadence > 3601 {
+		t.Errorf("GetFeedPostCadence = %v, want 3600", cadence)
+	}
+	if cadence, err := f.db.GetFeedPostCadence(f.ctx, uuid.New()); err != nil || cadence != 0 {
+		t.Errorf("GetFeedPostCadence of a feed with no posts = %v, %v, want 0", cadence, err)
+	}
+}
+
+func testAdoptLegacyPost(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	// What 007_posts.sql leaves behind: the url stands in for the guid
+	legacy, err := f.db.CreatePost(f.ctx, database.CreatePostParams{
+		ID: uuid.New(), CreatedAt: at(0), UpdatedAt: at(0), Title: "Hello", Url: "https://example.com/hello",
+		FeedID: feed.ID, Categories: []string{}, Guid: "https://example.com/hello",
+	})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	arg := database.AdoptLegacyPostParams{Guid: "tag:example.com,2024:hello", FeedID: feed.ID, Url: "https://example.com/hello"}
+	other := arg
+	other.FeedID = f.feed(alice, "Other", "https://example.com/other").ID
+	if n, err := f.db.AdoptLegacyPost(f.ctx, other); err != nil || n != 0 {
+		t.Errorf("AdoptLegacyPost in another feed = %v, %v, want 0", n, err)
+	}
+	if n, err := f.db.AdoptLegacyPost(f.ctx, arg); err != nil || n != 1 {
+		t.Fatalf("AdoptLegacyPost = %v, %v, want 1", n, err)
+	}
+	// The item now upserts onto the legacy post
+	post, err := f.db.CreatePost(f.ctx, database.CreatePostParams{
+		ID: uuid.New(), CreatedAt: at(10), UpdatedAt: at(10), Title: "Hello again", Url: "https://example.com/hello",
+		FeedID: feed.ID, Categories: []string{}, Guid: arg.Guid,
+	})
+	if err != nil || post.Inserted || post.ID != legacy.ID || post.Guid != arg.Guid {
+		t.Errorf("CreatePost after AdoptLegacyPost = %+v, %v", post, err)
+	}
+	if n, err := f.db.AdoptLegacyPost(f.ctx, arg); err != nil || n != 0 {
+		t.Errorf("AdoptLegacyPost again = %v, %v, want 0", n, err)
+	}
+
+	// Nothing moves onto a guid that's already stored, or off a post whose url has changed since
+	taken := f.post(feed, "Taken", "", 0, nil)
+	for _, url := range []string{"https://example.com/twice", "https://example.com/moved"} {
+		stored := url
+		if url == "https://example.com/moved" {
+			stored = "https://example.com/elsewhere"
+		}
+		if _, err := f.db.CreatePost(f.ctx, database.CreatePostParams{
+			ID: uuid.New(), CreatedAt: at(0), UpdatedAt: at(0), Title: url, Url: stored,
+			FeedID: feed.ID, Categories: []string{}, Guid: url,
+		}); err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+	}
+	if n, err := f.db.AdoptLegacyPost(f.ctx, database.AdoptLegacyPostParams{Guid: taken.Guid, FeedID: feed.ID, Url: "https://example.com/twice"}); err != nil || n != 0 {
+		t.Errorf("AdoptLegacyPost onto a stored guid = %v, %v, want 0", n, err)
+	}
+	if n, err := f.db.AdoptLegacyPost(f.ctx, database.AdoptLegacyPostParams{Guid: "moved", FeedID: feed.ID, Url: "https://example.com/moved"}); err != nil || n != 0 {
+		t.Errorf("AdoptLegacyPost of a post with another url = %v, %v, want 0", n, err)
+	}
+}
+
+func testListPosts(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	other := f.feed(alice, "Other", "https://example.com/other")
+	unfollowed := f.feed(alice, "Unfollowed", "https://example.com/unfollowed")
+	f.follow(alice, feed)
+	f.follow(alice, other)
+	undated := f.post(feed, "Undated", "", 0, nil)
+	oldest := f.post(feed, "Oldest", "", 0, minutes(-30))
+	newest := f.post(other, "Newest", "", 0, minutes(-10))
+	middle := f.post(feed, "Middle", "", 0, minutes(-20))
+	f.post(unfollowed, "Hidden", "", 0, minutes(-5))
+
+	list := func(arg database.ListPostsForUserParams) []uuid.UUID {
+		t.Helper()
+		arg.UserID = alice.ID
+		if arg.Limit == 0 {
+			arg.Limit = 10
+		}
+		posts, err := f.db.ListPostsForUser(f.ctx, arg)
+		if err != nil {
+			t.Fatalf("ListPostsForUser: %v", err)
+		}
+		ids := make([]uuid.UUID, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+		return ids
+	}
+	checkIDs(t, "newest first", list(database.ListPostsForUserParams{}), newest.ID, middle.ID, oldest.ID, undated.ID)
+	checkIDs(t, "second page", list(database.ListPostsForUserParams{Limit: 2, Offset: 2}), oldest.ID, undated.ID)
+	checkIDs(t, "one feed", list(database.ListPostsForUserParams{FeedID: uuid.NullUUID{UUID: other.ID, Valid: true}}), newest.ID)
+	checkIDs(t, "between", list(database.ListPostsForUserParams{Since: nullTime(at(-20)), Until: nullTime(at(-10))}), middle.ID)
+
+	if err := f.db.MarkPostRead(f.ctx, database.MarkPostReadParams{UserID: alice.ID, PostID: middle.ID, ReadAt: at(0)}); err != nil {
+		t.Fatalf("MarkPostRead: %v", err)
+	}
+	checkIDs(t, "unread", list(database.ListPostsForUserParams{UnreadOnly: true}), newest.ID, oldest.ID, undated.ID)
+
+	post, err := f.db.GetPostForUser(f.ctx, database.GetPostForUserParams{ID: middle.ID, UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("GetPostForUser: %v", err)
+	}
+	if post.Title != "Middle" || post.FeedName != "Blog" || post.FeedUrl != feed.Url || !post.Read {
+		t.Errorf("GetPostForUser = %+v", post)
+	}
+	bob := f.user("bob")
+	if _, err := f.db.GetPostForUser(f.ctx, database.GetPostForUserParams{ID: middle.ID, UserID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPostForUser of a feed the user doesn't follow: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+type browseFunc func(arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error)
+
+// browseFuncs adapts the four browse queries, which share their params and rows, to one signature.
+func browseFuncs(db storage.Store, ctx context.Context) map[string]browseFunc {
+	return map[string]browseFunc{
+		"published": func(arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+			return db.BrowsePostsByPublished(ctx, arg)
+		},
+		"published reverse": func(arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+			rows, err := db.BrowsePostsByPublishedReverse(ctx, database.BrowsePostsByPublishedReverseParams(arg))
+			return convertRows(rows), err
+		},
+		"fetched": func(arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+			rows, err := db.BrowsePostsByFetched(ctx, database.BrowsePostsByFetchedParams(arg))
+			return convertRows(rows), err
+		},
+		"fetched reverse": func(arg database.BrowsePostsByPublishedParams) ([]database.BrowsePostsByPublishedRow, error) {
+			rows, err := db.BrowsePostsByFetchedReverse(ctx, database.BrowsePostsByFetchedReverseParams(arg))
+			return convertRows(rows), err
+		},
+	}
+}
+
+func convertRows[T ~struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Read        bool
+	SortAt      time.Time
+}](rows []T) []database.BrowsePostsByPublishedRow {
+	var items []database.BrowsePostsByPublishedRow
+	for _, row := range rows {
+		items = append(items, database.BrowsePostsByPublishedRow(row))
+	}
+	return items
+}
+
+func testBrowsePosts(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	f.follow(alice, feed)
+
+	// Posts sort on published time, or the fetch time when there is none.  a and b tie on both.
+	a := f.post(feed, "A", "", 5, minutes(-10))
+	b := f.post(feed, "B", "", 5, minutes(-10))
+	c := f.post(feed, "C", "", 3, nil)
+	d := f.post(feed, "D", "", 1, minutes(-20))
+	e := f.post(feed, "E", "", 4, minutes(-30))
+	first, second := a.ID, b.ID
+	if strings.Compare(first.String(), second.String()) > 0 {
+		first, second = second, first
+	}
+
+	want := map[string][]uuid.UUID{
+		"published":         {c.ID, second, first, d.ID, e.ID},
+		"published reverse": {e.ID, d.ID, first, second, c.ID},
+		"fetched":           {second, first, e.ID, c.ID, d.ID},
+		"fetched reverse":   {d.ID, c.ID, e.ID, first, second},
+	}
+	for name, browse := range browseFuncs(f.db, f.ctx) {
+		t.Run(name, func(t *testing.T) {
+			arg := database.BrowsePostsByPublishedParams{UserID: alice.ID, Include: []string{}, Exclude: []string{}, Limit: 10}
+			all, err := browse(arg)
+			if err != nil {
+				t.Fatalf("browse: %v", err)
+			}
+			checkIDs(t, "all", rowIDs(all), want[name]...)
+
+			// Page through two at a time with the last row as the cursor
+			var paged []uuid.UUID
+			arg.Limit = 2
+			for page := 0; page < 5; page++ {
+				rows, err := browse(arg)
+				if err != nil {
+					t.Fatalf("browse page %v: %v", page, err)
+				}
+				if len(rows) == 0 {
+					break
+				}
+				paged = append(paged, rowIDs(rows)...)
+				last := rows[len(rows)-1]
+				arg.CursorAt = nullTime(last.SortAt)
+				arg.CursorID = uuid.NullUUID{UUID: last.ID, Valid: true}
+			}
+			checkIDs(t, "paged", paged, want[name]...)
+		})
+	}
+
+	rows, err := f.db.BrowsePostsByPublished(f.ctx, database.BrowsePostsByPublishedParams{UserID: alice.ID, Include: []string{}, Exclude: []string{}, Limit: 1})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("BrowsePostsByPublished = %v, %v", rows, err)
+	}
+	if row := rows[0]; row.FeedName != "Blog" || row.PublishedAt.Valid || !row.CreatedAt.Equal(at(3)) || !row.SortAt.Equal(at(3)) {
+		t.Errorf("undated post row = %+v", row)
+	}
+}
+
+func testBrowseFilters(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Go Blog", "https://example.com/go")
+	other := f.feed(alice, "Rust Blog", "https://example.com/rust")
+	f.follow(alice, feed)
+	f.follow(alice, other)
+	generics := f.post(feed, "Generics in Go", "Type parameters explained", 0, minutes(-30))
+	errs := f.post(feed, "Error handling", "Wrapping errors with %w", 0, minutes(-20))
+	traits := f.post(other, "Traits", "Like Go interfaces, but generic", 0, minutes(-10))
+	if err := f.db.MarkPostRead(f.ctx, database.MarkPostReadParams{UserID: alice.ID, PostID: traits.ID, ReadAt: at(0)}); err != nil {
+		t.Fatalf("MarkPostRead: %v", err)
+	}
+
+	browse := func(arg database.BrowsePostsByPublishedParams) []uuid.UUID {
+		t.Helper()
+		arg.UserID = alice.ID
+		arg.Limit = 10
+		if arg.Include == nil {
+			arg.Include = []string{}
+		}
+		if arg.Exclude == nil {
+			arg.Exclude = []string{}
+		}
+		rows, err := f.db.BrowsePostsByPublished(f.ctx, arg)
+		if err != nil {
+			t.Fatalf("BrowsePostsByPublished: %v", err)
+		}
+		return rowIDs(rows)
+	}
+	checkIDs(t, "feed by name", browse(database.BrowsePostsByPublishedParams{Feed: sql.NullString{String: "go blog", Valid: true}}), errs.ID, generics.ID)
+	checkIDs(t, "feed by url", browse(database.BrowsePostsByPublishedParams{Feed: sql.NullString{String: other.Url, Valid: true}}), traits.ID)
+	checkIDs(t, "since and until", browse(database.BrowsePostsByPublishedParams{Since: nullTime(at(-20)), Until: nullTime(at(-10))}), errs.ID)
+	checkIDs(t, "unread", browse(database.BrowsePostsByPublishedParams{UnreadOnly: true}), errs.ID, generics.ID)
+	checkIDs(t, "include", browse(database.BrowsePostsByPublishedParams{Include: []string{"GENERIC"}}), traits.ID, generics.ID)
+	checkIDs(t, "include all", browse(database.BrowsePostsByPublishedParams{Include: []string{"generic", "go"}}), traits.ID, generics.ID)
+	checkIDs(t, "exclude", browse(database.BrowsePostsByPublishedParams{Exclude: []string{"generic"}}), errs.ID)
+	checkIDs(t, "escaped wildcard", browse(database.BrowsePostsByPublishedParams{Include: []string{`\%w`}}), errs.ID)
+}
+
+func testReads(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	other := f.feed(alice, "Other", "https://example.com/other")
+	f.follow(alice, feed)
+	f.follow(alice, other)
+	old := f.post(feed, "Old", "", 0, minutes(-30))
+	undated := f.post(feed, "Undated", "", -20, nil)
+	recent := f.post(feed, "Recent", "", 0, minutes(-10))
+	f.post(other, "Elsewhere", "", 0, minutes(-30))
+
+	read := func(id uuid.UUID) bool {
+		t.Helper()
+		post, err := f.db.GetPostForUser(f.ctx, database.GetPostForUserParams{ID: id, UserID: alice.ID})
+		if err != nil {
+			t.Fatalf("GetPostForUser: %v", err)
+		}
+		return post.Read
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := f.db.MarkPostRead(f.ctx, database.MarkPostReadParams{UserID: alice.ID, PostID: recent.ID, ReadAt: at(i)}); err != nil {
+			t.Fatalf("MarkPostRead: %v", err)
+		}
+	}
+	if !read(recent.ID) {
+		t.Errorf("post not read after MarkPostRead")
+	}
+	if err := f.db.MarkPostUnread(f.ctx, database.MarkPostUnreadParams{UserID: alice.ID, PostID: recent.ID}); err != nil {
+		t.Fatalf("MarkPostUnread: %v", err)
+	}
+	if read(recent.ID) {
+		t.Errorf("post still read after MarkPostUnread")
+	}
+
+	n, err := f.db.MarkPostsRead(f.ctx, database.MarkPostsReadParams{ReadAt: at(0), UserID: alice.ID, FeedID: uuid.NullUUID{UUID: feed.ID, Valid: true}, Before: nullTime(at(-15))})
+	if err != nil {
+		t.Fatalf("MarkPostsRead: %v", err)
+	}
+	// undated counts by its fetch time
+	if n != 2 || !read(old.ID) || !read(undated.ID) || read(recent.ID) {
+		t.Errorf("MarkPostsRead before -15 marked %v posts", n)
+	}
+	n, err = f.db.MarkPostsRead(f.ctx, database.MarkPostsReadParams{ReadAt: at(0), UserID: alice.ID})
+	if err != nil || n != 2 {
+		t.Errorf("MarkPostsRead of everything = %v, %v, want the 2 still unread", n, err)
+	}
+}
+
+func testStars(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	first := f.post(feed, "First", "", 0, nil)
+	second := f.post(feed, "Second", "", 0, nil)
+
+	star := func(id uuid.UUID, note *string, when int) database.PostStar {
+		t.Helper()
+		arg := database.StarPostParams{UserID: alice.ID, PostID: id, StarredAt: at(when)}
+		if note != nil {
+			arg.Note = sql.NullString{String: *note, Valid: true}
+		}
+		s, err := f.db.StarPost(f.ctx, arg)
+		if err != nil {
+			t.Fatalf("StarPost: %v", err)
+		}
+		return s
+	}
+	note := "read later"
+	if s := star(first.ID, &note, 1); s.Note != note || !s.CreatedAt.Equal(at(1)) {
+		t.Errorf("StarPost = %+v", s)
+	}
+	if s := star(first.ID, nil, 2); s.Note != note || !s.CreatedAt.Equal(at(1)) || !s.UpdatedAt.Equal(at(2)) {
+		t.Errorf("starring again without a note = %+v, want the note kept", s)
+	}
+	changed := "done"
+	if s := star(first.ID, &changed, 3); s.Note != changed {
+		t.Errorf("starring again with a note = %+v, want it replaced", s)
+	}
+	if s := star(second.ID, nil, 4); s.Note != "" {
+		t.Errorf("StarPost without a note = %+v", s)
+	}
+
+	starred, err := f.db.GetStarredPostsForUser(f.ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetStarredPostsForUser: %v", err)
+	}
+	if len(starred) != 2 || starred[0].ID != second.ID || starred[1].ID != first.ID || starred[1].Note != changed || starred[1].FeedName != "Blog" {
+		t.Errorf("GetStarredPostsForUser = %+v, want newest star first", starred)
+	}
+
+	if n, err := f.db.UnstarPost(f.ctx, database.UnstarPostParams{UserID: alice.ID, PostID: first.ID}); err != nil || n != 1 {
+		t.Errorf("UnstarPost = %v, %v, want 1 row", n, err)
+	}
+	if n, _ := f.db.UnstarPost(f.ctx, database.UnstarPostParams{UserID: alice.ID, PostID: first.ID}); n != 0 {
+		t.Errorf("UnstarPost of an unstarred post changed %v rows", n)
+	}
+}
+
+func testAPITokens(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	bob := f.user("bob")
+	token, err := f.db.CreateAPIToken(f.ctx, database.CreateAPITokenParams{ID: uuid.New(), CreatedAt: at(0), Name: "laptop", TokenHash: "hash", UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	if token.LastUsedAt.Valid || token.Name != "laptop" {
+		t.Errorf("CreateAPIToken = %+v", token)
+	}
+	_, err = f.db.CreateAPIToken(f.ctx, database.CreateAPITokenParams{ID: uuid.New(), CreatedAt: at(0), Name: "again", TokenHash: "hash", UserID: bob.ID})
+	if !storage.IsDuplicate(err) {
+		t.Errorf("CreateAPIToken with a taken hash: got %v, want a duplicate error", err)
+	}
+
+	usr, err := f.db.GetUserByAPIToken(f.ctx, database.GetUserByAPITokenParams{TokenHash: "hash", LastUsedAt: nullTime(at(5))})
+	if err != nil || usr.ID != alice.ID {
+		t.Fatalf("GetUserByAPIToken = %+v, %v, want alice", usr, err)
+	}
+	if _, err := f.db.GetUserByAPIToken(f.ctx, database.GetUserByAPITokenParams{TokenHash: "nope", LastUsedAt: nullTime(at(5))}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByAPIToken of an unknown token: got %v, want sql.ErrNoRows", err)
+	}
+
+	tokens, err := f.db.GetAPITokensForUser(f.ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetAPITokensForUser: %v", err)
+	}
+	if len(tokens) != 1 || !tokens[0].LastUsedAt.Time.Equal(at(5)) {
+		t.Errorf("GetAPITokensForUser = %+v, want the use recorded", tokens)
+	}
+
+	if n, _ := f.db.DeleteAPIToken(f.ctx, database.DeleteAPITokenParams{ID: token.ID, UserID: bob.ID}); n != 0 {
+		t.Errorf("another user deleted the token")
+	}
+	if n, err := f.db.DeleteAPIToken(f.ctx, database.DeleteAPITokenParams{ID: token.ID, UserID: alice.ID}); err != nil || n != 1 {
+		t.Errorf("DeleteAPIToken = %v, %v, want 1 row", n, err)
+	}
+}
+
+func testSearch(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Go Blog", "https://example.com/go")
+	other := f.feed(alice, "Rust Blog", "https://example.com/rust")
+	unfollowed := f.feed(alice, "Unfollowed", "https://example.com/unfollowed")
+	f.follow(alice, feed)
+	f.follow(alice, other)
+	inTitle := f.post(feed, "Goroutines and channels", "A tour of concurrency primitives", 0, minutes(-30))
+	inDescription := f.post(feed, "Concurrency patterns", "Fan out with goroutines and channels", 0, minutes(-20))
+	phrase := f.post(other, "Async Rust", "Tasks are not goroutines, error handling differs", 0, minutes(-10))
+	f.post(unfollowed, "Goroutines elsewhere", "", 0, minutes(-5))
+
+	search := func(query string, arg database.SearchPostsForUserParams) []database.SearchPostsForUserRow {
+		t.Helper()
+		arg.Query = query
+		arg.UserID = alice.ID
+		if arg.Limit == 0 {
+			arg.Limit = 10
+		}
+		rows, err := f.db.SearchPostsForUser(f.ctx, arg)
+		if err != nil {
+			t.Fatalf("SearchPostsForUser(%q): %v", query, err)
+		}
+		return rows
+	}
+	ids := func(rows []database.SearchPostsForUserRow) []uuid.UUID {
+		ids := make([]uuid.UUID, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		return ids
+	}
+
+	rows := search("goroutines", database.SearchPostsForUserParams{})
+	checkIDs(t, "title match first", ids(rows)[:1], inTitle.ID)
+	if len(rows) != 3 {
+		t.Fatalf("goroutines matched %v posts, want 3", len(rows))
+	}
+	if rows[0].Rank <= rows[1].Rank || rows[0].FeedName != "Go Blog" {
+		t.Errorf("title match = %+v, want it ranked above %+v", rows[0], rows[1])
+	}
+	if !strings.Contains(rows[0].TitleHighlight, "\x01Goroutines\x02") {
+		t.Errorf("title highlight = %q", rows[0].TitleHighlight)
+	}
+	for _, row := range rows[1:] {
+		if !strings.Contains(row.Snippet, "\x01goroutines\x02") {
+			t.Errorf("snippet = %q, want the match marked", row.Snippet)
+		}
+	}
+
+	checkIDs(t, "stemmed", ids(search("channel", database.SearchPostsForUserParams{})), inTitle.ID, inDescription.ID)
+	checkIDs(t, "phrase", ids(search(`"error handling"`, database.SearchPostsForUserParams{})), phrase.ID)
+	checkIDs(t, "or", ids(search("tour or async", database.SearchPostsForUserParams{Feed: sql.NullString{String: "rust blog", Valid: true}})), phrase.ID)
+	checkIDs(t, "exclude", ids(search("goroutines -rust", database.SearchPostsForUserParams{})), inTitle.ID, inDescription.ID)
+	checkIDs(t, "before", ids(search("goroutines", database.SearchPostsForUserParams{Before: nullTime(at(-25))})), inTitle.ID)
+	checkIDs(t, "after", ids(search("concurrency", database.SearchPostsForUserParams{After: nullTime(at(-25))})), inDescription.ID)
+	if rows := search("goroutines", database.SearchPostsForUserParams{Limit: 1}); len(rows) != 1 {
+		t.Errorf("limit 1 returned %v rows", len(rows))
+	}
+	if rows := search("kubernetes", database.SearchPostsForUserParams{}); len(rows) != 0 {
+		t.Errorf("kubernetes matched %v posts", len(rows))
+	}
+}
+
+func testFeedRetention(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	news := f.feed(alice, "News", "https://example.com/news")
+	blog := f.feed(alice, "Blog", "https://example.com/feed")
+	if blog.RetentionMaxAgeSeconds.Valid || blog.RetentionMaxPosts.Valid || blog.RetentionKeepUnread.Valid {
+		t.Errorf("new feed has retention overrides: %+v", blog)
+	}
+
+	arg := database.SetFeedRetentionParams{
+		Url:                    blog.Url,
+		RetentionMaxAgeSeconds: sql.NullInt32{Int32: 86400, Valid: true},
+		RetentionMaxPosts:      sql.NullInt32{Int32: 0, Valid: true},
+		RetentionKeepUnread:    sql.NullBool{Bool: false, Valid: true},
+	}
+	if n, err := f.db.SetFeedRetention(f.ctx, arg); err != nil || n != 1 {
+		t.Fatalf("SetFeedRetention = %v, %v, want 1 row", n, err)
+	}
+	got, err := f.db.GetFeed(f.ctx, blog.Url)
+	if err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	if got.RetentionMaxAgeSeconds != arg.RetentionMaxAgeSeconds || got.RetentionMaxPosts != arg.RetentionMaxPosts ||
+		got.RetentionKeepUnread != arg.RetentionKeepUnread {
+		t.Errorf("GetFeed after SetFeedRetention = %+v", got)
+	}
+
+	retentions, err := f.db.GetFeedRetentions(f.ctx)
+	if err != nil {
+		t.Fatalf("GetFeedRetentions: %v", err)
+	}
+	if len(retentions) != 2 || retentions[0].ID != blog.ID || retentions[1].ID != news.ID {
+		t.Fatalf("GetFeedRetentions = %+v, want Blog then News", retentions)
+	}
+	if r := retentions[0]; r.Url != blog.Url || r.RetentionMaxAgeSeconds != arg.RetentionMaxAgeSeconds || r.RetentionKeepUnread != arg.RetentionKeepUnread {
+		t.Errorf("GetFeedRetentions Blog = %+v", r)
+	}
+	if r := retentions[1]; r.RetentionMaxAgeSeconds.Valid || r.RetentionMaxPosts.Valid || r.RetentionKeepUnread.Valid {
+		t.Errorf("GetFeedRetentions News = %+v, want no overrides", r)
+	}
+
+	if n, err := f.db.SetFeedRetention(f.ctx, database.SetFeedRetentionParams{Url: blog.Url}); err != nil || n != 1 {
+		t.Errorf("clearing retention = %v, %v", n, err)
+	}
+	if got, _ := f.db.GetFeed(f.ctx, blog.Url); got.RetentionMaxAgeSeconds.Valid || got.RetentionKeepUnread.Valid {
+		t.Errorf("retention after clearing = %+v", got)
+	}
+	if n, err := f.db.SetFeedRetention(f.ctx, database.SetFeedRetentionParams{Url: "https://example.com/missing"}); err != nil || n != 0 {
+		t.Errorf("SetFeedRetention of a missing feed = %v, %v, want 0 rows", n, err)
+	}
+}
+
+func testPrune(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	bob := f.user("bob")
+	blog := f.feed(alice, "Blog", "https://example.com/feed")
+	news := f.feed(alice, "News", "https://example.com/news")
+	f.follow(alice, blog)
+	f.follow(bob, blog)
+	oldest := f.post(blog, "Oldest", "", 0, minutes(0))
+	old := f.post(blog, "Old unique", "", 10, minutes(10))
+	middle := f.post(blog, "Middle", "", 20, minutes(20))
+	f.post(blog, "Undated", "", 30, nil)
+	f.post(blog, "Newest", "", 40, minutes(40))
+	f.post(news, "Other feed", "", 0, minutes(0))
+
+	if _, err := f.db.StarPost(f.ctx, database.StarPostParams{UserID: bob.ID, PostID: oldest.ID, StarredAt: at(50)}); err != nil {
+		t.Fatalf("StarPost: %v", err)
+	}
+	read := func(usr database.User, post database.CreatePostRow) {
+		t.Helper()
+		if err := f.db.MarkPostRead(f.ctx, database.MarkPostReadParams{UserID: usr.ID, PostID: post.ID, ReadAt: at(50)}); err != nil {
+			t.Fatalf("MarkPostRead: %v", err)
+		}
+	}
+	read(alice, old)
+	read(alice, middle)
+	read(bob, middle)
+
+	before := nullTime(at(15))
+	maxPosts := sql.NullInt32{Int32: 2, Valid: true}
+	cases := []struct {
+		name string
+		arg  database.CountPrunablePostsParams
+		want int64
+	}{
+		// oldest is starred, so never counted
+		{"max age", database.CountPrunablePostsParams{FeedID: blog.ID, Before: before}, 1},
+		// bob hasn't read old
+		{"max age keeping unread", database.CountPrunablePostsParams{FeedID: blog.ID, Before: before, KeepUnread: true}, 0},
+		{"max posts", database.CountPrunablePostsParams{FeedID: blog.ID, MaxPosts: maxPosts}, 2},
+		{"max posts keeping unread", database.CountPrunablePostsParams{FeedID: blog.ID, MaxPosts: maxPosts, KeepUnread: true}, 1},
+		{"both", database.CountPrunablePostsParams{FeedID: blog.ID, Before: before, MaxPosts: sql.NullInt32{Int32: 4, Valid: true}}, 1},
+		{"no limits", database.CountPrunablePostsParams{FeedID: blog.ID}, 0},
+		{"other feed", database.CountPrunablePostsParams{FeedID: news.ID, Before: before}, 1},
+	}
+	for _, c := range cases {
+		if n, err := f.db.CountPrunablePosts(f.ctx, c.arg); err != nil || n != c.want {
+			t.Errorf("CountPrunablePosts %v = %v, %v, want %v", c.name, n, err, c.want)
+		}
+	}
+
+	read(bob, old)
+	arg := database.PrunePostsParams{FeedID: blog.ID, Before: before, KeepUnread: true}
+	if n, err := f.db.CountPrunablePosts(f.ctx, database.CountPrunablePostsParams(arg)); err != nil || n != 1 {
+		t.Errorf("CountPrunablePosts once everyone read old = %v, %v, want 1", n, err)
+	}
+	if n, err := f.db.PrunePosts(f.ctx, arg); err != nil || n != 1 {
+		t.Fatalf("PrunePosts = %v, %v, want 1 row", n, err)
+	}
+	if _, err := f.db.GetPostForUser(f.ctx, database.GetPostForUserParams{ID: old.ID, UserID: alice.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPostForUser of a pruned post: got %v, want sql.ErrNoRows", err)
+	}
+	if n, _ := f.db.PrunePosts(f.ctx, arg); n != 0 {
+		t.Errorf("pruning again deleted %v posts", n)
+	}
+	again := database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   at(60),
+		UpdatedAt:   at(60),
+		Title:       old.Title,
+		Url:         old.Url,
+		PublishedAt: old.PublishedAt,
+		FeedID:      blog.ID,
+		Categories:  []string{},
+		Guid:        old.Guid,
+	}
+	if _, err := f.db.CreatePost(f.ctx, again); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CreatePost of a pruned item: got %v, want sql.ErrNoRows", err)
+	}
+	again.ID, again.FeedID, again.PublishedAt = uuid.New(), news.ID, nullTime(at(60))
+	if post, err := f.db.CreatePost(f.ctx, again); err != nil || !post.Inserted {
+		t.Errorf("CreatePost of the pruned guid in another feed = %+v, %v, want inserted", post, err)
+	}
+	posts, err := f.db.ListPostsForUser(f.ctx, database.ListPostsForUserParams{UserID: alice.ID, Limit: 10})
+	if err != nil || len(posts) != 4 {
+		t.Errorf("ListPostsForUser after prune = %v posts, %v, want 4", len(posts), err)
+	}
+	if results, err := f.db.SearchPostsForUser(f.ctx, database.SearchPostsForUserParams{Query: "unique", UserID: alice.ID, Limit: 10}); err != nil || len(results) != 0 {
+		t.Errorf("search finds a pruned post: %+v, %v", results, err)
+	}
+	if starred, _ := f.db.GetStarredPostsForUser(f.ctx, bob.ID); len(starred) != 1 || starred[0].ID != oldest.ID {
+		t.Errorf("starred post was pruned: %+v", starred)
+	}
+	if n, _ := f.db.CountPrunablePosts(f.ctx, database.CountPrunablePostsParams{FeedID: news.ID, Before: before}); n != 1 {
+		t.Errorf("pruning Blog touched News, %v prunable", n)
+	}
+}
+
+func testRestore(t *testing.T, f *fixture) {
+	usr := database.RestoreUserParams{ID: uuid.New(), CreatedAt: at(0), UpdatedAt: at(1), Name: "alice"}
+	if err := f.db.RestoreUser(f.ctx, usr); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	feed := database.RestoreFeedParams{
+		ID:                    uuid.New(),
+		CreatedAt:             at(0),
+		UpdatedAt:             at(2),
+		Name:                  "Blog",
+		Url:                   "https://example.com/feed",
+		UserID:                usr.ID,
+		LastFetchedAt:         nullTime(at(2)),
+		Etag:                  `"v1"`,
+		LastBodySize:          1024,
+		LastError:             "timeout",
+		LastErrorAt:           nullTime(at(1)),
+		ConsecutiveFailures:   1,
+		NextFetchAt:           nullTime(at(60)),
+		HintedIntervalSeconds: 3600,
+		SkipHours:             []int32{1, 2},
+		SkipDays:              []string{"Sunday"},
+		RetentionMaxPosts:     sql.NullInt32{Int32: 100, Valid: true},
+		RetentionKeepUnread:   sql.NullBool{Bool: true, Valid: true},
+		PublicOnly:            true,
+	}
+	if err := f.db.RestoreFeed(f.ctx, feed); err != nil {
+		t.Fatalf("RestoreFeed: %v", err)
+	}
+	follow := database.RestoreFeedFollowParams{ID: uuid.New(), CreatedAt: at(0), UpdatedAt: at(0), UserID: usr.ID, FeedID: feed.ID, Category: "tech"}
+	if err := f.db.RestoreFeedFollow(f.ctx, follow); err != nil {
+		t.Fatalf("RestoreFeedFollow: %v", err)
+	}
+	var posts []database.RestorePostParams
+	for i, title := range []string{"Generics", "Errors", "Iterators"} {
+		post := database.RestorePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   at(i),
+			UpdatedAt:   at(i),
+			Title:       title,
+			Url:         "https://example.com/" + title,
+			Description: "About " + title,
+			PublishedAt: nullTime(at(i)),
+			FeedID:      feed.ID,
+			Categories:  []string{"go"},
+			Guid:        title,
+		}
+		if err := f.db.RestorePost(f.ctx, post); err != nil {
+			t.Fatalf("RestorePost(%v): %v", title, err)
+		}
+		posts = append(posts, post)
+	}
+	if err := f.db.RestorePostRead(f.ctx, database.RestorePostReadParams{UserID: usr.ID, PostID: posts[0].ID, ReadAt: at(5)}); err != nil {
+		t.Fatalf("RestorePostRead: %v", err)
+	}
+	star := database.RestorePostStarParams{UserID: usr.ID, PostID: posts[1].ID, Note: "later", CreatedAt: at(5), UpdatedAt: at(6)}
+	if err := f.db.RestorePostStar(f.ctx, star); err != nil {
+		t.Fatalf("RestorePostStar: %v", err)
+	}
+	token := database.RestoreAPITokenParams{ID: uuid.New(), CreatedAt: at(0), Name: "laptop", TokenHash: "hash", UserID: usr.ID, LastUsedAt: nullTime(at(7))}
+	if err := f.db.RestoreAPIToken(f.ctx, token); err != nil {
+		t.Fatalf("RestoreAPIToken: %v", err)
+	}
+	pruned := database.RestorePrunedPostParams{FeedID: feed.ID, Guid: "Generators"}
+	if err := f.db.RestorePrunedPost(f.ctx, pruned); err != nil {
+		t.Fatalf("RestorePrunedPost: %v", err)
+	}
+
+	users, err := f.db.ListUsers(f.ctx)
+	if err != nil || len(users) != 1 || users[0].ID != usr.ID || !users[0].UpdatedAt.Equal(usr.UpdatedAt) || users[0].Name != usr.Name {
+		t.Errorf("ListUsers = %+v, %v", users, err)
+	}
+	feeds, err := f.db.ListFeeds(f.ctx)
+	if err != nil || len(feeds) != 1 {
+		t.Fatalf("ListFeeds = %+v, %v", feeds, err)
+	}
+	if got := feeds[0]; got.ID != feed.ID || got.Url != feed.Url || got.UserID != usr.ID || !got.UpdatedAt.Equal(feed.UpdatedAt) ||
+		!got.NextFetchAt.Time.Equal(feed.NextFetchAt.Time) || got.Etag != feed.Etag || got.LastBodySize != 1024 ||
+		got.LastError != "timeout" || got.ConsecutiveFailures != 1 || got.HintedIntervalSeconds != 3600 ||
+		len(got.SkipHours) != 2 || len(got.SkipDays) != 1 || got.SkipDays[0] != "Sunday" ||
+		got.RetentionMaxPosts != feed.RetentionMaxPosts || got.RetentionKeepUnread != feed.RetentionKeepUnread || !got.PublicOnly ||
+		got.RetentionMaxAgeSeconds.Valid || got.LeaseOwner != "" || got.LeaseExpiresAt.Valid {
+		t.Errorf("ListFeeds = %+v, want the restored feed", got)
+	}
+	follows, err := f.db.ListFeedFollows(f.ctx)
+	if err != nil || len(follows) != 1 || follows[0].ID != follow.ID || follows[0].Category != "tech" {
+		t.Errorf("ListFeedFollows = %+v, %v", follows, err)
+	}
+
+	// Paging by id
+	sorted := []uuid.UUID{posts[0].ID, posts[1].ID, posts[2].ID}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+	page, err := f.db.ListPosts(f.ctx, database.ListPostsParams{Limit: 2})
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	var got []uuid.UUID
+	for _, post := range page {
+		got = append(got, post.ID)
+	}
+	checkIDs(t, "ListPosts first page", got, sorted[:2]...)
+	page, _ = f.db.ListPosts(f.ctx, database.ListPostsParams{After: page[1].ID, Limit: 2})
+	if len(page) != 1 || page[0].ID != sorted[2] || len(page[0].Categories) != 1 || page[0].Guid == "" || !page[0].PublishedAt.Valid {
+		t.Errorf("ListPosts second page = %+v", page)
+	}
+
+	reads, err := f.db.ListPostReads(f.ctx)
+	if err != nil || len(reads) != 1 || reads[0].PostID != posts[0].ID || !reads[0].ReadAt.Equal(at(5)) {
+		t.Errorf("ListPostReads = %+v, %v", reads, err)
+	}
+	stars, err := f.db.ListPostStars(f.ctx)
+	if err != nil || len(stars) != 1 || stars[0].PostID != posts[1].ID || stars[0].Note != "later" || !stars[0].UpdatedAt.Equal(at(6)) {
+		t.Errorf("ListPostStars = %+v, %v", stars, err)
+	}
+	tokens, err := f.db.ListAPITokens(f.ctx)
+	if err != nil || len(tokens) != 1 || tokens[0].TokenHash != "hash" || !tokens[0].LastUsedAt.Time.Equal(at(7)) {
+		t.Errorf("ListAPITokens = %+v, %v", tokens, err)
+	}
+	if u, err := f.db.GetUserByAPIToken(f.ctx, database.GetUserByAPITokenParams{TokenHash: "hash", LastUsedAt: nullTime(at(8))}); err != nil || u.ID != usr.ID {
+		t.Errorf("restored token doesn't authenticate: %+v, %v", u, err)
+	}
+	if err := f.db.RestorePrunedPost(f.ctx, pruned); err != nil {
+		t.Errorf("RestorePrunedPost again: %v", err)
+	}
+	if got, err := f.db.ListPrunedPosts(f.ctx); err != nil || len(got) != 1 || got[0].FeedID != feed.ID || got[0].Guid != "Generators" {
+		t.Errorf("ListPrunedPosts = %+v, %v", got, err)
+	}
+	if _, err := f.db.CreatePost(f.ctx, database.CreatePostParams{
+		ID: uuid.New(), CreatedAt: at(8), UpdatedAt: at(8), Title: "Generators", FeedID: feed.ID, Categories: []string{}, Guid: "Generators",
+	}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("CreatePost of a restored pruned item: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Restoring a row again replaces it, search follows along
+	posts[2].Title = "Range functions"
+	if err := f.db.RestorePost(f.ctx, posts[2]); err != nil {
+		t.Fatalf("RestorePost again: %v", err)
+	}
+	if results, _ := f.db.SearchPostsForUser(f.ctx, database.SearchPostsForUserParams{Query: "range", UserID: usr.ID, Limit: 10}); len(results) != 1 || results[0].ID != posts[2].ID {
+		t.Errorf("search for a replaced title = %+v", results)
+	}
+	if results, _ := f.db.SearchPostsForUser(f.ctx, database.SearchPostsForUserParams{Query: "iterators", UserID: usr.ID, Limit: 10}); len(results) != 1 {
+		t.Errorf("search for a replaced post's description = %+v", results)
+	}
+	feed.Name = "Renamed"
+	if err := f.db.RestoreFeed(f.ctx, feed); err != nil {
+		t.Fatalf("RestoreFeed again: %v", err)
+	}
+	if got, _ := f.db.GetFeed(f.ctx, feed.Url); got.Name != "Renamed" {
+		t.Errorf("feed after restoring again = %+v", got)
+	}
+	if err := f.db.RestorePostRead(f.ctx, database.RestorePostReadParams{UserID: usr.ID, PostID: posts[0].ID, ReadAt: at(9)}); err != nil {
+		t.Fatalf("RestorePostRead again: %v", err)
+	}
+	if reads, _ := f.db.ListPostReads(f.ctx); len(reads) != 1 || !reads[0].ReadAt.Equal(at(9)) {
+		t.Errorf("reads after restoring again = %+v", reads)
+	}
+
+	// Unique keys other than the primary key still hold
+	dupUser := usr
+	dupUser.ID = uuid.New()
+	if err := f.db.RestoreUser(f.ctx, dupUser); !storage.IsDuplicate(err) {
+		t.Errorf("RestoreUser with a taken name: got %v, want a duplicate error", err)
+	}
+	dupFeed := feed
+	dupFeed.ID = uuid.New()
+	if err := f.db.RestoreFeed(f.ctx, dupFeed); !storage.IsDuplicate(err) {
+		t.Errorf("RestoreFeed with a taken url: got %v, want a duplicate error", err)
+	}
+	dupPost := posts[0]
+	dupPost.ID = uuid.New()
+	if err := f.db.RestorePost(f.ctx, dupPost); !storage.IsDuplicate(err) {
+		t.Errorf("RestorePost with a taken guid: got %v, want a duplicate error", err)
+	}
+	dupToken := token
+	dupToken.ID = uuid.New()
+	if err := f.db.RestoreAPIToken(f.ctx, dupToken); !storage.IsDuplicate(err) {
+		t.Errorf("RestoreAPIToken with a taken hash: got %v, want a duplicate error", err)
+	}
+}
+
+func testInTx(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	stop := errors.New("stop")
+	inTx := func(opts *sql.TxOptions, fn func(tx *fixture) error) error {
+		t.Helper()
+		return f.db.InTx(f.ctx, opts, func(db storage.Store) error {
+			return fn(&fixture{t: t, ctx: f.ctx, db: db})
+		})
+	}
+
+	// Committed, including a call that opens its own transaction outside InTx
+	err := inTx(nil, func(tx *fixture) error {
+		tx.follow(alice, tx.feed(alice, "Blog", "https://example.com/feed"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InTx = %v", err)
+	}
+	if follows, err := f.db.GetFeedFollowsForUser(f.ctx, alice.ID); err != nil || len(follows) != 1 {
+		t.Errorf("follows after commit = %+v, %v", follows, err)
+	}
+
+	// Rolled back, nested InTx included
+	err = inTx(nil, func(tx *fixture) error {
+		bob := tx.user("bob")
+		news := tx.feed(bob, "News", "https://example.com/news")
+		tx.follow(bob, news)
+		tx.post(news, "Gone", "", 0, nil)
+		if err := tx.db.InTx(tx.ctx, nil, func(db storage.Store) error {
+			(&fixture{t: t, ctx: tx.ctx, db: db}).user("carol")
+			return nil
+		}); err != nil {
+			return err
+		}
+		if names, err := tx.db.GetUsers(tx.ctx); err != nil || len(names) != 3 {
+			t.Errorf("GetUsers inside InTx = %v, %v", names, err)
+		}
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("InTx = %v, want fn's error", err)
+	}
+	if names, err := f.db.GetUsers(f.ctx); err != nil || len(names) != 1 || names[0] != "alice" {
+		t.Errorf("GetUsers after rollback = %v, %v", names, err)
+	}
+	if _, err := f.db.GetFeed(f.ctx, "https://example.com/news"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFeed after rollback: got %v, want sql.ErrNoRows", err)
+	}
+
+	// A read only snapshot, as backups take
+	err = inTx(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *fixture) error {
+		feeds, err := tx.db.ListFeeds(tx.ctx)
+		if err != nil || len(feeds) != 1 {
+			t.Errorf("ListFeeds in a read only InTx = %+v, %v", feeds, err)
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("read only InTx = %v", err)
+	}
+}
+
+func testClearDB(t *testing.T, f *fixture) {
+	alice := f.user("alice")
+	feed := f.feed(alice, "Blog", "https://example.com/feed")
+	f.follow(alice, feed)
+	post := f.post(feed, "Post", "", 0, nil)
+	if _, err := f.db.StarPost(f.ctx, database.StarPostParams{UserID: alice.ID, PostID: post.ID, StarredAt: at(0)}); err != nil {
+		t.Fatalf("StarPost: %v", err)
+	}
+
+	if err := f.db.ClearDB(f.ctx); err != nil {
+		t.Fatalf("ClearDB: %v", err)
+	}
+	if names, err := f.db.GetUsers(f.ctx); err != nil || len(names) != 0 {
+		t.Errorf("GetUsers after ClearDB = %v, %v", names, err)
+	}
+	if _, err := f.db.GetFeed(f.ctx, feed.Url); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("feeds should be deleted with their user: %v", err)
+	}
+	if cadence, err := f.db.GetFeedPostCadence(f.ctx, feed.ID); err != nil || cadence != 0 {
+		t.Errorf("posts should be deleted with their feed: %v, %v", cadence, err)
+	}
+	// The name is free again
+	f.user("alice")
+}
+
+func rowIDs(rows []database.BrowsePostsByPublishedRow) []uuid.UUID {
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids
+}
+
+func checkIDs(t *testing.T, name string, got []uuid.UUID, want ...uuid.UUID) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%v:\ngot  %v\nwant %v", name, got, want)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFeed(feeds []database.Feed, id uuid.UUID) bool {
+	for _, feed := range feeds {
+		if feed.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func feedURLs(feeds []database.Feed) []string {
+	urls := make([]string, len(feeds))
+	for i, feed := range feeds {
+		urls[i] = feed.Url
+	}
+	return urls
+}
@@ -3,14 +3,19 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
 	"html"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,11 +24,16 @@ import (
 
 	"github.com/striderjg/gator/internal/config"
 	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/output"
+	"github.com/striderjg/gator/internal/storage"
+	"github.com/striderjg/gator/internal/storage/sqlite"
 )
 
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	db     storage.Store
+	conn   *sql.DB
+	schema fs.FS
+	cfg    *config.Config
 }
 
 type command struct {
@@ -32,25 +42,24 @@ type command struct {
 }
 
 type commands struct {
-	cmdHandlers map[string]func(*state, command) error
+	cmdHandlers map[string]func(context.Context, *state, command) error
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
+func (c *commands) register(name string, f func(context.Context, *state, command) error) {
 	c.cmdHandlers[name] = f
 }
-func (c *commands) run(s *state, cmd command) error {
+func (c *commands) run(ctx context.Context, s *state, cmd command) error {
 	if _, ok := c.cmdHandlers[cmd.name]; !ok {
 		return fmt.Errorf("command: %v does not exist", cmd.name)
 	}
-	return c.cmdHandlers[cmd.name](s, cmd)
+	return c.cmdHandlers[cmd.name](ctx, s, cmd)
 }
 
 // ===================== HANDLERS ===============================================
-func handlerLogin(s *state, cmd command) error {
+func handlerLogin(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) == 0 {
 		return errors.New("the login handler expects a single argument, the username")
 	}
-	ctx := context.Background()
 	_, err := s.db.GetUser(ctx, cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("user %v doesn't exist: %w", cmd.args[0], err)
@@ -63,26 +72,82 @@ func handlerLogin(s *state, cmd command) error {
 	return nil
 }
 
-func handlerAddFeed(s *state, cmd command, usr database.User) error {
-	if len(cmd.args) < 2 {
-		return errors.New("the addfeed handler expects two arguments: Usage: addfeed NAME URL")
+// addedFeedOutput is the record `addfeed --output` writes.
+type addedFeedOutput struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func handlerAddFeed(ctx context.Context, s *state, cmd command, usr database.User) error {
+	flags := flag.NewFlagSet("addfeed", flag.ContinueOnError)
+	var format output.Format
+	flags.Var(&format, "output", "text, json, jsonl, csv, yaml or table")
+	args, err := parseFlags(flags, cmd.args)
+	if err != nil {
+		return err
 	}
-	ctx := context.Background()
-	_, err := fetchFeed(ctx, cmd.args[1])
+	if len(args) < 2 {
+		return errors.New("the addfeed handler expects two arguments: Usage: addfeed [--output FORMAT] NAME URL")
+	}
+	feedEntry, err := addFeed(ctx, s, usr, args[0], args[1], "")
+	if err != nil {
+		return err
+	}
+
+	if !format.IsText() {
+		return output.Write(os.Stdout, format, addedFeedOutput{
+			ID:        feedEntry.ID,
+			Name:      feedEntry.Name,
+			URL:       feedEntry.Url,
+			UserID:    feedEntry.UserID,
+			CreatedAt: feedEntry.CreatedAt,
+			UpdatedAt: feedEntry.UpdatedAt,
+		})
+	}
+
+	fmt.Println("Added to feeds:")
+	fmt.Println("=================================")
+	fmt.Printf("id: %v, created_at: %v, updated_at: %v\n", feedEntry.ID, feedEntry.CreatedAt, feedEntry.UpdatedAt)
+	fmt.Println("\tname: ", feedEntry.Name)
+	fmt.Println("\turl: ", feedEntry.Url)
+	fmt.Println("\tuser_id: ", feedEntry.UserID)
+	return nil
+}
+
+// existingFeedError is returned by addFeed when the feed it found is already in the database.
+type existingFeedError struct {
+	feed database.Feed
+}
+
+func (e *existingFeedError) Error() string {
+	return fmt.Sprintf("feed %v is already in the database as %v, follow it with: gator follow %v", e.feed.Url, e.feed.Name, e.feed.Url)
+}
+
+// addFeed finds the feed at url, which can also be the site's homepage, then creates it and follows it for usr.
+func addFeed(ctx context.Context, s *state, usr database.User, name, url, category string) (database.Feed, error) {
+	url, err := discoverFeed(ctx, url)
 	if err != nil {
-		return fmt.Errorf("error fetching feed: %w", err)
+		return database.Feed{}, fmt.Errorf("error fetching feed: %w", err)
+	}
+	if existing, err := s.db.GetFeed(ctx, url); err == nil {
+		return database.Feed{}, &existingFeedError{feed: existing}
 	}
 
 	feedEntry, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      cmd.args[0],
-		Url:       cmd.args[1],
-		UserID:    usr.ID,
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Name:       name,
+		Url:        url,
+		UserID:     usr.ID,
+		PublicOnly: publicOnly(ctx),
 	})
 	if err != nil {
-		return fmt.Errorf("error creating feed entry: %w", err)
+		return database.Feed{}, fmt.Errorf("error creating feed entry: %w", err)
 	}
 	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		ID:        uuid.New(),
@@ -90,25 +155,55 @@ func handlerAddFeed(s *state, cmd command, usr database.User) error {
 		UpdatedAt: time.Now(),
 		UserID:    usr.ID,
 		FeedID:    feedEntry.ID,
+		Category:  category,
 	})
 	if err != nil {
-		return fmt.Errorf("error creating feed_follows entry: %w", err)
+		return database.Feed{}, fmt.Errorf("error creating feed_follows entry: %w", err)
 	}
+	return feedEntry, nil
+}
 
-	fmt.Println("Added to feeds:")
-	fmt.Println("=================================")
-	fmt.Printf("id: %v, created_at: %v, updated_at: %v\n", feedEntry.ID, feedEntry.CreatedAt, feedEntry.UpdatedAt)
-	fmt.Println("\tname: ", feedEntry.Name)
-	fmt.Println("\turl: ", feedEntry.Url)
-	fmt.Println("\tuser_id: ", feedEntry.UserID)
-	return nil
+// feedOutput is the record `feeds --output` writes for every feed.
+type feedOutput struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Owner string `json:"owner"`
+}
+
+// feedErrorOutput is the record `feeds --errors --output` writes for every failing feed.
+type feedErrorOutput struct {
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Disabled            bool       `json:"disabled"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error"`
+	LastErrorAt         *time.Time `json:"last_error_at"`
+	NextFetchAt         *time.Time `json:"next_fetch_at"`
 }
 
-func handlerFeeds(s *state, cmd command) error {
-	feeds, err := s.db.GetFeeds(context.Background())
+func handlerFeeds(ctx context.Context, s *state, cmd command) error {
+	flags := flag.NewFlagSet("feeds", flag.ContinueOnError)
+	showErrors := flags.Bool("errors", false, "list feeds that are failing or disabled")
+	var format output.Format
+	flags.Var(&format, "output", "text, json, jsonl, csv, yaml or table")
+	if _, err := parseFlags(flags, cmd.args); err != nil {
+		return err
+	}
+	if *showErrors {
+		return listFeedErrors(ctx, s, format)
+	}
+
+	feeds, err := s.db.GetFeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting feeds from db: %w", err)
 	}
+	if !format.IsText() {
+		records := make([]feedOutput, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, feedOutput{Name: feed.Name, URL: feed.Url, Owner: feed.Username})
+		}
+		return output.Write(os.Stdout, format, records)
+	}
 	for _, feed := range feeds {
 		fmt.Println("1)")
 		fmt.Println("\tName: ", feed.Name)
@@ -119,12 +214,111 @@ func handlerFeeds(s *state, cmd command) error {
 	return nil
 }
 
-func handlerUnfollow(s *state, cmd command, usr database.User) error {
+func listFeedErrors(ctx context.Context, s *state, format output.Format) error {
+	feeds, err := s.db.GetFeedErrors(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting feed errors from db: %w", err)
+	}
+	if !format.IsText() {
+		records := make([]feedErrorOutput, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, feedErrorOutput{
+				Name:                feed.Name,
+				URL:                 feed.Url,
+				Disabled:            feed.Disabled,
+				ConsecutiveFailures: feed.ConsecutiveFailures,
+				LastError:           feed.LastError,
+				LastErrorAt:         nullTimePtr(feed.LastErrorAt),
+				NextFetchAt:         nullTimePtr(feed.NextFetchAt),
+			})
+		}
+		return output.Write(os.Stdout, format, records)
+	}
+	if len(feeds) == 0 {
+		fmt.Println("No failing feeds")
+		return nil
+	}
+	for _, feed := range feeds {
+		status := "failing"
+		if feed.Disabled {
+			status = "DISABLED"
+		}
+		fmt.Printf("%v (%v)\n", feed.Name, status)
+		fmt.Println("\tUrl: ", feed.Url)
+		fmt.Println("\tConsecutive failures: ", feed.ConsecutiveFailures)
+		if feed.LastErrorAt.Valid {
+			fmt.Println("\tLast error at: ", feed.LastErrorAt.Time.Format(time.RFC1123))
+		}
+		fmt.Println("\tLast error: ", feed.LastError)
+		if !feed.Disabled && feed.NextFetchAt.Valid {
+			fmt.Println("\tNext attempt: ", feed.NextFetchAt.Time.Format(time.RFC1123))
+		}
+		fmt.Println("=================================================")
+	}
+	return nil
+}
+
+func handlerFeed(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return errors.New("feed expects a subcommand.  Usage: feed enable URL | feed set-interval URL DURATION|auto | feed set-retention URL [--max-age AGE] [--max-posts N] [--keep-unread BOOL]")
+	}
+	switch cmd.args[0] {
+	case "set-interval":
+		if len(cmd.args) < 3 {
+			return errors.New("feed set-interval expects two arguments.  Usage: feed set-interval URL DURATION|auto")
+		}
+		override := sql.NullInt32{}
+		if cmd.args[2] != "auto" {
+			interval, err := time.ParseDuration(cmd.args[2])
+			if err != nil {
+				return fmt.Errorf("error parsing time duration: %w", err)
+			}
+			if interval < time.Minute {
+				return errors.New("interval must be at least 1m")
+			}
+			override = sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true}
+		}
+		n, err := s.db.SetFeedIntervalOverride(ctx, database.SetFeedIntervalOverrideParams{
+			Url:                     cmd.args[1],
+			IntervalOverrideSeconds: override,
+		})
+		if err != nil {
+			return fmt.Errorf("error setting feed interval: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("feed %v does not exist", cmd.args[1])
+		}
+		if override.Valid {
+			fmt.Printf("Feed %v will be fetched every %v\n", cmd.args[1], cmd.args[2])
+		} else {
+			fmt.Printf("Feed %v is back on automatic scheduling\n", cmd.args[1])
+		}
+		return nil
+	case "set-retention":
+		return handleFeedSetRetention(ctx, s, cmd.args[1:])
+	case "enable":
+		if len(cmd.args) < 2 {
+			return errors.New("feed enable expects an argument.  Usage: feed enable URL")
+		}
+		n, err := s.db.EnableFeed(ctx, cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("error enabling feed: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("feed %v does not exist", cmd.args[1])
+		}
+		fmt.Printf("Feed %v enabled\n", cmd.args[1])
+		return nil
+	default:
+		return fmt.Errorf("feed: unknown subcommand %v", cmd.args[0])
+	}
+}
+
+func handlerUnfollow(ctx context.Context, s *state, cmd command, usr database.User) error {
 	if len(cmd.args) < 1 {
 		return errors.New("unfollow expects an argument.  Usage: unfollow URL")
 	}
 
-	ctx := context.Background()
 	feed, err := s.db.GetFeed(ctx, cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("error retrieving feed: %w", err)
@@ -143,13 +337,23 @@ func handlerUnfollow(s *state, cmd command, usr database.User) error {
 	return nil
 }
 
-func handlerFollow(s *state, cmd command, usr database.User) error {
+func handlerFollow(ctx context.Context, s *state, cmd command, usr database.User) error {
 	if len(cmd.args) < 1 {
 		return errors.New("follow expect an argument.  Usage: follow URL")
 	}
 
-	ctx := context.Background()
 	feed, err := s.db.GetFeed(ctx, cmd.args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		// Might be the site rather than the feed itself
+		feedURL, discoverErr := discoverFeed(ctx, cmd.args[0])
+		if discoverErr != nil {
+			return fmt.Errorf("feed %v isn't in the database and no feed was found there: %w", cmd.args[0], discoverErr)
+		}
+		feed, err = s.db.GetFeed(ctx, feedURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("feed %v isn't in the database yet, add it with: gator addfeed NAME %v", feedURL, feedURL)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("error retrieving feed: %w", err)
 	}
@@ -169,66 +373,184 @@ func handlerFollow(s *state, cmd command, usr database.User) error {
 	return nil
 }
 
-func handlerFollowing(s *state, cmd command, usr database.User) error {
-	feeds, err := s.db.GetFeedFollowsForUser(context.Background(), usr.ID)
+// followOutput is the record `following --output` writes for every followed feed.
+type followOutput struct {
+	FeedID      uuid.UUID `json:"feed_id"`
+	FeedName    string    `json:"feed_name"`
+	FeedURL     string    `json:"feed_url"`
+	Category    string    `json:"category"`
+	UnreadCount int64     `json:"unread_count"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+func handlerFollowing(ctx context.Context, s *state, cmd command, usr database.User) error {
+	flags := flag.NewFlagSet("following", flag.ContinueOnError)
+	var format output.Format
+	flags.Var(&format, "output", "text, json, jsonl, csv, yaml or table")
+	if _, err := parseFlags(flags, cmd.args); err != nil {
+		return err
+	}
+
+	feeds, err := s.db.GetFeedFollowsForUser(ctx, usr.ID)
 	if err != nil {
-		fmt.Errorf("error retrieving follows for user: %w", err)
+		return fmt.Errorf("error retrieving follows for user: %w", err)
+	}
+	if !format.IsText() {
+		records := make([]followOutput, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, followOutput{
+				FeedID:      feed.FeedID,
+				FeedName:    feed.FeedName,
+				FeedURL:     feed.FeedUrl,
+				Category:    feed.Category,
+				UnreadCount: feed.UnreadCount,
+				FollowedAt:  feed.CreatedAt,
+			})
+		}
+		return output.Write(os.Stdout, format, records)
 	}
 
 	fmt.Printf("User: %v is following:\n", s.cfg.Current_user)
 	fmt.Println("===============================")
 	for _, feed := range feeds {
-		fmt.Println("  * ", feed.FeedName)
+		if feed.Category != "" {
+			fmt.Printf("  *  %v (%v unread) [%v]\n", feed.FeedName, feed.UnreadCount, feed.Category)
+		} else {
+			fmt.Printf("  *  %v (%v unread)\n", feed.FeedName, feed.UnreadCount)
+		}
 	}
 
 	return nil
 }
 
-func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) < 1 {
-		return errors.New("agg expects an argument of type [digit][s|m|h].  Usage: agg DURATION")
+func handlerAgg(ctx context.Context, s *state, cmd command) error {
+	opts := defaultAggOptions
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	flags.IntVar(&opts.workers, "workers", opts.workers, "number of feeds fetched in parallel")
+	flags.IntVar(&opts.batch, "batch", opts.batch, "number of due feeds claimed per cycle")
+	flags.IntVar(&opts.perHost, "per-host", opts.perHost, "maximum concurrent requests to a single host")
+	flags.DurationVar(&opts.lease, "lease", opts.lease, "how long a claimed feed is reserved for this process")
+	flags.DurationVar(&opts.drain, "drain", opts.drain, "how long to let in-flight fetches finish on shutdown")
+	flags.IntVar(&opts.maxFailures, "max-failures", opts.maxFailures, "consecutive failures before a feed is disabled")
+	prune := flags.Bool("prune", false, "apply the retention policies after every cycle")
+	args, err := parseFlags(flags, cmd.args)
+	if err != nil {
+		return err
 	}
-	interval, err := time.ParseDuration(cmd.args[0])
+	if len(args) < 1 {
+		return errors.New("agg expects an argument of type [digit][s|m|h].  Usage: agg DURATION [--workers N] [--batch M] [--per-host K] [--lease DURATION] [--drain DURATION] [--max-failures N] [--prune]")
+	}
+	if opts.workers < 1 || opts.batch < 1 || opts.perHost < 1 {
+		return errors.New("--workers, --batch and --per-host must be at least 1")
+	}
+	if opts.maxFailures < 1 {
+		return errors.New("--max-failures must be at least 1")
+	}
+	if opts.lease < time.Minute {
+		return errors.New("--lease must be at least 1m")
+	}
+	interval, err := time.ParseDuration(args[0])
 	if err != nil {
 		return fmt.Errorf("error parsing time duration: %w", err)
 	}
 	if interval.Seconds() < 1 {
 		return errors.New("time duration must be greater then 1 second")
 	}
-
-	fmt.Printf("Collecting feeds every %v\n", interval.String())
-	ticker := time.NewTicker(interval)
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+	if *prune {
+		// Catch a bad retention config now rather than after the first cycle
+		if _, err := globalRetention(s.cfg); err != nil {
+			return err
+		}
 	}
 
-	/*
-		feed, err := fetchFeed(context.Background(), "https://www.wagslane.dev/index.xml")
-		if err != nil {
-			return fmt.Errorf("error fetching feed: %w", err)
+	// In-flight fetches run on fetchCtx which outlives ctx by up to opts.drain after a shutdown signal
+	fetchCtx, cancelFetch := context.WithCancel(context.WithoutCancel(ctx))
+	var drainTimedOut atomic.Bool
+	drainDone := make(chan struct{})
+	// The drain goroutine prints, don't leave it running after agg returns
+	defer func() {
+		cancelFetch()
+		<-drainDone
+	}()
+	go func() {
+		defer close(drainDone)
+		select {
+		case <-ctx.Done():
+		case <-fetchCtx.Done():
+			return
+		}
+		fmt.Printf("Shutting down, waiting up to %v for in-flight fetches (interrupt again to force)\n", opts.drain)
+		timer := time.NewTimer(opts.drain)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			drainTimedOut.Store(true)
+			cancelFetch()
+		case <-fetchCtx.Done():
 		}
+	}()
 
-		fmt.Printf("<TITLE>%v</TITLE>\n", feed.Channel.Title)
-		fmt.Printf("<LINK>%v</LINK>\n", feed.Channel.Link)
-		fmt.Printf("<Description>%v</Description>\n", feed.Channel.Description)
-		fmt.Println("============================================================")
+	fmt.Printf("Collecting %v feeds every %v with %v workers\n", opts.batch, interval.String(), opts.workers)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		summary, err := scrapeFeeds(ctx, fetchCtx, s, opts)
+		if err != nil {
+			// TODO:  LOG ERROR
+			fmt.Println(err.Error())
+		} else {
+			fmt.Printf("Cycle finished in %v: %v\n", time.Since(start).Round(time.Millisecond), summary)
+		}
+		if *prune && ctx.Err() == nil {
+			pruned, err := prunePosts(ctx, s, false)
+			if err != nil {
+				fmt.Println(err.Error())
+			} else if pruned.posts > 0 {
+				fmt.Printf("Pruned %v posts from %v feeds\n", pruned.posts, len(pruned.feeds))
+			}
+		}
 
-		for _, item := range feed.Channel.Item {
-			fmt.Printf("\t<TITLE>%v</TITLE>\n", item.Title)
-			fmt.Printf("\t<LINK>%v</LINK>\n", item.Link)
-			fmt.Printf("\t<Description>%v</Description>\n", item.Description)
-			fmt.Println("============================================================")
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			continue
 		}
-	*/
+		break
+	}
+
+	if drainTimedOut.Load() {
+		return fmt.Errorf("in-flight fetches cancelled after the %v drain timeout", opts.drain)
+	}
+	fmt.Println("agg stopped")
 	return nil
 }
 
-func handlerGetUsers(s *state, cmd command) error {
-	ctx := context.Background()
+// userOutput is the record `users --output` writes for every user.
+type userOutput struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+func handlerGetUsers(ctx context.Context, s *state, cmd command) error {
+	flags := flag.NewFlagSet("users", flag.ContinueOnError)
+	var format output.Format
+	flags.Var(&format, "output", "text, json, jsonl, csv, yaml or table")
+	if _, err := parseFlags(flags, cmd.args); err != nil {
+		return err
+	}
+
 	users, err := s.db.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("error retrieving users: %w", err)
 	}
+	if !format.IsText() {
+		records := make([]userOutput, 0, len(users))
+		for _, name := range users {
+			records = append(records, userOutput{Name: name, Current: name == s.cfg.Current_user})
+		}
+		return output.Write(os.Stdout, format, records)
+	}
 	for _, usr := range users {
 		fmt.Printf("* %v", usr)
 		if usr == s.cfg.Current_user {
@@ -240,8 +562,7 @@ func handlerGetUsers(s *state, cmd command) error {
 	return nil
 }
 
-func handlerReset(s *state, cmd command) error {
-	ctx := context.Background()
+func handlerReset(ctx context.Context, s *state, cmd command) error {
 	if err := s.db.ClearDB(ctx); err != nil {
 		return fmt.Errorf("error reseting the db: %w", err)
 	}
@@ -249,11 +570,10 @@ func handlerReset(s *state, cmd command) error {
 	return nil
 }
 
-func handlerRegister(s *state, cmd command) error {
+func handlerRegister(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) == 0 {
 		return errors.New("the register command expects a single argument, the username")
 	}
-	ctx := context.Background()
 	usr, err := s.db.CreateUser(ctx, database.CreateUserParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
@@ -271,126 +591,129 @@ func handlerRegister(s *state, cmd command) error {
 	return nil
 }
 
-func handlerBrowse(s *state, cmd command, usr database.User) error {
-	var lim int32 = 2
-	if len(cmd.args) > 0 {
-		limInt, err := strconv.Atoi(cmd.args[0])
-		if err != nil {
-			return fmt.Errorf("error converting argument to integer.  browser expect an int: %w", err)
-		}
-		lim = int32(limInt)
-	}
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: usr.ID,
-		Limit:  lim,
-	})
+func handlerTest(ctx context.Context, s *state, cmd command) error {
+	summary, err := scrapeFeeds(ctx, ctx, s, defaultAggOptions)
 	if err != nil {
-		return fmt.Errorf("error retrieving posts for user: %w", err)
-	}
-	for _, post := range posts {
-		fmt.Println("++++++++++++++++++++++++++++++++++++++++++++++++++++")
-		fmt.Println(post.Title)
-		fmt.Println(post.Url)
-		fmt.Println("====================================================")
-		fmt.Println(post.Description)
-		fmt.Println("")
-	}
-
-	return nil
-}
-
-func handlerTest(s *state, cmd command) error {
-	if err := scrapeFeeds(s); err != nil {
 		return err
 	}
+	fmt.Println(summary)
 	return nil
 }
 
 // ============================== Utility Functions ==================================================
 
-func scrapeFeeds(s *state) error {
-	ctx := context.Background()
-	feed, err := s.db.GetNextFeedToFetch(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting next feed to fetch: %w", err)
-	}
-
-	s.db.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
-		ID:   feed.ID,
-		Time: time.Now(),
-	})
-	rssFeed, err := fetchFeed(ctx, feed.Url)
-	if err != nil {
-		return fmt.Errorf("error fetching feed at (%v): %w", feed.Url, err)
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
 	}
+	return &t.Time
+}
 
-	for _, rssItem := range rssFeed.Channel.Item {
-		pubDate := sql.NullTime{}
-		if rssItem.PubDate != "" {
-			pubDate.Valid = true
-			pubDate.Time, err = time.Parse(time.RFC1123, rssItem.PubDate)
-			if err != nil {
-				pubDate.Time, err = time.Parse(time.RFC1123Z, rssItem.PubDate)
-				if err != nil {
-					// TODO:  LOG ERROR
-					fmt.Println("============= ERROR ==============")
-					fmt.Println("Failed to parse Publish Date")
-					fmt.Println("==================================")
-					pubDate.Time = time.Time{}
-					pubDate.Valid = false
-				}
-			}
+// parseFlags lets flags and positional arguments be mixed in any order.  Returns the positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
 		}
-
-		retPost, err := s.db.CreatePost(ctx, database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       rssItem.Title,
-			Url:         rssItem.Link,
-			Description: rssItem.Description,
-			PublishedAt: pubDate,
-			FeedID:      feed.ID,
-		})
-		if err != nil {
-			if !strings.Contains(err.Error(), "duplicate key value") {
-				// TODO:  LOG ERROR
-				fmt.Println("=========== ERROR ===========")
-				fmt.Printf("Error creating Post: %v\n", rssItem.Title)
-				fmt.Printf("In feed: %v\n", feed.Url)
-				fmt.Println(err.Error())
-				fmt.Println("=============================")
-			}
-		} else {
-			fmt.Println("Saved: ", retPost.Title)
-			fmt.Println("")
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
 		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-
-	return nil
 }
 
-func middlewareLoggedIn(handler func(s *state, cmd command, usr database.User) error) func(*state, command) error {
-	return func(s *state, cmd command) error {
-		usr, err := s.db.GetUser(context.Background(), s.cfg.Current_user)
+func middlewareLoggedIn(handler func(ctx context.Context, s *state, cmd command, usr database.User) error) func(context.Context, *state, command) error {
+	return func(ctx context.Context, s *state, cmd command) error {
+		usr, err := s.db.GetUser(ctx, s.cfg.Current_user)
 		if err != nil {
 			return fmt.Errorf("error fetching current user: %w", err)
 		}
-		return handler(s, cmd, usr)
+		return handler(ctx, s, cmd, usr)
 	}
 }
 
+// feedClient has a timeout so a hung server can't hold a feed lease past its expiry.
+var feedClient = &http.Client{Timeout: time.Minute}
+
+type publicOnlyKey struct{}
+
+// withPublicOnly makes fetchFeed and discoverFeed go through apiFeedClient for requests made with the
+// returned context, and addFeed mark the feeds it creates with it as public only.
+func withPublicOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, publicOnlyKey{}, true)
+}
+
+func publicOnly(ctx context.Context) bool {
+	return ctx.Value(publicOnlyKey{}) == true
+}
+
+func feedClientFor(ctx context.Context) *http.Client {
+	if publicOnly(ctx) {
+		return apiFeedClient
+	}
+	return feedClient
+}
+
+// maxBodySize caps how much of a feed or page is read, so one huge or endless response can't use up memory.
+const maxBodySize = 8 << 20
+
+// readLimited reads r to the end, failing once it's past maxBodySize.
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("response is larger than %v MB", maxBodySize>>20)
+	}
+	return body, nil
+}
+
+type fetchResult struct {
+	Feed         *RSSFeed
+	NotModified  bool
+	ETag         string
+	LastModified string
+	BodySize     int64
+}
+
 func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	result, err := fetchFeedConditional(ctx, feedURL, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return result.Feed, nil
+}
+
+// fetchFeedConditional sends If-None-Match/If-Modified-Since when etag/lastModified are set.
+// A 304 comes back as a result with NotModified set and no Feed.
+func fetchFeedConditional(ctx context.Context, feedURL, etag, lastModified string) (*fetchResult, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	req.Header.Set("User-Agent", "gator")
-	res, err := http.DefaultClient.Do(req)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	res, err := feedClientFor(ctx).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error getting responce: %w", err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		return &fetchResult{
+			NotModified:  true,
+			ETag:         etag,
+			LastModified: lastModified,
+		}, nil
+	}
 	if res.StatusCode < 200 || res.StatusCode > 299 {
 		return nil, errors.New("bad Status Code from response")
 	}
@@ -406,14 +729,17 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		}
 	*/
 
-	var feed RSSFeed
 	//var feed TestRSSFeed
 	//var feed TestRSSFeed
 	//var feedT TestChannel
 
-	decoder := xml.NewDecoder(res.Body)
-	if err := decoder.Decode(&feed); err != nil {
-		return nil, fmt.Errorf("error decoding RSSFeed: %w", err)
+	body, err := readLimited(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	feed, err := parseFeed(body, res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
 	}
 	// ===================== TODO:  Try to figure out what's going on with channel link
 	//  == Leaving the comments in for later use.
@@ -471,7 +797,46 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
 	}
 
-	return &feed, nil
+	return &fetchResult{
+		Feed:         feed,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		BodySize:     int64(len(body)),
+	}, nil
+}
+
+// openDatabase connects to Db_url.  A sqlite://PATH url uses a SQLite file, anything else is a Postgres connection string.
+func openDatabase(s *state) error {
+	if path, ok := strings.CutPrefix(s.cfg.Db_url, "sqlite://"); ok {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("error getting path to home directory: %w", err)
+			}
+			path = filepath.Join(home, rest)
+		}
+		db, err := sqlite.Open(path)
+		if err != nil {
+			return err
+		}
+		s.db = sqlite.New(db)
+		s.conn = db
+		s.schema = sqlite.Migrations()
+		return nil
+	}
+
+	db, err := sql.Open("postgres", s.cfg.Db_url)
+	if err != nil {
+		return err
+	}
+	schema, err := fs.Sub(schemaFS, "sql/schema")
+	if err != nil {
+		return err
+	}
+	s.db = storage.NewPostgres(db)
+	s.conn = db
+	s.schema = schema
+	return nil
 }
 
 // . ================================ ENTRY POINT ============================================
@@ -486,14 +851,13 @@ func main() {
 	}
 
 	cmds := commands{
-		cmdHandlers: make(map[string]func(*state, command) error),
+		cmdHandlers: make(map[string]func(context.Context, *state, command) error),
 	}
 
-	db, err := sql.Open("postgres", mainState.cfg.Db_url)
-	if err != nil {
+	if err := openDatabase(&mainState); err != nil {
 		fmt.Printf("Error opening database %v\n", err.Error())
+		return
 	}
-	mainState.db = database.New(db)
 
 	// -- Handler registration
 	cmds.register("login", handlerLogin)
@@ -503,10 +867,26 @@ func main() {
 	cmds.register("agg", handlerAgg)
 	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
 	cmds.register("feeds", handlerFeeds)
+	cmds.register("feed", handlerFeed)
 	cmds.register("follow", middlewareLoggedIn(handlerFollow))
 	cmds.register("following", middlewareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
 	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	cmds.register("read", middlewareLoggedIn(handlerRead))
+	cmds.register("mark-read", middlewareLoggedIn(handlerMarkRead))
+	cmds.register("star", middlewareLoggedIn(handlerStar))
+	cmds.register("unstar", middlewareLoggedIn(handlerUnstar))
+	cmds.register("starred", middlewareLoggedIn(handlerStarred))
+	cmds.register("search", middlewareLoggedIn(handlerSearch))
+	cmds.register("import", middlewareLoggedIn(handlerImport))
+	cmds.register("export", middlewareLoggedIn(handlerExport))
+	cmds.register("token", middlewareLoggedIn(handlerToken))
+	cmds.register("serve", handlerServe)
+	cmds.register("web", middlewareLoggedIn(handlerWeb))
+	cmds.register("prune", handlerPrune)
+	cmds.register("backup", handlerBackup)
+	cmds.register("restore", handlerRestore)
+	cmds.register("migrate", handlerMigrate)
 	cmds.register("test", handlerTest)
 
 	// -- Start
@@ -514,7 +894,22 @@ func main() {
 		fmt.Println("Usage: gator requires a command in format gator COMMAND [ARGUMENTS]")
 		os.Exit(1)
 	}
-	err = cmds.run(&mainState, command{os.Args[1], os.Args[2:]})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Only the first signal is graceful, a second one gets the default behaviour and kills the process
+		<-ctx.Done()
+		stop()
+	}()
+	if os.Args[1] != "migrate" {
+		if err := checkSchema(ctx, &mainState); err != nil {
+			stop()
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	err = cmds.run(ctx, &mainState, command{os.Args[1], os.Args[2:]})
+	stop()
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
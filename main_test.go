@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/config"
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/storage/memory"
+)
+
+// testEnv is a registered, logged in user on an in-memory store.  Handlers print to stdout so run captures it,
+// which means handler tests can't be parallel.
+type testEnv struct {
+	t   *testing.T
+	ctx context.Context
+	s   *state
+	usr database.User
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	// SetUser writes ~/.gatorconfig.json
+	t.Setenv("HOME", t.TempDir())
+	env := &testEnv{t: t, ctx: context.Background(), s: &state{db: memory.New(), cfg: &config.Config{}}}
+	env.mustRun(handlerRegister, "alice")
+	usr, err := env.s.db.GetUser(env.ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	env.usr = usr
+	return env
+}
+
+// run calls handler with args and returns what it printed.
+func (env *testEnv) run(handler func(context.Context, *state, command) error, args ...string) (string, error) {
+	env.t.Helper()
+	return env.runContext(env.ctx, handler, args...)
+}
+
+func (env *testEnv) runContext(ctx context.Context, handler func(context.Context, *state, command) error, args ...string) (string, error) {
+	env.t.Helper()
+	var err error
+	out := captureStdout(env.t, func() {
+		err = handler(ctx, env.s, command{args: args})
+	})
+	return out, err
+}
+
+func (env *testEnv) mustRun(handler func(context.Context, *state, command) error, args ...string) string {
+	env.t.Helper()
+	out, err := env.run(handler, args...)
+	if err != nil {
+		env.t.Fatalf("%v: %v", args, err)
+	}
+	return out
+}
+
+// runErr runs a handler that should fail and checks the error mentions want.
+func (env *testEnv) runErr(handler func(context.Context, *state, command) error, want string, args ...string) {
+	env.t.Helper()
+	_, err := env.run(handler, args...)
+	if err == nil {
+		env.t.Fatalf("%v: no error, want one containing %q", args, want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		env.t.Fatalf("%v: error %q, want one containing %q", args, err, want)
+	}
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		r.Close()
+		done <- buf.String()
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+// feed adds a feed owned and followed by the user without going over the network.
+func (env *testEnv) feed(name, url string) database.Feed {
+	env.t.Helper()
+	feed, err := env.s.db.CreateFeed(env.ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      name,
+		Url:       url,
+		UserID:    env.usr.ID,
+	})
+	if err != nil {
+		env.t.Fatalf("CreateFeed: %v", err)
+	}
+	if _, err := env.s.db.CreateFeedFollow(env.ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    env.usr.ID,
+		FeedID:    feed.ID,
+	}); err != nil {
+		env.t.Fatalf("CreateFeedFollow: %v", err)
+	}
+	return feed
+}
+
+func (env *testEnv) post(feed database.Feed, title, description string, published time.Time) database.CreatePostRow {
+	env.t.Helper()
+	post, err := env.s.db.CreatePost(env.ctx, database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		Title:       title,
+		Url:         "https://example.com/" + strings.ReplaceAll(strings.ToLower(title), " ", "-"),
+		Description: description,
+		PublishedAt: sql.NullTime{Time: published, Valid: true},
+		FeedID:      feed.ID,
+		Categories:  []string{},
+		Guid:        title,
+	})
+	if err != nil {
+		env.t.Fatalf("CreatePost: %v", err)
+	}
+	return post
+}
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Test Blog</title>
+  <link>%[1]v</link>
+  <description>Posts for tests</description>
+  <item>
+    <title>Generics in Go</title>
+    <link>%[1]v/generics</link>
+    <guid>%[1]v/generics</guid>
+    <pubDate>Mon, 04 Mar 2024 10:00:00 +0000</pubDate>
+    <description>Type parameters arrived in Go 1.18.</description>
+  </item>
+  <item>
+    <title>Error handling</title>
+    <link>%[1]v/errors</link>
+    <guid>%[1]v/errors</guid>
+    <pubDate>Tue, 05 Mar 2024 10:00:00 +0000</pubDate>
+    <description>Wrapping errors with fmt.Errorf.</description>
+  </item>
+</channel>
+</rss>`
+
+// newFeedServer serves testRSS at /rss.xml, a page linking to it at / and 404s everywhere else.
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprintf(w, testRSS, srv.URL)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="alternate" type="application/rss+xml" href="/rss.xml"></head><body>Test Blog</body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	env := newTestEnv(t)
+	if env.s.cfg.Current_user != "alice" {
+		t.Errorf("current user = %q after register, want alice", env.s.cfg.Current_user)
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("config.Read: %v", err)
+	}
+	if cfg.Current_user != "alice" {
+		t.Errorf("config file user = %q, want alice", cfg.Current_user)
+	}
+
+	env.runErr(handlerRegister, "error creating user alice", "alice")
+	env.runErr(handlerRegister, "expects a single argument")
+
+	env.mustRun(handlerRegister, "bob")
+	out := env.mustRun(handlerLogin, "alice")
+	if !strings.Contains(out, "User: alice has been set") {
+		t.Errorf("login printed %q", out)
+	}
+	if env.s.cfg.Current_user != "alice" {
+		t.Errorf("current user = %q after login, want alice", env.s.cfg.Current_user)
+	}
+	env.runErr(handlerLogin, "user carol doesn't exist", "carol")
+	env.runErr(handlerLogin, "expects a single argument")
+}
+
+func TestUsers(t *testing.T) {
+	env := newTestEnv(t)
+	env.mustRun(handlerRegister, "bob")
+	env.mustRun(handlerLogin, "alice")
+
+	if out := env.mustRun(handlerGetUsers); out != "* alice (current)\n* bob\n" {
+		t.Errorf("users printed %q", out)
+	}
+	var users []userOutput
+	if err := json.Unmarshal([]byte(env.mustRun(handlerGetUsers, "--output", "json")), &users); err != nil {
+		t.Fatalf("decoding users: %v", err)
+	}
+	if len(users) != 2 || users[0] != (userOutput{Name: "alice", Current: true}) || users[1] != (userOutput{Name: "bob"}) {
+		t.Errorf("users = %+v", users)
+	}
+	env.runErr(handlerGetUsers, "invalid value", "--output", "xml")
+}
+
+func TestReset(t *testing.T) {
+	env := newTestEnv(t)
+	env.feed("Blog", "https://example.com/rss")
+	if out := env.mustRun(handlerReset); out != "Database reset\n" {
+		t.Errorf("reset printed %q", out)
+	}
+	if users, _ := env.s.db.GetUsers(env.ctx); len(users) != 0 {
+		t.Errorf("users left after reset: %v", users)
+	}
+	if feeds, _ := env.s.db.GetFeeds(env.ctx); len(feeds) != 0 {
+		t.Errorf("feeds left after reset: %v", feeds)
+	}
+	env.runErr(middlewareLoggedIn(handlerFollowing), "error fetching current user")
+}
+
+func TestAddFeed(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	addFeed := middlewareLoggedIn(handlerAddFeed)
+
+	// The homepage is enough, the feed is found from its <link>
+	out := env.mustRun(addFeed, "Test Blog", srv.URL)
+	if !strings.Contains(out, "url:  "+srv.URL+"/rss.xml") {
+		t.Errorf("addfeed printed %q", out)
+	}
+	feed, err := env.s.db.GetFeed(env.ctx, srv.URL+"/rss.xml")
+	if err != nil {
+		t.Fatalf("GetFeed: %v", err)
+	}
+	if feed.Name != "Test Blog" || feed.UserID != env.usr.ID {
+		t.Errorf("feed = %+v", feed)
+	}
+	follows, _ := env.s.db.GetFeedFollowsForUser(env.ctx, env.usr.ID)
+	if len(follows) != 1 || follows[0].FeedID != feed.ID {
+		t.Errorf("follows after addfeed = %+v", follows)
+	}
+
+	env.runErr(addFeed, "already in the database as Test Blog", "Again", srv.URL+"/rss.xml")
+	env.runErr(addFeed, "error fetching feed", "Missing", srv.URL+"/missing")
+	env.runErr(addFeed, "expects two arguments", "Test Blog")
+
+	env = newTestEnv(t)
+	var added addedFeedOutput
+	if err := json.Unmarshal([]byte(env.mustRun(addFeed, "--output", "json", "Test Blog", srv.URL+"/rss.xml")), &added); err != nil {
+		t.Fatalf("decoding addfeed: %v", err)
+	}
+	if added.Name != "Test Blog" || added.URL != srv.URL+"/rss.xml" || added.UserID != env.usr.ID {
+		t.Errorf("addfeed --output json = %+v", added)
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	env := newTestEnv(t)
+	if out := env.mustRun(handlerFeeds); out != "" {
+		t.Errorf("feeds printed %q with no feeds", out)
+	}
+	feed := env.feed("Blog", "https://example.com/rss")
+	out := env.mustRun(handlerFeeds)
+	if !strings.Contains(out, "Name:  Blog") || !strings.Contains(out, "Owner:  alice") {
+		t.Errorf("feeds printed %q", out)
+	}
+	var feeds []feedOutput
+	if err := json.Unmarshal([]byte(env.mustRun(handlerFeeds, "--output", "json")), &feeds); err != nil {
+		t.Fatalf("decoding feeds: %v", err)
+	}
+	if len(feeds) != 1 || feeds[0] != (feedOutput{Name: "Blog", URL: "https://example.com/rss", Owner: "alice"}) {
+		t.Errorf("feeds = %+v", feeds)
+	}
+
+	if out := env.mustRun(handlerFeeds, "--errors"); out != "No failing feeds\n" {
+		t.Errorf("feeds --errors printed %q", out)
+	}
+	now := time.Now()
+	if _, err := env.s.db.RecordFeedFailure(env.ctx, database.RecordFeedFailureParams{
+		Error:       "bad Status Code from response",
+		At:          sql.NullTime{Time: now, Valid: true},
+		NextFetchAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+		MaxFailures: 1,
+		ID:          feed.ID,
+	}); err != nil {
+		t.Fatalf("RecordFeedFailure: %v", err)
+	}
+	out = env.mustRun(handlerFeeds, "--errors")
+	if !strings.Contains(out, "Blog (DISABLED)") || !strings.Contains(out, "Last error:  bad Status Code") {
+		t.Errorf("feeds --errors printed %q", out)
+	}
+	var failing []feedErrorOutput
+	if err := json.Unmarshal([]byte(env.mustRun(handlerFeeds, "--errors", "--output", "json")), &failing); err != nil {
+		t.Fatalf("decoding feeds --errors: %v", err)
+	}
+	if len(failing) != 1 || !failing[0].Disabled || failing[0].ConsecutiveFailures != 1 {
+		t.Errorf("feeds --errors = %+v", failing)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+
+	if out := env.mustRun(handlerFeed, "set-interval", feed.Url, "2h"); !strings.Contains(out, "fetched every 2h") {
+		t.Errorf("set-interval printed %q", out)
+	}
+	got, _ := env.s.db.GetFeed(env.ctx, feed.Url)
+	if !got.IntervalOverrideSeconds.Valid || got.IntervalOverrideSeconds.Int32 != 7200 {
+		t.Errorf("interval override = %v, want 7200", got.IntervalOverrideSeconds)
+	}
+	if out := env.mustRun(handlerFeed, "set-interval", feed.Url, "auto"); !strings.Contains(out, "automatic scheduling") {
+		t.Errorf("set-interval auto printed %q", out)
+	}
+	got, _ = env.s.db.GetFeed(env.ctx, feed.Url)
+	if got.IntervalOverrideSeconds.Valid {
+		t.Errorf("interval override = %v after auto", got.IntervalOverrideSeconds)
+	}
+
+	env.runErr(handlerFeed, "at least 1m", "set-interval", feed.Url, "30s")
+	env.runErr(handlerFeed, "error parsing time duration", "set-interval", feed.Url, "often")
+	env.runErr(handlerFeed, "does not exist", "set-interval", "https://example.com/other", "1h")
+	env.runErr(handlerFeed, "expects two arguments", "set-interval", feed.Url)
+
+	if out := env.mustRun(handlerFeed, "enable", feed.Url); out != "Feed "+feed.Url+" enabled\n" {
+		t.Errorf("enable printed %q", out)
+	}
+	env.runErr(handlerFeed, "does not exist", "enable", "https://example.com/other")
+	env.runErr(handlerFeed, "expects an argument", "enable")
+	env.runErr(handlerFeed, "unknown subcommand", "delete")
+	env.runErr(handlerFeed, "expects a subcommand")
+}
+
+func TestFollowUnfollowFollowing(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	feed := env.feed("Blog", srv.URL+"/rss.xml")
+	env.post(feed, "First", "", time.Now())
+
+	env.mustRun(handlerRegister, "bob")
+	bob := env.s.cfg.Current_user
+	follow := middlewareLoggedIn(handlerFollow)
+	following := middlewareLoggedIn(handlerFollowing)
+	unfollow := middlewareLoggedIn(handlerUnfollow)
+
+	// The site's homepage finds the feed that's already in the database
+	if out := env.mustRun(follow, srv.URL); out != "User bob is now following Blog\n" {
+		t.Errorf("follow printed %q", out)
+	}
+	env.runErr(follow, "error creating feed_follows entry", feed.Url)
+	env.runErr(follow, "no feed was found there", srv.URL+"/missing")
+	env.runErr(follow, "expect an argument")
+
+	out := env.mustRun(following)
+	if out != "User: "+bob+" is following:\n===============================\n  *  Blog (1 unread)\n" {
+		t.Errorf("following printed %q", out)
+	}
+	var follows []followOutput
+	if err := json.Unmarshal([]byte(env.mustRun(following, "--output", "json")), &follows); err != nil {
+		t.Fatalf("decoding following: %v", err)
+	}
+	if len(follows) != 1 || follows[0].FeedURL != feed.Url || follows[0].UnreadCount != 1 {
+		t.Errorf("following = %+v", follows)
+	}
+
+	env.mustRun(unfollow, feed.Url)
+	if out := env.mustRun(following, "--output", "json"); strings.TrimSpace(out) != "[]" {
+		t.Errorf("following after unfollow = %q", out)
+	}
+	env.runErr(unfollow, "error retrieving feed", "https://example.com/other")
+	env.runErr(unfollow, "expects an argument")
+}
+
+func TestFollowUnknownFeed(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	env.runErr(middlewareLoggedIn(handlerFollow), "add it with: gator addfeed NAME "+srv.URL+"/rss.xml", srv.URL)
+}
+
+func TestAggAndTest(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	feed := env.feed("Blog", srv.URL+"/rss.xml")
+
+	out := env.mustRun(handlerTest)
+	if !strings.Contains(out, "Fetched "+feed.Url+": 2 new, 0 updated") {
+		t.Errorf("test printed %q", out)
+	}
+	posts, _ := env.s.db.ListPostsForUser(env.ctx, database.ListPostsForUserParams{UserID: env.usr.ID, Limit: 10})
+	if len(posts) != 2 || posts[0].Title != "Error handling" || posts[1].Title != "Generics in Go" {
+		t.Errorf("posts after test = %+v", posts)
+	}
+
+	// The feed was just fetched so agg has nothing due, it should still run a cycle and stop with ctx
+	failing := env.feed("Missing", srv.URL+"/missing")
+	ctx, cancel := context.WithTimeout(env.ctx, 200*time.Millisecond)
+	defer cancel()
+	out, err := env.runContext(ctx, handlerAgg, "1m", "--workers", "2")
+	if err != nil {
+		t.Fatalf("agg: %v", err)
+	}
+	if !strings.Contains(out, "Collecting 10 feeds every 1m0s with 2 workers") || !strings.Contains(out, "agg stopped") {
+		t.Errorf("agg printed %q", out)
+	}
+	got, _ := env.s.db.GetFeed(env.ctx, failing.Url)
+	if got.ConsecutiveFailures != 1 || got.LastError == "" {
+		t.Errorf("failing feed after agg = %+v", got)
+	}
+
+	env.runErr(handlerAgg, "expects an argument")
+	env.runErr(handlerAgg, "greater then 1 second", "500ms")
+	env.runErr(handlerAgg, "error parsing time duration", "soon")
+	env.runErr(handlerAgg, "must be at least 1", "1m", "--workers", "0")
+	env.runErr(handlerAgg, "--max-failures must be at least 1", "1m", "--max-failures", "0")
+	env.runErr(handlerAgg, "--lease must be at least 1m", "1m", "--lease", "10s")
+}
+
+// Posts stored before guids were tracked have their url as guid, scraping their items again updates them.
+func TestScrapeLegacyPosts(t *testing.T) {
+	env := newTestEnv(t)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Blog</title>
+<item><title>Generics in Go</title><link>%[1]v/generics</link><guid isPermaLink="false">tag:blog,1</guid><description>Edited</description></item>
+<item><title>Error handling</title><link>%[1]v/errors</link></item>
+</channel></rss>`, srv.URL)
+	}))
+	t.Cleanup(srv.Close)
+	feed := env.feed("Blog", srv.URL+"/rss.xml")
+
+	legacy := map[uuid.UUID]bool{}
+	for _, path := range []string{"/generics", "/errors"} {
+		post, err := env.s.db.CreatePost(env.ctx, database.CreatePostParams{
+			ID:         uuid.New(),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+			Title:      path,
+			Url:        srv.URL + path,
+			FeedID:     feed.ID,
+			Categories: []string{},
+			Guid:       srv.URL + path,
+		})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		legacy[post.ID] = true
+	}
+
+	var summary scrapeSummary
+	var err error
+	captureStdout(t, func() { summary, err = scrapeFeed(env.ctx, env.s, feed) })
+	if err != nil {
+		t.Fatalf("scrapeFeed: %v", err)
+	}
+	if summary.added != 0 || summary.updated != 2 {
+		t.Errorf("scrape added %v and updated %v posts, want 0 and 2", summary.added, summary.updated)
+	}
+	posts, _ := env.s.db.ListPostsForUser(env.ctx, database.ListPostsForUserParams{UserID: env.usr.ID, Limit: 10})
+	if len(posts) != 2 {
+		t.Fatalf("%v posts after scraping, want the 2 legacy posts", len(posts))
+	}
+	for _, post := range posts {
+		if !legacy[post.ID] || post.Guid == post.Url {
+			t.Errorf("post after scraping = %+v", post)
+		}
+	}
+}
+
+func TestFetchFeedTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		if r.URL.Path == "/endless" {
+			for i := 0; i <= maxBodySize/1024; i++ {
+				if _, err := w.Write(bytes.Repeat([]byte(" "), 1024)); err != nil {
+					return
+				}
+			}
+			return
+		}
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Blog</title></channel></rss>`+strings.Repeat(" ", maxBodySize-100))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchFeed(context.Background(), srv.URL+"/endless"); err == nil || !strings.Contains(err.Error(), "larger than 8 MB") {
+		t.Errorf("fetchFeed of an oversized response = %v", err)
+	}
+	if feed, err := fetchFeed(context.Background(), srv.URL+"/"); err != nil || feed.Channel.Title != "Blog" {
+		t.Errorf("fetchFeed just under the limit = %v, %v", feed, err)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	limit := fs.Int("limit", 1, "")
+	args, err := parseFlags(fs, []string{"one", "--limit", "3", "two"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if *limit != 3 || len(args) != 2 || args[0] != "one" || args[1] != "two" {
+		t.Errorf("parseFlags = %v, limit %v", args, *limit)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/striderjg/gator/internal/migrate"
+)
+
+// schemaFS is the Postgres schema, the SQLite one is embedded in its package.
+//
+//go:embed sql/schema/*.sql
+var schemaFS embed.FS
+
+func newMigrator(s *state) (*migrate.Migrator, error) {
+	return migrate.New(s.conn, s.schema, ".")
+}
+
+func handlerMigrate(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return errors.New("migrate expects a subcommand.  Usage: migrate up|down|status|redo|baseline VERSION")
+	}
+	m, err := newMigrator(s)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, migration := range done {
+			fmt.Printf("Applied %v\n", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return nil
+	case "down":
+		migration, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %v\n", migration.Name)
+		return nil
+	case "redo":
+		migration, err := m.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back and reapplied %v\n", migration.Name)
+		return nil
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				fmt.Printf("  [x] %v (applied %v)\n", status.Name, status.AppliedAt.Format(time.RFC1123))
+			} else {
+				fmt.Printf("  [ ] %v (pending)\n", status.Name)
+			}
+		}
+		return nil
+	case "baseline":
+		if len(cmd.args) < 2 {
+			return errors.New("migrate baseline expects a version.  Usage: migrate baseline VERSION")
+		}
+		version, err := strconv.ParseInt(cmd.args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version: %w", err)
+		}
+		n, err := m.Baseline(ctx, version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Marked %v migrations applied\n", n)
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %v", cmd.args[0])
+	}
+}
+
+// checkSchema refuses to run commands against a database that's missing migrations, the queries would
+// fail in confusing ways otherwise.
+func checkSchema(ctx context.Context, s *state) error {
+	m, err := newMigrator(s)
+	if err != nil {
+		return err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking database schema: %w", err)
+	}
+	if len(pending) > 0 {
+		version, err := m.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("error checking database schema: %w", err)
+		}
+		return fmt.Errorf("database schema is out of date (version %v, %v migrations pending up to %v), run: gator migrate up", version, len(pending), m.Latest())
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/striderjg/gator/internal/config"
+	"github.com/striderjg/gator/internal/storage/memory"
+)
+
+func TestMigrate(t *testing.T) {
+	env := &testEnv{t: t, ctx: context.Background(), s: &state{db: memory.New(), cfg: &config.Config{}}}
+	env.s.cfg.Db_url = "sqlite://" + filepath.Join(t.TempDir(), "gator.db")
+	if err := openDatabase(env.s); err != nil {
+		t.Fatalf("openDatabase: %v", err)
+	}
+	t.Cleanup(func() { env.s.conn.Close() })
+
+	if err := checkSchema(env.ctx, env.s); err == nil || !strings.Contains(err.Error(), "run: gator migrate up") {
+		t.Errorf("checkSchema on an empty database = %v", err)
+	}
+	out := env.mustRun(handlerMigrate, "status")
+	if !strings.Contains(out, "[ ] ") || strings.Contains(out, "[x] ") {
+		t.Errorf("status before up printed %q", out)
+	}
+	out = env.mustRun(handlerMigrate, "up")
+	if !strings.HasPrefix(out, "Applied ") {
+		t.Errorf("up printed %q", out)
+	}
+	if err := checkSchema(env.ctx, env.s); err != nil {
+		t.Errorf("checkSchema after up: %v", err)
+	}
+	if out := env.mustRun(handlerMigrate, "up"); out != "Database is up to date\n" {
+		t.Errorf("second up printed %q", out)
+	}
+	if out := env.mustRun(handlerMigrate, "redo"); !strings.HasPrefix(out, "Rolled back and reapplied ") {
+		t.Errorf("redo printed %q", out)
+	}
+	if out := env.mustRun(handlerMigrate, "down"); !strings.HasPrefix(out, "Rolled back ") {
+		t.Errorf("down printed %q", out)
+	}
+	if out := env.mustRun(handlerMigrate, "status"); strings.Count(out, "[ ] ") != 1 {
+		t.Errorf("status after down printed %q", out)
+	}
+
+	m, err := newMigrator(env.s)
+	if err != nil {
+		t.Fatalf("newMigrator: %v", err)
+	}
+	if out := env.mustRun(handlerMigrate, "baseline", fmt.Sprint(m.Latest())); out != "Marked 1 migrations applied\n" {
+		t.Errorf("baseline printed %q", out)
+	}
+
+	env.runErr(handlerMigrate, "invalid version", "baseline", "latest")
+	env.runErr(handlerMigrate, "expects a version", "baseline")
+	env.runErr(handlerMigrate, "unknown subcommand", "sideways")
+	env.runErr(handlerMigrate, "expects a subcommand")
+}
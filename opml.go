@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+type opmlImportStats struct {
+	added    int
+	followed int
+	skipped  int
+	moved    int
+	failed   int
+}
+
+func handlerImport(ctx context.Context, s *state, cmd command, usr database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("import expects an argument.  Usage: import FILE.opml")
+	}
+	data, err := os.ReadFile(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error reading opml file: %w", err)
+	}
+	var doc OPML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error decoding opml file: %w", err)
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(ctx, usr.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving follows for user: %w", err)
+	}
+	// Feeds the user follows, with their category
+	following := make(map[uuid.UUID]string, len(follows))
+	for _, ff := range follows {
+		following[ff.FeedID] = ff.Category
+	}
+
+	var stats opmlImportStats
+	if err := importOutlines(ctx, s, usr, doc.Body.Outlines, nil, following, &stats); err != nil {
+		return err
+	}
+	fmt.Printf("Import finished: %v feeds added, %v existing feeds followed, %v already followed, %v moved to another category, %v failed\n",
+		stats.added, stats.followed, stats.skipped, stats.moved, stats.failed)
+	return nil
+}
+
+// importOutlines walks the outline tree.  Outlines without an xmlUrl are folders and become the
+// category of the feeds under them, nested folders are joined with "/".
+func importOutlines(ctx context.Context, s *state, usr database.User, outlines []OPMLOutline, folders []string, following map[uuid.UUID]string, stats *opmlImportStats) error {
+	for _, outline := range outlines {
+		name := strings.TrimSpace(outline.Text)
+		if name == "" {
+			name = strings.TrimSpace(outline.Title)
+		}
+
+		feedURL := strings.TrimSpace(outline.XMLURL)
+		if feedURL == "" {
+			if err := importOutlines(ctx, s, usr, outline.Outlines, append(folders, name), following, stats); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = feedURL
+		}
+		if err := importFeed(ctx, s, usr, name, feedURL, strings.Join(folders, "/"), following, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importFeed only returns an error for database failures.  Feeds that can't be fetched are reported and skipped.
+// Feeds already followed are moved to category so re-importing a reorganized file takes effect.
+func importFeed(ctx context.Context, s *state, usr database.User, name, feedURL, category string, following map[uuid.UUID]string, stats *opmlImportStats) error {
+	feed, err := s.db.GetFeed(ctx, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		feed, err = addFeed(ctx, s, usr, name, feedURL, category)
+		var existing *existingFeedError
+		switch {
+		case errors.As(err, &existing):
+			// feedURL is the site, or an old address, of a feed that's already in the database
+			feed = existing.feed
+		case err != nil:
+			stats.failed++
+			fmt.Printf("Skipped %v (%v): %v\n", name, feedURL, err)
+			return nil
+		default:
+			following[feed.ID] = category
+			stats.added++
+			fmt.Printf("Added: %v\n", name)
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("error retrieving feed %v: %w", feedURL, err)
+	}
+
+	if current, ok := following[feed.ID]; ok {
+		if current == category {
+			stats.skipped++
+			return nil
+		}
+		if _, err := s.db.SetFeedFollowCategory(ctx, database.SetFeedFollowCategoryParams{
+			UserID:    usr.ID,
+			FeedID:    feed.ID,
+			Category:  category,
+			UpdatedAt: time.Now(),
+		}); err != nil {
+			return fmt.Errorf("error updating category of %v: %w", feed.Url, err)
+		}
+		following[feed.ID] = category
+		stats.moved++
+		if category == "" {
+			fmt.Printf("Moved: %v out of %v\n", name, current)
+		} else {
+			fmt.Printf("Moved: %v to %v\n", name, category)
+		}
+		return nil
+	}
+
+	if _, err := s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    usr.ID,
+		FeedID:    feed.ID,
+		Category:  category,
+	}); err != nil {
+		return fmt.Errorf("error creating feed_follows entry for %v: %w", feed.Url, err)
+	}
+	following[feed.ID] = category
+	stats.followed++
+	fmt.Printf("Followed: %v\n", name)
+	return nil
+}
+
+func handlerExport(ctx context.Context, s *state, cmd command, usr database.User) error {
+	follows, err := s.db.GetFeedFollowsForUser(ctx, usr.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving follows for user: %w", err)
+	}
+
+	doc := OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       fmt.Sprintf("gator subscriptions for %v", usr.Name),
+			DateCreated: time.Now().Format(time.RFC1123Z),
+			OwnerName:   usr.Name,
+		},
+	}
+	for _, ff := range follows {
+		var folders []string
+		if ff.Category != "" {
+			folders = strings.Split(ff.Category, "/")
+		}
+		doc.Body.Outlines = insertOutline(doc.Body.Outlines, folders, OPMLOutline{
+			Text:   ff.FeedName,
+			Title:  ff.FeedName,
+			Type:   "rss",
+			XMLURL: ff.FeedUrl,
+		})
+	}
+
+	var out io.Writer = os.Stdout
+	if len(cmd.args) > 0 {
+		f, err := os.Create(cmd.args[0])
+		if err != nil {
+			return fmt.Errorf("error creating export file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding opml: %w", err)
+	}
+	if _, err := fmt.Fprintf(out, "%v%s\n", xml.Header, data); err != nil {
+		return fmt.Errorf("error writing opml: %w", err)
+	}
+	return nil
+}
+
+// insertOutline adds feed under the folder path, creating folder outlines as needed.
+func insertOutline(outlines []OPMLOutline, folders []string, feed OPMLOutline) []OPMLOutline {
+	if len(folders) == 0 {
+		return append(outlines, feed)
+	}
+	for i := range outlines {
+		if outlines[i].XMLURL == "" && outlines[i].Text == folders[0] {
+			outlines[i].Outlines = insertOutline(outlines[i].Outlines, folders[1:], feed)
+			return outlines
+		}
+	}
+	folder := OPMLOutline{Text: folders[0], Title: folders[0]}
+	folder.Outlines = insertOutline(nil, folders[1:], feed)
+	return append(outlines, folder)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func TestImportExport(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	followed := env.feed("Followed", "https://example.com/followed")
+	// In the database but not followed by alice
+	other, err := env.s.db.CreateFeed(env.ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      "Other",
+		Url:       "https://example.com/other",
+		UserID:    env.usr.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	opml := fmt.Sprintf(`<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline text="Test Blog" type="rss" xmlUrl="%v/rss.xml"/>
+      </outline>
+      <outline title="Other" xmlUrl="%v"/>
+    </outline>
+    <outline text="Followed" xmlUrl="%v"/>
+    <outline text="Broken" xmlUrl="%v/missing"/>
+  </body>
+</opml>`, srv.URL, other.Url, followed.Url, srv.URL)
+	if err := os.WriteFile(path, []byte(opml), 0644); err != nil {
+		t.Fatalf("writing opml: %v", err)
+	}
+
+	out := env.mustRun(middlewareLoggedIn(handlerImport), path)
+	if !strings.Contains(out, "Import finished: 1 feeds added, 1 existing feeds followed, 1 already followed, 0 moved to another category, 1 failed") {
+		t.Errorf("import printed %q", out)
+	}
+	follows, _ := env.s.db.GetFeedFollowsForUser(env.ctx, env.usr.ID)
+	categories := map[string]string{}
+	for _, ff := range follows {
+		categories[ff.FeedUrl] = ff.Category
+	}
+	if len(categories) != 3 || categories[srv.URL+"/rss.xml"] != "Tech/Go" || categories[other.Url] != "Tech" || categories[followed.Url] != "" {
+		t.Errorf("categories after import = %v", categories)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.opml")
+	env.mustRun(middlewareLoggedIn(handlerExport), exportPath)
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	var doc OPML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	if doc.Head.OwnerName != "alice" {
+		t.Errorf("export owner = %q", doc.Head.OwnerName)
+	}
+	var walk func(outlines []OPMLOutline, folders []string)
+	exported := map[string]string{}
+	walk = func(outlines []OPMLOutline, folders []string) {
+		for _, outline := range outlines {
+			if outline.XMLURL == "" {
+				walk(outline.Outlines, append(folders, outline.Text))
+				continue
+			}
+			exported[outline.XMLURL] = strings.Join(folders, "/")
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+	if fmt.Sprint(exported) != fmt.Sprint(categories) {
+		t.Errorf("exported %v, want %v", exported, categories)
+	}
+
+	// Importing the export again changes nothing
+	if out := env.mustRun(middlewareLoggedIn(handlerImport), exportPath); !strings.Contains(out, "0 feeds added, 0 existing feeds followed, 3 already followed, 0 moved to another category, 0 failed") {
+		t.Errorf("re-import printed %q", out)
+	}
+
+	// A reorganized file moves the feeds already followed
+	reorganized := filepath.Join(t.TempDir(), "reorganized.opml")
+	opml = fmt.Sprintf(`<opml version="2.0"><body>
+  <outline text="Reading"><outline text="Test Blog" xmlUrl="%v/rss.xml"/></outline>
+  <outline text="Other" xmlUrl="%v"/>
+  <outline text="News"><outline text="Followed" xmlUrl="%v"/></outline>
+</body></opml>`, srv.URL, other.Url, followed.Url)
+	if err := os.WriteFile(reorganized, []byte(opml), 0644); err != nil {
+		t.Fatalf("writing opml: %v", err)
+	}
+	out = env.mustRun(middlewareLoggedIn(handlerImport), reorganized)
+	if !strings.Contains(out, "0 feeds added, 0 existing feeds followed, 0 already followed, 3 moved to another category, 0 failed") ||
+		!strings.Contains(out, "Moved: Test Blog to Reading") || !strings.Contains(out, "Moved: Other out of Tech") {
+		t.Errorf("importing a reorganized file printed %q", out)
+	}
+	follows, _ = env.s.db.GetFeedFollowsForUser(env.ctx, env.usr.ID)
+	for _, ff := range follows {
+		categories[ff.FeedUrl] = ff.Category
+	}
+	if len(follows) != 3 || categories[srv.URL+"/rss.xml"] != "Reading" || categories[other.Url] != "" || categories[followed.Url] != "News" {
+		t.Errorf("categories after importing a reorganized file = %v", categories)
+	}
+
+	if out := env.mustRun(middlewareLoggedIn(handlerExport)); !strings.Contains(out, `<opml version="2.0">`) {
+		t.Errorf("export to stdout printed %q", out)
+	}
+
+	env.runErr(middlewareLoggedIn(handlerImport), "expects an argument")
+	env.runErr(middlewareLoggedIn(handlerImport), "error reading opml file", filepath.Join(t.TempDir(), "missing.opml"))
+	notOPML := filepath.Join(t.TempDir(), "feeds.txt")
+	os.WriteFile(notOPML, []byte("not xml"), 0644)
+	env.runErr(middlewareLoggedIn(handlerImport), "error decoding opml file", notOPML)
+	env.runErr(middlewareLoggedIn(handlerExport), "error creating export file", filepath.Join(t.TempDir(), "missing", "export.opml"))
+}
+
+// An outline pointing at the site of a feed that's already in the database follows that feed.
+func TestImportDiscoveredFeed(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	if _, err := env.s.db.CreateFeed(env.ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      "Test Blog",
+		Url:       srv.URL + "/rss.xml",
+		UserID:    env.usr.ID,
+	}); err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	opml := fmt.Sprintf(`<opml version="2.0"><body><outline text="Tech"><outline text="Test Blog" xmlUrl="%v/"/></outline></body></opml>`, srv.URL)
+	if err := os.WriteFile(path, []byte(opml), 0644); err != nil {
+		t.Fatalf("writing opml: %v", err)
+	}
+	out := env.mustRun(middlewareLoggedIn(handlerImport), path)
+	if !strings.Contains(out, "0 feeds added, 1 existing feeds followed, 0 already followed, 0 moved to another category, 0 failed") {
+		t.Errorf("import printed %q", out)
+	}
+	follows, _ := env.s.db.GetFeedFollowsForUser(env.ctx, env.usr.ID)
+	if len(follows) != 1 || follows[0].FeedUrl != srv.URL+"/rss.xml" || follows[0].Category != "Tech" {
+		t.Errorf("follows after import = %+v", follows)
+	}
+
+	// Already followed under the site's address too
+	if out := env.mustRun(middlewareLoggedIn(handlerImport), path); !strings.Contains(out, "1 already followed") {
+		t.Errorf("re-import printed %q", out)
+	}
+}
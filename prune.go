@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/striderjg/gator/internal/config"
+	"github.com/striderjg/gator/internal/database"
+)
+
+// retentionPolicy decides which of a feed's posts prune deletes.  A zero maxAge or maxPosts means no limit.
+// Starred posts are always kept, deliberately not configurable since deleting them would lose the stars and notes.
+type retentionPolicy struct {
+	maxAge     time.Duration
+	maxPosts   int
+	keepUnread bool
+}
+
+func (p retentionPolicy) limited() bool {
+	return p.maxAge > 0 || p.maxPosts > 0
+}
+
+func (p retentionPolicy) String() string {
+	if !p.limited() {
+		return "keep forever"
+	}
+	var parts []string
+	if p.maxAge > 0 {
+		parts = append(parts, "max age "+formatRetentionAge(p.maxAge))
+	}
+	if p.maxPosts > 0 {
+		parts = append(parts, fmt.Sprintf("max %v posts", p.maxPosts))
+	}
+	if p.keepUnread {
+		parts = append(parts, "keep unread")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// globalRetention is the policy from the config file, used for every field a feed doesn't override.
+func globalRetention(cfg *config.Config) (retentionPolicy, error) {
+	var policy retentionPolicy
+	if cfg.Retention == nil {
+		return policy, nil
+	}
+	maxAge, err := parseRetentionAge(cfg.Retention.Max_age)
+	if err != nil {
+		return retentionPolicy{}, fmt.Errorf("error in the retention max_age config: %w", err)
+	}
+	if cfg.Retention.Max_posts < 0 {
+		return retentionPolicy{}, errors.New("the retention max_posts config can't be negative")
+	}
+	policy.maxAge = maxAge
+	policy.maxPosts = cfg.Retention.Max_posts
+	policy.keepUnread = cfg.Retention.Keep_unread
+	return policy, nil
+}
+
+// feedRetention applies a feed's overrides, NULL columns fall back on global.
+func feedRetention(global retentionPolicy, maxAgeSeconds, maxPosts sql.NullInt32, keepUnread sql.NullBool) retentionPolicy {
+	policy := global
+	if maxAgeSeconds.Valid {
+		policy.maxAge = time.Duration(maxAgeSeconds.Int32) * time.Second
+	}
+	if maxPosts.Valid {
+		policy.maxPosts = int(maxPosts.Int32)
+	}
+	if keepUnread.Valid {
+		policy.keepUnread = keepUnread.Bool
+	}
+	return policy
+}
+
+// parseRetentionAge accepts Nd, Nw or a Go duration.  Empty, 0 and forever mean no limit.
+func parseRetentionAge(value string) (time.Duration, error) {
+	switch value {
+	case "", "0", "forever":
+		return 0, nil
+	}
+	var age time.Duration
+	if unit := value[len(value)-1]; unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q, expected e.g. 30d, 8w or 720h", value)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+		if unit == 'w' {
+			age *= 7
+		}
+	} else {
+		var err error
+		if age, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid age %q, expected e.g. 30d, 8w or 720h", value)
+		}
+	}
+	if age < time.Hour {
+		return 0, errors.New("max age must be at least 1h")
+	}
+	// Stored as seconds in an INTEGER column
+	if age.Seconds() > float64(1<<31-1) {
+		return 0, fmt.Errorf("max age %v is too long", value)
+	}
+	return age, nil
+}
+
+func formatRetentionAge(age time.Duration) string {
+	if age%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%vd", int(age/(24*time.Hour)))
+	}
+	return age.String()
+}
+
+// feedPrune is what prune did, or would do, to one feed.
+type feedPrune struct {
+	name   string
+	url    string
+	policy retentionPolicy
+	posts  int64
+}
+
+type pruneSummary struct {
+	feeds []feedPrune
+	posts int64
+}
+
+// prunePosts applies every feed's retention policy.  With dryRun nothing is deleted and the counts are
+// what would have been.
+func prunePosts(ctx context.Context, s *state, dryRun bool) (pruneSummary, error) {
+	global, err := globalRetention(s.cfg)
+	if err != nil {
+		return pruneSummary{}, err
+	}
+	feeds, err := s.db.GetFeedRetentions(ctx)
+	if err != nil {
+		return pruneSummary{}, fmt.Errorf("error retrieving feed retention: %w", err)
+	}
+
+	// One cutoff for the whole pass so every feed is pruned to the same moment
+	now := time.Now()
+	var summary pruneSummary
+	for _, feed := range feeds {
+		policy := feedRetention(global, feed.RetentionMaxAgeSeconds, feed.RetentionMaxPosts, feed.RetentionKeepUnread)
+		if !policy.limited() {
+			continue
+		}
+		arg := database.PrunePostsParams{
+			FeedID:     feed.ID,
+			KeepUnread: policy.keepUnread,
+		}
+		if policy.maxAge > 0 {
+			arg.Before = sql.NullTime{Time: now.Add(-policy.maxAge), Valid: true}
+		}
+		if policy.maxPosts > 0 {
+			arg.MaxPosts = sql.NullInt32{Int32: int32(policy.maxPosts), Valid: true}
+		}
+
+		var n int64
+		if dryRun {
+			n, err = s.db.CountPrunablePosts(ctx, database.CountPrunablePostsParams(arg))
+		} else {
+			n, err = s.db.PrunePosts(ctx, arg)
+		}
+		if err != nil {
+			return summary, fmt.Errorf("error pruning feed %v: %w", feed.Url, err)
+		}
+		if n > 0 {
+			summary.feeds = append(summary.feeds, feedPrune{name: feed.Name, url: feed.Url, policy: policy, posts: n})
+			summary.posts += n
+		}
+	}
+	return summary, nil
+}
+
+func handlerPrune(ctx context.Context, s *state, cmd command) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be deleted without deleting it")
+	if _, err := parseFlags(fs, cmd.args); err != nil {
+		return err
+	}
+
+	summary, err := prunePosts(ctx, s, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "pruned"
+	if *dryRun {
+		verb = "would be pruned"
+	}
+	for _, feed := range summary.feeds {
+		fmt.Printf("%v: %v posts %v (%v)\n", feed.name, feed.posts, verb, feed.policy)
+	}
+	fmt.Printf("%v posts %v from %v feeds\n", summary.posts, verb, len(summary.feeds))
+	return nil
+}
+
+// handleFeedSetRetention is feed set-retention URL [--max-age AGE] [--max-posts N] [--keep-unread BOOL].
+// Only the flags given change, default clears the feed's override.
+func handleFeedSetRetention(ctx context.Context, s *state, args []string) error {
+	const usage = "Usage: feed set-retention URL [--max-age AGE|forever|default] [--max-posts N|default] [--keep-unread true|false|default]"
+	fs := flag.NewFlagSet("feed set-retention", flag.ContinueOnError)
+	fs.String("max-age", "", "delete posts older than AGE, e.g. 30d, 8w or 720h")
+	fs.String("max-posts", "", "keep only the newest N posts, 0 for no limit")
+	fs.String("keep-unread", "", "keep posts a follower hasn't read")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("feed set-retention expects a feed url.  " + usage)
+	}
+	if fs.NFlag() == 0 {
+		return errors.New("feed set-retention expects at least one setting.  " + usage)
+	}
+
+	feed, err := s.db.GetFeed(ctx, args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed %v does not exist", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving feed: %w", err)
+	}
+	arg := database.SetFeedRetentionParams{
+		Url:                    feed.Url,
+		RetentionMaxAgeSeconds: feed.RetentionMaxAgeSeconds,
+		RetentionMaxPosts:      feed.RetentionMaxPosts,
+		RetentionKeepUnread:    feed.RetentionKeepUnread,
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		value := f.Value.String()
+		switch f.Name {
+		case "max-age":
+			arg.RetentionMaxAgeSeconds, err = parseRetentionSetting(value, func(value string) (int32, error) {
+				age, err := parseRetentionAge(value)
+				return int32(age.Seconds()), err
+			})
+		case "max-posts":
+			arg.RetentionMaxPosts, err = parseRetentionSetting(value, func(value string) (int32, error) {
+				n, err := strconv.ParseInt(value, 10, 32)
+				if err != nil || n < 0 {
+					return 0, fmt.Errorf("--max-posts expects a number of posts or default, got %q", value)
+				}
+				return int32(n), nil
+			})
+		case "keep-unread":
+			arg.RetentionKeepUnread, err = parseRetentionBool(f.Name, value)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.SetFeedRetention(ctx, arg); err != nil {
+		return fmt.Errorf("error setting feed retention: %w", err)
+	}
+	global, err := globalRetention(s.cfg)
+	if err != nil {
+		return err
+	}
+	policy := feedRetention(global, arg.RetentionMaxAgeSeconds, arg.RetentionMaxPosts, arg.RetentionKeepUnread)
+	fmt.Printf("Feed %v retention: %v\n", feed.Url, policy)
+	return nil
+}
+
+// parseRetentionSetting turns default into NULL so the feed follows the config file again.
+func parseRetentionSetting(value string, parse func(string) (int32, error)) (sql.NullInt32, error) {
+	if value == "default" {
+		return sql.NullInt32{}, nil
+	}
+	n, err := parse(value)
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	return sql.NullInt32{Int32: n, Valid: true}, nil
+}
+
+func parseRetentionBool(name, value string) (sql.NullBool, error) {
+	if value == "default" {
+		return sql.NullBool{}, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return sql.NullBool{}, fmt.Errorf("--%v expects true, false or default, got %q", name, value)
+	}
+	return sql.NullBool{Bool: b, Valid: true}, nil
+}
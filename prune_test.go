@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/striderjg/gator/internal/config"
+	"github.com/striderjg/gator/internal/database"
+)
+
+func TestParseRetentionAge(t *testing.T) {
+	cases := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"forever", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"30m", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+		{"-1d", 0, true},
+		{"100000w", 0, true},
+	}
+	for _, c := range cases {
+		got, err := parseRetentionAge(c.in)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("parseRetentionAge(%q) = %v, %v, want %v (error %v)", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	env := newTestEnv(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	news := env.feed("News", "https://example.com/news")
+	now := time.Now()
+	ancient := env.post(blog, "Ancient", "", now.Add(-60*24*time.Hour))
+	env.post(blog, "Old", "", now.Add(-40*24*time.Hour))
+	env.post(blog, "New", "", now.Add(-24*time.Hour))
+	env.post(news, "Yesterday", "", now.Add(-48*time.Hour))
+	env.post(news, "Today", "", now.Add(-time.Hour))
+	env.mustRun(middlewareLoggedIn(handlerStar), ancient.ID.String())
+
+	countPosts := func() int {
+		t.Helper()
+		posts, err := env.s.db.ListPostsForUser(env.ctx, database.ListPostsForUserParams{UserID: env.usr.ID, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListPostsForUser: %v", err)
+		}
+		return len(posts)
+	}
+
+	// Without a retention config posts are kept forever
+	if out := env.mustRun(handlerPrune); out != "0 posts pruned from 0 feeds\n" {
+		t.Errorf("prune without retention printed %q", out)
+	}
+
+	env.s.cfg.Retention = &config.Retention{Max_age: "30d"}
+	env.mustRun(handlerFeed, "set-retention", news.Url, "--max-posts", "1")
+
+	want := "Blog: 1 posts would be pruned (max age 30d)\n" +
+		"News: 1 posts would be pruned (max age 30d, max 1 posts)\n" +
+		"2 posts would be pruned from 2 feeds\n"
+	if out := env.mustRun(handlerPrune, "--dry-run"); out != want {
+		t.Errorf("prune --dry-run printed %q, want %q", out, want)
+	}
+	if n := countPosts(); n != 5 {
+		t.Errorf("%v posts after a dry run, want 5", n)
+	}
+
+	want = "Blog: 1 posts pruned (max age 30d)\n" +
+		"News: 1 posts pruned (max age 30d, max 1 posts)\n" +
+		"2 posts pruned from 2 feeds\n"
+	if out := env.mustRun(handlerPrune); out != want {
+		t.Errorf("prune printed %q, want %q", out, want)
+	}
+	if n := countPosts(); n != 3 {
+		t.Errorf("%v posts after prune, want 3", n)
+	}
+	if out := env.mustRun(handlerPrune); out != "0 posts pruned from 0 feeds\n" {
+		t.Errorf("second prune printed %q", out)
+	}
+
+	// Starred posts are never pruned, a keep_starred left in the config is ignored
+	var cfg config.Config
+	if err := json.Unmarshal([]byte(`{"retention": {"max_age": "30d", "keep_starred": false}}`), &cfg); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	env.s.cfg.Retention = cfg.Retention
+	if out := env.mustRun(handlerPrune); out != "0 posts pruned from 0 feeds\n" {
+		t.Errorf("prune with keep_starred false printed %q", out)
+	}
+	if _, err := env.s.db.GetPostForUser(env.ctx, database.GetPostForUserParams{ID: ancient.ID, UserID: env.usr.ID}); err != nil {
+		t.Errorf("starred post was pruned: %v", err)
+	}
+	env.runErr(handlerFeed, "keep-starred", "set-retention", blog.Url, "--keep-starred", "false")
+
+	env.s.cfg.Retention.Max_age = "soon"
+	env.runErr(handlerPrune, "retention max_age")
+	env.runErr(handlerAgg, "retention max_age", "--prune", "1m")
+}
+
+// Pruned posts stay gone while their items are still in the feed.
+func TestPruneThenScrape(t *testing.T) {
+	env := newTestEnv(t)
+	srv := newFeedServer(t)
+	feed := env.feed("Blog", srv.URL+"/rss.xml")
+	scrape := func() scrapeSummary {
+		t.Helper()
+		var summary scrapeSummary
+		var err error
+		captureStdout(t, func() { summary, err = scrapeFeed(env.ctx, env.s, feed) })
+		if err != nil {
+			t.Fatalf("scrapeFeed: %v", err)
+		}
+		return summary
+	}
+
+	if summary := scrape(); summary.added != 2 {
+		t.Fatalf("first scrape added %v posts, want 2", summary.added)
+	}
+	env.s.cfg.Retention = &config.Retention{Max_age: "30d"}
+	if out := env.mustRun(handlerPrune); out != "Blog: 2 posts pruned (max age 30d)\n2 posts pruned from 1 feeds\n" {
+		t.Errorf("prune printed %q", out)
+	}
+
+	if summary := scrape(); summary.added != 0 || summary.updated != 0 {
+		t.Errorf("scrape after prune added %v and updated %v posts, want none", summary.added, summary.updated)
+	}
+	posts, err := env.s.db.ListPostsForUser(env.ctx, database.ListPostsForUserParams{UserID: env.usr.ID, Limit: 10})
+	if err != nil || len(posts) != 0 {
+		t.Errorf("posts after prune and scrape = %+v, %v, want none", posts, err)
+	}
+}
+
+func TestFeedSetRetention(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+
+	if out := env.mustRun(handlerFeed, "set-retention", feed.Url, "--max-age", "2w"); out != "Feed "+feed.Url+" retention: max age 14d\n" {
+		t.Errorf("set-retention printed %q", out)
+	}
+	got, _ := env.s.db.GetFeed(env.ctx, feed.Url)
+	if got.RetentionMaxAgeSeconds.Int32 != 14*24*3600 || got.RetentionMaxPosts.Valid {
+		t.Errorf("retention after --max-age = %v, %v", got.RetentionMaxAgeSeconds, got.RetentionMaxPosts)
+	}
+
+	// Flags not given are left alone
+	out := env.mustRun(handlerFeed, "set-retention", feed.Url, "--max-posts", "10", "--keep-unread", "true")
+	if out != "Feed "+feed.Url+" retention: max age 14d, max 10 posts, keep unread\n" {
+		t.Errorf("set-retention printed %q", out)
+	}
+	got, _ = env.s.db.GetFeed(env.ctx, feed.Url)
+	if got.RetentionMaxAgeSeconds.Int32 != 14*24*3600 || got.RetentionMaxPosts.Int32 != 10 ||
+		!got.RetentionKeepUnread.Bool {
+		t.Errorf("retention = %+v", got)
+	}
+
+	// default goes back to the config file, forever overrides it
+	env.s.cfg.Retention = &config.Retention{Max_age: "90d"}
+	out = env.mustRun(handlerFeed, "set-retention", feed.Url, "--max-age", "default", "--max-posts", "default",
+		"--keep-unread", "default")
+	if out != "Feed "+feed.Url+" retention: max age 90d\n" {
+		t.Errorf("set-retention default printed %q", out)
+	}
+	got, _ = env.s.db.GetFeed(env.ctx, feed.Url)
+	if got.RetentionMaxAgeSeconds.Valid || got.RetentionMaxPosts.Valid || got.RetentionKeepUnread.Valid {
+		t.Errorf("retention after default = %+v", got)
+	}
+	if out := env.mustRun(handlerFeed, "set-retention", feed.Url, "--max-age", "forever"); out != "Feed "+feed.Url+" retention: keep forever\n" {
+		t.Errorf("set-retention forever printed %q", out)
+	}
+
+	env.runErr(handlerFeed, "expects a feed url", "set-retention")
+	env.runErr(handlerFeed, "at least one setting", "set-retention", feed.Url)
+	env.runErr(handlerFeed, "does not exist", "set-retention", "https://example.com/other", "--max-posts", "5")
+	env.runErr(handlerFeed, "at least 1h", "set-retention", feed.Url, "--max-age", "30m")
+	env.runErr(handlerFeed, "--max-posts expects", "set-retention", feed.Url, "--max-posts", "-1")
+	env.runErr(handlerFeed, "--keep-unread expects", "set-retention", feed.Url, "--keep-unread", "maybe")
+}
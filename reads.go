@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/dateparse"
+	"github.com/striderjg/gator/internal/sanitize"
+)
+
+// handlerRead prints a post with its markup stripped and marks it read.
+func handlerRead(ctx context.Context, s *state, cmd command, usr database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("read expects an argument.  Usage: read POST_ID")
+	}
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+
+	post, err := s.db.GetPostForUser(ctx, database.GetPostForUserParams{ID: id, UserID: usr.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no post %v in the feeds you follow", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving post: %w", err)
+	}
+
+	fmt.Println(post.Title)
+	fmt.Println(post.Url)
+	fmt.Println("Feed: ", post.FeedName)
+	if post.Author != "" {
+		fmt.Println("Author: ", post.Author)
+	}
+	if post.PublishedAt.Valid {
+		fmt.Println("Published: ", post.PublishedAt.Time.Format(time.RFC1123))
+	}
+	if len(post.Categories) > 0 {
+		fmt.Println("Categories: ", strings.Join(post.Categories, ", "))
+	}
+	fmt.Println("====================================================")
+	fmt.Println(sanitize.Text(post.Description))
+
+	if err := s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+		UserID: usr.ID,
+		PostID: post.ID,
+		ReadAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("error marking post read: %w", err)
+	}
+	return nil
+}
+
+func handlerMarkRead(ctx context.Context, s *state, cmd command, usr database.User) error {
+	fs := flag.NewFlagSet("mark-read", flag.ContinueOnError)
+	feedURL := fs.String("feed", "", "only mark posts from the feed at this url")
+	all := fs.Bool("all", false, "mark posts from every followed feed")
+	before := fs.String("before", "", "only mark posts published before this date")
+	if _, err := parseFlags(fs, cmd.args); err != nil {
+		return err
+	}
+	if *feedURL == "" && !*all && *before == "" {
+		return errors.New("mark-read needs --feed URL, --all or --before DATE.  Usage: mark-read --feed URL|--all [--before DATE]")
+	}
+	if *feedURL != "" && *all {
+		return errors.New("--feed and --all can't be used together")
+	}
+
+	params := database.MarkPostsReadParams{
+		ReadAt: time.Now(),
+		UserID: usr.ID,
+	}
+	if *feedURL != "" {
+		feed, err := s.db.GetFeed(ctx, *feedURL)
+		if err != nil {
+			return fmt.Errorf("error retrieving feed: %w", err)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if *before != "" {
+		t, err := dateparse.Parse(*before)
+		if err != nil {
+			return fmt.Errorf("error parsing --before: %w", err)
+		}
+		params.Before = sql.NullTime{Time: t, Valid: true}
+	}
+
+	n, err := s.db.MarkPostsRead(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error marking posts read: %w", err)
+	}
+	fmt.Printf("Marked %v posts read\n", n)
+	return nil
+}
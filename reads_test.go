@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func TestRead(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	post := env.post(feed, "Generics in Go", "<p>Type <b>parameters</b></p>", time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC))
+	read := middlewareLoggedIn(handlerRead)
+
+	out := env.mustRun(read, post.ID.String())
+	for _, want := range []string{"Generics in Go\n", "Feed:  Blog\n", "Published:  Mon, 04 Mar 2024 10:00:00 UTC\n", "Type parameters"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("read printed %q, missing %q", out, want)
+		}
+	}
+	if strings.Contains(out, "<b>") {
+		t.Errorf("read printed markup: %q", out)
+	}
+	got, _ := env.s.db.GetPostForUser(env.ctx, database.GetPostForUserParams{ID: post.ID, UserID: env.usr.ID})
+	if !got.Read {
+		t.Error("post isn't marked read after read")
+	}
+
+	env.runErr(read, "no post", uuid.NewString())
+	env.runErr(read, "invalid post id", "42")
+	env.runErr(read, "expects an argument")
+
+	// Posts from feeds the user doesn't follow can't be read
+	env.mustRun(handlerRegister, "bob")
+	env.runErr(read, "no post", post.ID.String())
+}
+
+func TestMarkRead(t *testing.T) {
+	env := newTestEnv(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	news := env.feed("News", "https://example.com/news")
+	env.post(blog, "Old", "", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	env.post(blog, "New", "", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	env.post(news, "Headline", "", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	markRead := middlewareLoggedIn(handlerMarkRead)
+
+	if out := env.mustRun(markRead, "--feed", blog.Url, "--before", "2024-03-01"); out != "Marked 1 posts read\n" {
+		t.Errorf("mark-read --feed --before printed %q", out)
+	}
+	if out := env.mustRun(markRead, "--before", "2024-03-01"); out != "Marked 1 posts read\n" {
+		t.Errorf("mark-read --before printed %q", out)
+	}
+	if out := env.mustRun(markRead, "--all"); out != "Marked 1 posts read\n" {
+		t.Errorf("mark-read --all printed %q", out)
+	}
+	if out := env.mustRun(markRead, "--all"); out != "Marked 0 posts read\n" {
+		t.Errorf("mark-read --all again printed %q", out)
+	}
+
+	env.runErr(markRead, "needs --feed URL, --all or --before")
+	env.runErr(markRead, "can't be used together", "--feed", blog.Url, "--all")
+	env.runErr(markRead, "error retrieving feed", "--feed", "https://example.com/other")
+	env.runErr(markRead, "error parsing --before", "--all", "--before", "someday")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+// Bounds for the automatically computed per-feed fetch interval.
+const (
+	defaultFetchInterval = time.Hour
+	minFetchInterval     = 15 * time.Minute
+	maxFetchInterval     = 24 * time.Hour
+	// A publisher asking for less than weekly polling via ttl or sy:updatePeriod is still polled weekly.
+	maxHintedInterval = 7 * 24 * time.Hour
+)
+
+var syUpdatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+var skipDayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+type scheduleHints struct {
+	interval  time.Duration
+	skipHours []int32
+	skipDays  []string
+}
+
+// feedScheduleHints pulls <ttl>, <skipHours>, <skipDays> and sy:updatePeriod/sy:updateFrequency out of a feed.
+// When both ttl and sy:* are present the longer of the two wins.
+func feedScheduleHints(feed *RSSFeed) scheduleHints {
+	hints := scheduleHints{
+		skipHours: []int32{},
+		skipDays:  []string{},
+	}
+
+	if ttl, err := strconv.Atoi(strings.TrimSpace(feed.Channel.TTL)); err == nil && ttl > 0 {
+		hints.interval = time.Duration(ttl) * time.Minute
+	}
+	if period, ok := syUpdatePeriods[strings.ToLower(strings.TrimSpace(feed.Channel.UpdatePeriod))]; ok {
+		frequency, err := strconv.Atoi(strings.TrimSpace(feed.Channel.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		hints.interval = max(hints.interval, period/time.Duration(frequency))
+	}
+
+	for _, hour := range feed.Channel.SkipHours {
+		h, err := strconv.Atoi(strings.TrimSpace(hour))
+		// Spec says 0-23 but 24 shows up in the wild for midnight
+		if err != nil || h < 0 || h > 24 {
+			continue
+		}
+		h %= 24
+		if !slices.Contains(hints.skipHours, int32(h)) {
+			hints.skipHours = append(hints.skipHours, int32(h))
+		}
+	}
+	for _, day := range feed.Channel.SkipDays {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if _, ok := skipDayNames[day]; ok && !slices.Contains(hints.skipDays, day) {
+			hints.skipDays = append(hints.skipDays, day)
+		}
+	}
+	return hints
+}
+
+// nextFetchInterval combines the publisher hints with the observed gap between posts.
+// A manual override beats everything else.
+func nextFetchInterval(feed database.Feed, cadence time.Duration) time.Duration {
+	if feed.IntervalOverrideSeconds.Valid && feed.IntervalOverrideSeconds.Int32 > 0 {
+		return time.Duration(feed.IntervalOverrideSeconds.Int32) * time.Second
+	}
+
+	interval := defaultFetchInterval
+	if cadence > 0 {
+		// Poll about twice per expected post
+		interval = min(max(cadence/2, minFetchInterval), maxFetchInterval)
+	}
+	hinted := time.Duration(feed.HintedIntervalSeconds) * time.Second
+	return max(interval, min(hinted, maxHintedInterval))
+}
+
+// nextFetchTime pushes from+interval past any skipHours/skipDays, which are in GMT per the RSS spec.
+func nextFetchTime(from time.Time, interval time.Duration, skipHours []int32, skipDays []string) time.Time {
+	next := from.Add(interval)
+	// A week of hourly steps covers every combination, anything more means the feed skips every hour
+	for range 7 * 24 {
+		utc := next.UTC()
+		if !slices.Contains(skipHours, int32(utc.Hour())) && !slices.Contains(skipDays, strings.ToLower(utc.Weekday().String())) {
+			return next
+		}
+		next = utc.Truncate(time.Hour).Add(time.Hour)
+	}
+	return from.Add(interval)
+}
+
+// scheduleNextFetch records a successful fetch and works out when the feed is due again.
+func scheduleNextFetch(ctx context.Context, s *state, feed database.Feed) (time.Time, error) {
+	cadenceSeconds, err := s.db.GetFeedPostCadence(ctx, feed.ID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting post cadence for feed (%v): %w", feed.Url, err)
+	}
+	cadence := time.Duration(cadenceSeconds * float64(time.Second))
+
+	next := nextFetchTime(time.Now(), nextFetchInterval(feed, cadence), feed.SkipHours, feed.SkipDays)
+	if err := s.db.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+		ID:          feed.ID,
+		NextFetchAt: sql.NullTime{Time: next, Valid: true},
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("error recording fetch of feed (%v): %w", feed.Url, err)
+	}
+	return next, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func TestFeedScheduleHints(t *testing.T) {
+	cases := []struct {
+		name      string
+		ttl       string
+		period    string
+		frequency string
+		hours     []string
+		days      []string
+		want      scheduleHints
+	}{
+		{name: "no hints", want: scheduleHints{}},
+		{name: "ttl", ttl: "60", want: scheduleHints{interval: time.Hour}},
+		{name: "bad ttl", ttl: "soon", want: scheduleHints{}},
+		{name: "negative ttl", ttl: "-5", want: scheduleHints{}},
+		{name: "hourly twice", period: "hourly", frequency: "2", want: scheduleHints{interval: 30 * time.Minute}},
+		{name: "daily without frequency", period: " Daily ", want: scheduleHints{interval: 24 * time.Hour}},
+		{name: "weekly zero frequency", period: "weekly", frequency: "0", want: scheduleHints{interval: 7 * 24 * time.Hour}},
+		{name: "unknown period", period: "fortnightly", want: scheduleHints{}},
+		{name: "ttl longer than period", ttl: "120", period: "hourly", want: scheduleHints{interval: 2 * time.Hour}},
+		{name: "period longer than ttl", ttl: "30", period: "daily", want: scheduleHints{interval: 24 * time.Hour}},
+		{
+			name:  "skip hours",
+			hours: []string{"0", " 23 ", "24", "25", "x", "1", "23"},
+			want:  scheduleHints{skipHours: []int32{0, 23, 1}},
+		},
+		{
+			name: "skip days",
+			days: []string{"Saturday", " sunday", "Funday", "SATURDAY"},
+			want: scheduleHints{skipDays: []string{"saturday", "sunday"}},
+		},
+	}
+	for _, c := range cases {
+		var feed RSSFeed
+		feed.Channel.TTL = c.ttl
+		feed.Channel.UpdatePeriod = c.period
+		feed.Channel.UpdateFrequency = c.frequency
+		feed.Channel.SkipHours = c.hours
+		feed.Channel.SkipDays = c.days
+		got := feedScheduleHints(&feed)
+		if got.interval != c.want.interval || fmt.Sprint(got.skipHours) != fmt.Sprint(c.want.skipHours) || fmt.Sprint(got.skipDays) != fmt.Sprint(c.want.skipDays) {
+			t.Errorf("%v: feedScheduleHints = %+v, want %+v", c.name, got, c.want)
+		}
+		// The columns are NOT NULL
+		if got.skipHours == nil || got.skipDays == nil {
+			t.Errorf("%v: feedScheduleHints returned nil skip lists", c.name)
+		}
+	}
+}
+
+func TestNextFetchInterval(t *testing.T) {
+	override := func(d time.Duration) sql.NullInt32 {
+		return sql.NullInt32{Int32: int32(d.Seconds()), Valid: true}
+	}
+	cases := []struct {
+		name     string
+		override sql.NullInt32
+		hinted   time.Duration
+		cadence  time.Duration
+		want     time.Duration
+	}{
+		{name: "default", want: defaultFetchInterval},
+		{name: "half the cadence", cadence: 4 * time.Hour, want: 2 * time.Hour},
+		{name: "frequent posts", cadence: 10 * time.Minute, want: minFetchInterval},
+		{name: "rare posts", cadence: 100 * time.Hour, want: maxFetchInterval},
+		{name: "hint beats cadence", hinted: 3 * time.Hour, cadence: time.Hour, want: 3 * time.Hour},
+		{name: "short hint", hinted: 10 * time.Minute, want: defaultFetchInterval},
+		{name: "hint capped", hinted: 30 * 24 * time.Hour, want: maxHintedInterval},
+		{name: "override beats hint", override: override(2 * time.Hour), hinted: 7 * 24 * time.Hour, cadence: time.Minute, want: 2 * time.Hour},
+		{name: "override below the minimum", override: override(5 * time.Minute), want: 5 * time.Minute},
+		{name: "zero override", override: override(0), cadence: 4 * time.Hour, want: 2 * time.Hour},
+	}
+	for _, c := range cases {
+		feed := database.Feed{IntervalOverrideSeconds: c.override, HintedIntervalSeconds: int32(c.hinted.Seconds())}
+		if got := nextFetchInterval(feed, c.cadence); got != c.want {
+			t.Errorf("%v: nextFetchInterval = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNextFetchTime(t *testing.T) {
+	utc := func(day, hour, minute int) time.Time {
+		// January 2024 starts on a Monday
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	pacific := time.FixedZone("PST", -8*60*60)
+	everyHour := make([]int32, 24)
+	for i := range everyHour {
+		everyHour[i] = int32(i)
+	}
+
+	cases := []struct {
+		name     string
+		from     time.Time
+		interval time.Duration
+		hours    []int32
+		days     []string
+		want     time.Time
+	}{
+		{name: "no skips", from: utc(1, 10, 30), interval: time.Hour, want: utc(1, 11, 30)},
+		{name: "outside skipped hours", from: utc(1, 10, 30), interval: time.Hour, hours: []int32{22, 23, 0, 1}, want: utc(1, 11, 30)},
+		{name: "skip hours past midnight", from: utc(1, 21, 30), interval: time.Hour, hours: []int32{22, 23, 0, 1}, want: utc(2, 2, 0)},
+		{name: "skip a day", from: utc(6, 12, 0), interval: time.Hour, days: []string{"saturday"}, want: utc(7, 0, 0)},
+		{name: "skip days and hours", from: utc(6, 23, 30), interval: time.Hour, hours: []int32{0}, days: []string{"sunday"}, want: utc(8, 1, 0)},
+		// Friday evening in California is already Saturday in GMT
+		{name: "skip days in gmt", from: time.Date(2024, 1, 5, 17, 0, 0, 0, pacific), interval: time.Hour, days: []string{"saturday"}, want: utc(7, 0, 0)},
+		{name: "skip hours in gmt", from: time.Date(2024, 1, 1, 13, 30, 0, 0, pacific), interval: time.Hour, hours: []int32{22}, want: utc(1, 23, 0)},
+		{name: "everything skipped", from: utc(1, 10, 30), interval: time.Hour, hours: everyHour, want: utc(1, 11, 30)},
+	}
+	for _, c := range cases {
+		if got := nextFetchTime(c.from, c.interval, c.hours, c.days); !got.Equal(c.want) {
+			t.Errorf("%v: nextFetchTime = %v, want %v", c.name, got.UTC(), c.want)
+		}
+	}
+}
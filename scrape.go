@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/dateparse"
+)
+
+type aggOptions struct {
+	workers int
+	batch   int
+	perHost int
+	lease   time.Duration
+	drain   time.Duration
+	owner   string
+
+	maxFailures int
+}
+
+var defaultAggOptions = aggOptions{
+	workers: 4,
+	batch:   10,
+	perHost: 2,
+	lease:   10 * time.Minute,
+	drain:   30 * time.Second,
+	owner:   aggWorkerID(),
+
+	maxFailures: 10,
+}
+
+// Failing feeds are retried after backoffBase, doubling with every consecutive failure up to backoffMax.
+const (
+	backoffBase = 5 * time.Minute
+	backoffMax  = 24 * time.Hour
+)
+
+func feedBackoff(failures int32) time.Duration {
+	backoff := backoffBase
+	for i := int32(1); i < failures && backoff < backoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, backoffMax)
+}
+
+// aggWorkerID identifies this agg process in feeds.lease_owner.
+func aggWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%v:%v:%v", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+type scrapeSummary struct {
+	feeds       int
+	notModified int
+	added       int
+	updated     int
+	errors      int
+	bytesRead   int64
+	bytesSaved  int64
+}
+
+func (sum *scrapeSummary) add(other scrapeSummary) {
+	sum.feeds += other.feeds
+	sum.notModified += other.notModified
+	sum.added += other.added
+	sum.updated += other.updated
+	sum.errors += other.errors
+	sum.bytesRead += other.bytesRead
+	sum.bytesSaved += other.bytesSaved
+}
+
+func (sum scrapeSummary) String() string {
+	return fmt.Sprintf("fetched %v feeds (%v not modified), %v posts added, %v updated, %v errors, %v bytes downloaded, %v bytes saved",
+		sum.feeds, sum.notModified, sum.added, sum.updated, sum.errors, sum.bytesRead, sum.bytesSaved)
+}
+
+// hostLimiter caps how many requests are in flight against a single host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		hosts: make(map[string]chan struct{}),
+	}
+}
+
+func (l *hostLimiter) acquire(host string) func() {
+	l.mu.Lock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.hosts[host] = sem
+	}
+	l.mu.Unlock()
+
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// scrapeFeeds runs one aggregation cycle: it leases the next opts.batch feeds due for a fetch and
+// scrapes them with opts.workers goroutines.  Leases let any number of agg processes share the feeds table.
+// No new feeds are claimed or started once ctx is done, feeds already being scraped run on fetchCtx.
+func scrapeFeeds(ctx, fetchCtx context.Context, s *state, opts aggOptions) (scrapeSummary, error) {
+	if ctx.Err() != nil {
+		return scrapeSummary{}, nil
+	}
+	now := time.Now()
+	feeds, err := s.db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		Now:            now,
+		Owner:          opts.owner,
+		LeaseExpiresAt: sql.NullTime{Time: now.Add(opts.lease), Valid: true},
+		Batch:          int32(opts.batch),
+	})
+	if err != nil {
+		return scrapeSummary{}, fmt.Errorf("error claiming feeds to fetch: %w", err)
+	}
+
+	jobs := make(chan database.Feed)
+	limiter := newHostLimiter(opts.perHost)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		summary scrapeSummary
+	)
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				release := limiter.acquire(feedHost(feed.Url))
+				feedSummary, err := scrapeFeed(fetchCtx, s, feed)
+				release()
+				if err != nil {
+					// TODO:  LOG ERROR
+					fmt.Println(err.Error())
+					feedSummary.errors++
+					recordFeedFailure(fetchCtx, s, feed, err, opts.maxFailures)
+				}
+				releaseLease(fetchCtx, s, feed, opts.owner)
+
+				mu.Lock()
+				summary.add(feedSummary)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i, feed := range feeds {
+		if ctx.Err() != nil {
+			// Shutting down, hand the feeds nobody started on back to the queue
+			for _, unstarted := range feeds[i:] {
+				releaseLease(fetchCtx, s, unstarted, opts.owner)
+			}
+			break
+		}
+		select {
+		case jobs <- feed:
+		case <-ctx.Done():
+			releaseLease(fetchCtx, s, feed, opts.owner)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return summary, nil
+}
+
+func releaseLease(ctx context.Context, s *state, feed database.Feed, owner string) {
+	if err := s.db.ReleaseFeedLease(ctx, database.ReleaseFeedLeaseParams{
+		ID:         feed.ID,
+		LeaseOwner: owner,
+	}); err != nil {
+		// Not fatal, the lease runs out on its own
+		fmt.Printf("error releasing lease on feed (%v): %v\n", feed.Url, err)
+	}
+}
+
+func recordFeedFailure(ctx context.Context, s *state, feed database.Feed, fetchErr error, maxFailures int) {
+	now := time.Now()
+	updated, err := s.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		Error:       fetchErr.Error(),
+		At:          sql.NullTime{Time: now, Valid: true},
+		NextFetchAt: sql.NullTime{Time: now.Add(feedBackoff(feed.ConsecutiveFailures + 1)), Valid: true},
+		MaxFailures: int32(maxFailures),
+		ID:          feed.ID,
+	})
+	if err != nil {
+		fmt.Printf("error recording failure of feed (%v): %v\n", feed.Url, err)
+		return
+	}
+	if updated.Disabled {
+		fmt.Printf("Disabled %v after %v consecutive failures.  Re-enable with: gator feed enable %v\n", feed.Url, updated.ConsecutiveFailures, feed.Url)
+	}
+}
+
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed) (scrapeSummary, error) {
+	summary := scrapeSummary{feeds: 1}
+	if feed.PublicOnly {
+		ctx = withPublicOnly(ctx)
+	}
+	result, err := fetchFeedConditional(ctx, feed.Url, feed.Etag, feed.LastModified)
+	if err != nil {
+		return summary, fmt.Errorf("error fetching feed at (%v): %w", feed.Url, err)
+	}
+	if result.NotModified {
+		summary.notModified++
+		summary.bytesSaved += feed.LastBodySize
+		next, err := scheduleNextFetch(ctx, s, feed)
+		if err != nil {
+			return summary, err
+		}
+		fmt.Printf("Not modified: %v (saved %v bytes), next fetch %v\n", feed.Url, feed.LastBodySize, next.Format(time.RFC1123))
+		return summary, nil
+	}
+	summary.bytesRead += result.BodySize
+
+	hints := feedScheduleHints(result.Feed)
+	if err := s.db.UpdateFeedScheduleHints(ctx, database.UpdateFeedScheduleHintsParams{
+		ID:                    feed.ID,
+		HintedIntervalSeconds: int32(hints.interval.Seconds()),
+		SkipHours:             hints.skipHours,
+		SkipDays:              hints.skipDays,
+	}); err != nil {
+		return summary, fmt.Errorf("error saving schedule hints for feed (%v): %w", feed.Url, err)
+	}
+	feed.HintedIntervalSeconds = int32(hints.interval.Seconds())
+	feed.SkipHours = hints.skipHours
+	feed.SkipDays = hints.skipDays
+
+	for _, rssItem := range result.Feed.Channel.Item {
+		pubDate := sql.NullTime{}
+		if t, err := dateparse.ParseFirst(rssItem.PubDate, rssItem.Updated, rssItem.DcDate); err == nil {
+			pubDate = sql.NullTime{Time: t, Valid: true}
+		} else if !errors.Is(err, dateparse.ErrEmpty) {
+			// TODO:  LOG ERROR
+			fmt.Printf("Failed to parse publish date for %v: %v\n", rssItem.Title, err)
+		}
+
+		// categories is NOT NULL so don't let a nil slice through as NULL
+		categories := rssItem.Categories
+		if categories == nil {
+			categories = []string{}
+		}
+
+		guid := itemGUID(rssItem)
+		// Posts from before guids were tracked are keyed on their url, move a match onto the real guid first
+		if rssItem.Link != "" && guid != rssItem.Link {
+			if _, err := s.db.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{Guid: guid, FeedID: feed.ID, Url: rssItem.Link}); err != nil {
+				// TODO:  LOG ERROR
+				fmt.Printf("Error updating guid of Post: %v in feed: %v: %v\n", rssItem.Title, feed.Url, err)
+				summary.errors++
+				continue
+			}
+		}
+
+		retPost, err := s.db.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Title:       rssItem.Title,
+			Url:         rssItem.Link,
+			Description: rssItem.Description,
+			PublishedAt: pubDate,
+			FeedID:      feed.ID,
+			Author:      rssItem.Author,
+			Categories:  categories,
+			Guid:        guid,
+		})
+		if err != nil {
+			// No row back means the item is already stored and hasn't changed upstream
+			if !errors.Is(err, sql.ErrNoRows) {
+				// TODO:  LOG ERROR
+				fmt.Printf("Error creating Post: %v in feed: %v: %v\n", rssItem.Title, feed.Url, err)
+				summary.errors++
+			}
+		} else if retPost.Inserted {
+			summary.added++
+		} else {
+			summary.updated++
+		}
+	}
+
+	// Only remember the validators once the items are stored, otherwise a failed run would be skipped by a 304
+	if err := s.db.UpdateFeedCache(ctx, database.UpdateFeedCacheParams{
+		ID:           feed.ID,
+		Etag:         result.ETag,
+		LastModified: result.LastModified,
+		LastBodySize: result.BodySize,
+	}); err != nil {
+		return summary, fmt.Errorf("error saving cache headers for feed (%v): %w", feed.Url, err)
+	}
+
+	next, err := scheduleNextFetch(ctx, s, feed)
+	if err != nil {
+		return summary, err
+	}
+	fmt.Printf("Fetched %v: %v new, %v updated, next fetch %v\n", feed.Url, summary.added, summary.updated, next.Format(time.RFC1123))
+	return summary, nil
+}
+
+// itemGUID is the identity of an item within its feed.  Items without a guid/id fall back on a hash of link and title.
+func itemGUID(item RSSItem) string {
+	if guid := strings.TrimSpace(item.Guid); guid != "" {
+		return guid
+	}
+	sum := sha256.Sum256([]byte(item.Link + "\n" + item.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+func feedHost(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return feedURL
+	}
+	return u.Host
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedBackoff(t *testing.T) {
+	cases := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{5, 80 * time.Minute},
+		{9, 1280 * time.Minute},
+		// 2560m is past the cap
+		{10, 24 * time.Hour},
+		{1000, 24 * time.Hour},
+	}
+	for _, c := range cases {
+		if got := feedBackoff(c.failures); got != c.want {
+			t.Errorf("feedBackoff(%v) = %v, want %v", c.failures, got, c.want)
+		}
+	}
+}
+
+func TestRecordFeedFailure(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	fetchErr := errors.New("bad Status Code from response")
+
+	for failures := int32(1); failures <= 3; failures++ {
+		before := time.Now()
+		out := captureStdout(t, func() { recordFeedFailure(env.ctx, env.s, feed, fetchErr, 3) })
+		got, err := env.s.db.GetFeed(env.ctx, feed.Url)
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		if got.ConsecutiveFailures != failures || got.LastError != fetchErr.Error() {
+			t.Errorf("after failure %v: consecutive failures %v, last error %q", failures, got.ConsecutiveFailures, got.LastError)
+		}
+		if wait := got.NextFetchAt.Time.Sub(before); wait < feedBackoff(failures) || wait > feedBackoff(failures)+time.Minute {
+			t.Errorf("after failure %v: next fetch in %v, want %v", failures, wait, feedBackoff(failures))
+		}
+		// Disabled once it reaches --max-failures
+		if disabled := failures == 3; got.Disabled != disabled || strings.Contains(out, "Disabled ") != disabled {
+			t.Errorf("after failure %v: disabled %v, printed %q", failures, got.Disabled, out)
+		}
+		feed = got
+	}
+
+	env.mustRun(handlerFeed, "enable", feed.Url)
+	got, _ := env.s.db.GetFeed(env.ctx, feed.Url)
+	if got.Disabled || got.ConsecutiveFailures != 0 {
+		t.Errorf("feed after enable = %+v", got)
+	}
+}
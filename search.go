@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/dateparse"
+	"github.com/striderjg/gator/internal/sanitize"
+)
+
+// SearchPostsForUser wraps matched words in these
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// searchQuery is a search split into the full text part and its filters.
+type searchQuery struct {
+	text   string
+	feed   string
+	before time.Time
+	after  time.Time
+}
+
+// parseSearchQuery pulls feed:, before: and after: filters out of query.  Filter values can be quoted,
+// everything else is left for websearch_to_tsquery, quotes and all.
+func parseSearchQuery(query string) (searchQuery, error) {
+	var sq searchQuery
+	var text []string
+	for _, token := range splitSearchTokens(query) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" {
+			text = append(text, token)
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "feed":
+			sq.feed = value
+		case "before", "after":
+			t, err := dateparse.Parse(value)
+			if err != nil {
+				return searchQuery{}, fmt.Errorf("error parsing %v: %w", key, err)
+			}
+			if strings.ToLower(key) == "before" {
+				sq.before = t
+			} else {
+				sq.after = t
+			}
+		default:
+			text = append(text, token)
+		}
+	}
+	sq.text = strings.Join(text, " ")
+	return sq, nil
+}
+
+// splitSearchTokens splits on spaces outside of double quotes.  The quotes are kept.
+func splitSearchTokens(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func handlerSearch(ctx context.Context, s *state, cmd command, usr database.User) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("limit", 10, "maximum number of results")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New(`search expects a query.  Usage: search [--limit N] QUERY  e.g. search '"rust async" -tokio feed:https://blog.example.com/rss after:2024-01-01'`)
+	}
+	if *limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+
+	sq, err := parseSearchQuery(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sq.text) == "" {
+		return errors.New("search needs at least one word to look for besides the filters")
+	}
+
+	params := database.SearchPostsForUserParams{
+		Query:  sq.text,
+		UserID: usr.ID,
+		Limit:  int32(*limit),
+	}
+	if sq.feed != "" {
+		params.Feed = sql.NullString{String: sq.feed, Valid: true}
+	}
+	if !sq.before.IsZero() {
+		params.Before = sql.NullTime{Time: sq.before, Valid: true}
+	}
+	if !sq.after.IsZero() {
+		params.After = sql.NullTime{Time: sq.after, Valid: true}
+	}
+
+	results, err := s.db.SearchPostsForUser(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error searching posts: %w", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No matching posts")
+		return nil
+	}
+
+	startMark, stopMark := "*", "*"
+	if isTerminal(os.Stdout) {
+		startMark, stopMark = "\033[1;33m", "\033[0m"
+	}
+	highlight := strings.NewReplacer(highlightStart, startMark, highlightStop, stopMark)
+	for _, result := range results {
+		fmt.Println("++++++++++++++++++++++++++++++++++++++++++++++++++++")
+		fmt.Println(highlight.Replace(result.TitleHighlight))
+		fmt.Println(result.Url)
+		published := "no date"
+		if result.PublishedAt.Valid {
+			published = result.PublishedAt.Time.Format("2006-01-02")
+		}
+		fmt.Printf("id: %v (%v, %v, rank %.3f)\n", result.ID, result.FeedName, published, result.Rank)
+		// The snippet is cut out of the raw description so it can hold stray markup
+		if snippet := sanitize.Text(result.Snippet); snippet != "" {
+			fmt.Println("====================================================")
+			fmt.Println("..." + highlight.Replace(snippet) + "...")
+		}
+		fmt.Println("")
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
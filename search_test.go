@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	env := newTestEnv(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	news := env.feed("News", "https://example.com/news")
+	env.post(blog, "Generics in Go", "Type parameters arrived in Go 1.18.", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+	env.post(blog, "Error handling", "Wrapping errors in Go with fmt.Errorf.", time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC))
+	env.post(news, "Rust news", "Nothing about Go here, well almost.", time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+	search := middlewareLoggedIn(handlerSearch)
+
+	out := env.mustRun(search, "generics")
+	if !strings.Contains(out, "*Generics* in Go\n") || !strings.Contains(out, "(Blog, 2024-03-04, rank ") {
+		t.Errorf("search printed %q", out)
+	}
+	if strings.Contains(out, "Error handling") {
+		t.Errorf("search for generics found error handling: %q", out)
+	}
+
+	out = env.mustRun(search, "--limit", "5", "go", "feed:Blog", "after:2024-01-01")
+	if !strings.Contains(out, "Generics in *Go*") || strings.Count(out, "id: ") != 1 {
+		t.Errorf("search with filters printed %q", out)
+	}
+	if out := env.mustRun(search, "go -rust before:2024-01-01"); !strings.Contains(out, "Error handling") || strings.Count(out, "id: ") != 1 {
+		t.Errorf("search go -rust before:2024-01-01 printed %q", out)
+	}
+	if out := env.mustRun(search, "kotlin"); out != "No matching posts\n" {
+		t.Errorf("search kotlin printed %q", out)
+	}
+
+	env.runErr(search, "expects a query")
+	env.runErr(search, "--limit must be at least 1", "--limit", "0", "go")
+	env.runErr(search, "at least one word", "feed:Blog")
+	env.runErr(search, "error parsing before", "go", "before:someday")
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	sq, err := parseSearchQuery(`"error handling" feed:"Go Blog" after:2024-01-01 -panic url:x`)
+	if err != nil {
+		t.Fatalf("parseSearchQuery: %v", err)
+	}
+	if sq.text != `"error handling" -panic url:x` {
+		t.Errorf("text = %q", sq.text)
+	}
+	if sq.feed != "Go Blog" {
+		t.Errorf("feed = %q", sq.feed)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !sq.after.Equal(want) || !sq.before.IsZero() {
+		t.Errorf("after = %v, before = %v", sq.after, sq.before)
+	}
+}
@@ -0,0 +1,33 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, name, token_hash, user_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAPITokensForUser :many
+SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetUserByAPIToken :one
+-- Looks up the owner of a token and records that it was used.
+WITH used_token AS (
+    UPDATE api_tokens SET last_used_at = $2
+    WHERE token_hash = $1
+    RETURNING user_id
+)
+SELECT users.* FROM users
+INNER JOIN used_token ON users.id = used_token.user_id;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens ORDER BY created_at, id;
+
+-- name: RestoreAPIToken :exec
+INSERT INTO api_tokens (id, created_at, name, token_hash, user_id, last_used_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    name = EXCLUDED.name,
+    token_hash = EXCLUDED.token_hash,
+    user_id = EXCLUDED.user_id,
+    last_used_at = EXCLUDED.last_used_at;
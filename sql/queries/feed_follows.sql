@@ -1,7 +1,7 @@
 -- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
-    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
-    VALUES ($1, $2, $3, $4, $5)
+    INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
+    VALUES ($1, $2, $3, $4, $5, $6)
     RETURNING *
 )
 SELECT inserted_feed_follow.*,
@@ -12,13 +12,36 @@ INNER JOIN feeds ON inserted_feed_follow.feed_id = feeds.id
 INNER JOIN users ON inserted_feed_follow.user_id = users.id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT feed_follows.*, feeds.name AS feed_name, users.name AS user_name
+SELECT feed_follows.*, feeds.name AS feed_name, users.name AS user_name, feeds.url AS feed_url,
+    (
+        SELECT COUNT(*) FROM posts
+        LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+        WHERE posts.feed_id = feed_follows.feed_id AND post_reads.post_id IS NULL
+    ) AS unread_count
 FROM feed_follows
 INNER JOIN users ON feed_follows.user_id = users.id
 INNER JOIN feeds ON feed_follows.feed_id = feeds.id
-WHERE users.id = $1;
+WHERE users.id = $1
+ORDER BY feed_follows.category, feeds.name;
+
+-- name: SetFeedFollowCategory :execrows
+UPDATE feed_follows SET category = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2;
 
 -- name: DeleteFeedFollow :one
 DELETE FROM feed_follows 
 WHERE user_id = $1 AND feed_id = $2
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: ListFeedFollows :many
+SELECT * FROM feed_follows ORDER BY created_at, id;
+
+-- name: RestoreFeedFollow :exec
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, category)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    user_id = EXCLUDED.user_id,
+    feed_id = EXCLUDED.feed_id,
+    category = EXCLUDED.category;
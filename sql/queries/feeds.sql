@@ -1,12 +1,13 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, public_only)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
 )
 RETURNING *;
 
@@ -16,8 +17,93 @@ SELECT * FROM feeds WHERE url = $1;
 -- name: GetFeeds :many
 SELECT f.name, f.url, u.name AS username FROM feeds f INNER JOIN users u ON u.id = f.user_id;
 
--- name: MarkFeedFetched :exec
-UPDATE feeds SET updated_at=sqlc.arg(time), last_fetched_at=sqlc.arg(time) WHERE id=$1;
+-- name: ClaimFeedsToFetch :many
+-- Leases the most overdue feeds to one agg process.  SKIP LOCKED keeps concurrent claimers from
+-- blocking on or double claiming the same rows, and expired leases from crashed workers are fair game.
+UPDATE feeds SET updated_at = sqlc.arg(now), last_fetched_at = sqlc.arg(now),
+    lease_owner = sqlc.arg(owner), lease_expires_at = sqlc.arg(lease_expires_at)
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE NOT feeds.disabled
+        AND (feeds.next_fetch_at IS NULL OR feeds.next_fetch_at <= sqlc.arg(now))
+        AND (feeds.lease_expires_at IS NULL OR feeds.lease_expires_at < sqlc.arg(now))
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT sqlc.arg(batch)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ReleaseFeedLease :exec
+UPDATE feeds SET lease_owner = '', lease_expires_at = NULL WHERE id = $1 AND lease_owner = $2;
+
+-- name: UpdateFeedCache :exec
+UPDATE feeds SET etag=$2, last_modified=$3, last_body_size=$4 WHERE id=$1;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds SET last_error = sqlc.arg(error), last_error_at = sqlc.arg(at),
+    consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = sqlc.arg(next_fetch_at),
+    disabled = consecutive_failures + 1 >= sqlc.arg(max_failures)::integer
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds SET consecutive_failures = 0, next_fetch_at = $2 WHERE id = $1;
+
+-- name: GetFeedErrors :many
+SELECT f.name, f.url, f.last_error, f.last_error_at, f.consecutive_failures, f.next_fetch_at, f.disabled
+FROM feeds f
+WHERE f.consecutive_failures > 0 OR f.disabled
+ORDER BY f.disabled DESC, f.consecutive_failures DESC, f.url;
+
+-- name: EnableFeed :execrows
+UPDATE feeds SET disabled = false, consecutive_failures = 0, next_fetch_at = NULL WHERE url = $1;
+
+-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds SET hinted_interval_seconds = $2, skip_hours = $3, skip_days = $4 WHERE id = $1;
+
+-- name: SetFeedIntervalOverride :execrows
+UPDATE feeds SET interval_override_seconds = $2, next_fetch_at = NULL WHERE url = $1;
+
+-- name: SetFeedRetention :execrows
+UPDATE feeds SET retention_max_age_seconds = $2, retention_max_posts = $3,
+    retention_keep_unread = $4
+WHERE url = $1;
+
+-- name: GetFeedRetentions :many
+SELECT id, name, url, retention_max_age_seconds, retention_max_posts, retention_keep_unread
+FROM feeds
+ORDER BY name, url;
+
+-- name: ListFeeds :many
+SELECT * FROM feeds ORDER BY created_at, id;
 
--- name: GetNextFeedToFetch :one
-SELECT * FROM feeds ORDER BY last_fetched_at NULLS FIRST LIMIT 1;
\ No newline at end of file
+-- name: RestoreFeed :exec
+-- Inserts or replaces a feed from a backup.  Leases belong to the agg process that took them and aren't restored.
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_body_size,
+    last_error, last_error_at, consecutive_failures, next_fetch_at, disabled, hinted_interval_seconds, skip_hours, skip_days,
+    interval_override_seconds, retention_max_age_seconds, retention_max_posts, retention_keep_unread, public_only)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    name = EXCLUDED.name,
+    url = EXCLUDED.url,
+    user_id = EXCLUDED.user_id,
+    last_fetched_at = EXCLUDED.last_fetched_at,
+    etag = EXCLUDED.etag,
+    last_modified = EXCLUDED.last_modified,
+    last_body_size = EXCLUDED.last_body_size,
+    last_error = EXCLUDED.last_error,
+    last_error_at = EXCLUDED.last_error_at,
+    consecutive_failures = EXCLUDED.consecutive_failures,
+    next_fetch_at = EXCLUDED.next_fetch_at,
+    disabled = EXCLUDED.disabled,
+    hinted_interval_seconds = EXCLUDED.hinted_interval_seconds,
+    skip_hours = EXCLUDED.skip_hours,
+    skip_days = EXCLUDED.skip_days,
+    interval_override_seconds = EXCLUDED.interval_override_seconds,
+    retention_max_age_seconds = EXCLUDED.retention_max_age_seconds,
+    retention_max_posts = EXCLUDED.retention_max_posts,
+    retention_keep_unread = EXCLUDED.retention_keep_unread,
+    public_only = EXCLUDED.public_only;
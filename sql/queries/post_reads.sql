@@ -0,0 +1,26 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :exec
+DELETE FROM post_reads WHERE user_id = $1 AND post_id = $2;
+
+-- name: MarkPostsRead :execrows
+-- Marks every unread post in the user's followed feeds read, optionally limited to one feed and to posts from before a date.
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, sqlc.arg(read_at)
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+    AND (sqlc.narg(before)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(before))
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: ListPostReads :many
+SELECT * FROM post_reads ORDER BY user_id, post_id;
+
+-- name: RestorePostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = EXCLUDED.read_at;
@@ -0,0 +1,31 @@
+-- name: StarPost :one
+-- Starring an already starred post only replaces the note when a new one is given.
+INSERT INTO post_stars (user_id, post_id, note, created_at, updated_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(post_id), COALESCE(sqlc.narg(note)::text, ''), sqlc.arg(starred_at), sqlc.arg(starred_at))
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+    note = COALESCE(sqlc.narg(note)::text, post_stars.note),
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetStarredPostsForUser :many
+-- Starred posts stay listed after their feed is unfollowed.
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.author, posts.categories, posts.guid, feeds.name AS feed_name, feeds.url AS feed_url, post_stars.note, post_stars.created_at AS starred_at
+FROM post_stars
+INNER JOIN posts ON post_stars.post_id = posts.id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_stars.user_id = $1
+ORDER BY post_stars.created_at DESC;
+
+-- name: ListPostStars :many
+SELECT * FROM post_stars ORDER BY user_id, post_id;
+
+-- name: RestorePostStar :exec
+INSERT INTO post_stars (user_id, post_id, note, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+    note = EXCLUDED.note,
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at;
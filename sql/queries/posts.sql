@@ -1,10 +1,233 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING *;
+-- Items whose posts were pruned aren't stored again, they return no row like an unchanged one.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+SELECT $1::uuid, $2::timestamp, $3::timestamp, $4::text, $5::text, $6::text, $7::timestamp, $8::uuid, $9::text, $10::text[], $11::text
+WHERE NOT EXISTS (SELECT 1 FROM pruned_posts pp WHERE pp.feed_id = $8 AND pp.guid = $11)
+ON CONFLICT (feed_id, guid) DO UPDATE SET
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+    OR posts.url IS DISTINCT FROM EXCLUDED.url
+    OR posts.description IS DISTINCT FROM EXCLUDED.description
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid, (xmax = 0) AS inserted;
 
--- name: GetPostsForUser :many
-SELECT * FROM posts 
+-- name: AdoptLegacyPost :execrows
+-- Posts stored before guids were tracked got their url as guid (007_posts.sql).  Moves such a post onto the
+-- item's real guid so CreatePost updates it instead of inserting the item a second time.
+UPDATE posts SET guid = sqlc.arg(guid)
+WHERE feed_id = sqlc.arg(feed_id) AND guid = sqlc.arg(url) AND url = sqlc.arg(url)
+    AND NOT EXISTS (SELECT 1 FROM posts taken WHERE taken.feed_id = sqlc.arg(feed_id) AND taken.guid = sqlc.arg(guid));
+
+-- name: GetFeedPostCadence :one
+-- Average gap in seconds between the feed's most recent posts, 0 when there isn't enough history.
+SELECT COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)) / NULLIF(COUNT(*) - 1, 0), 0)::float8 AS avg_gap_seconds
+FROM (
+    SELECT published_at FROM posts
+    WHERE feed_id = $1 AND published_at IS NOT NULL
+    ORDER BY published_at DESC LIMIT 20
+) recent;
+
+-- name: GetPostForUser :one
+-- A single post, only if it's in one of the user's followed feeds.
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.author, posts.categories, posts.guid, feeds.name AS feed_name, feeds.url AS feed_url, (post_reads.read_at IS NOT NULL)::boolean AS read FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2;
+
+-- name: ListPostsForUser :many
+-- Posts from the feeds a user follows, newest first, with optional feed and published date filters.
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.author, posts.categories, posts.guid, feeds.name AS feed_name, feeds.url AS feed_url, (post_reads.read_at IS NOT NULL)::boolean AS read FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR posts.published_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR posts.published_at < sqlc.narg(until))
+    AND (NOT sqlc.arg(unread_only)::boolean OR post_reads.post_id IS NULL)
+ORDER BY posts.published_at DESC NULLS LAST, posts.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SearchPostsForUser :many
+-- Full text search over the user's followed feeds, best match first.  query uses websearch syntax so
+-- "quoted phrases", OR and -word work.  Matches are wrapped in \x01 \x02 in the highlight and snippet.
+SELECT posts.id, posts.title, posts.url, posts.published_at, feeds.name AS feed_name,
+    ts_rank(posts.search_vector, q) AS rank,
+    ts_headline('english', posts.title, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', HighlightAll=true')::text AS title_highlight,
+    ts_headline('english', posts.description, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxFragments=2, MaxWords=25, MinWords=8')::text AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', sqlc.arg(query)) AS q
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND posts.search_vector @@ q
+    AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR lower(feeds.name) = lower(sqlc.narg(feed)))
+    AND (sqlc.narg(before)::timestamp IS NULL OR posts.published_at < sqlc.narg(before))
+    AND (sqlc.narg(after)::timestamp IS NULL OR posts.published_at >= sqlc.narg(after))
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT sqlc.arg('limit');
+
+-- name: BrowsePostsByPublished :many
+-- Keyset paginated posts from the user's followed feeds, newest published first.
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    COALESCE(posts.published_at, posts.created_at)::timestamp AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR lower(feeds.name) = lower(sqlc.narg(feed)))
+    AND (sqlc.narg(since)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+    AND (NOT sqlc.arg(unread_only)::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(include)::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(exclude)::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND (sqlc.narg(cursor_at)::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) < (sqlc.narg(cursor_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: BrowsePostsByPublishedReverse :many
+-- Keyset paginated posts from the user's followed feeds, oldest published first.
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    COALESCE(posts.published_at, posts.created_at)::timestamp AS sort_at
+FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1 
-ORDER BY posts.updated_at DESC LIMIT $2;
\ No newline at end of file
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR lower(feeds.name) = lower(sqlc.narg(feed)))
+    AND (sqlc.narg(since)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+    AND (NOT sqlc.arg(unread_only)::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(include)::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(exclude)::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND (sqlc.narg(cursor_at)::timestamp IS NULL OR (COALESCE(posts.published_at, posts.created_at), posts.id) > (sqlc.narg(cursor_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY COALESCE(posts.published_at, posts.created_at) ASC, posts.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: BrowsePostsByFetched :many
+-- Keyset paginated posts from the user's followed feeds, most recently fetched first.
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    posts.created_at AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR lower(feeds.name) = lower(sqlc.narg(feed)))
+    AND (sqlc.narg(since)::timestamp IS NULL OR posts.created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR posts.created_at < sqlc.narg(until))
+    AND (NOT sqlc.arg(unread_only)::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(include)::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(exclude)::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND (sqlc.narg(cursor_at)::timestamp IS NULL OR (posts.created_at, posts.id) < (sqlc.narg(cursor_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY posts.created_at DESC, posts.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: BrowsePostsByFetchedReverse :many
+-- Keyset paginated posts from the user's followed feeds, first fetched first.
+SELECT posts.id, posts.title, posts.url, posts.description, posts.published_at, posts.created_at, feeds.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::boolean AS read,
+    posts.created_at AS sort_at
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR lower(feeds.name) = lower(sqlc.narg(feed)))
+    AND (sqlc.narg(since)::timestamp IS NULL OR posts.created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR posts.created_at < sqlc.narg(until))
+    AND (NOT sqlc.arg(unread_only)::boolean OR post_reads.post_id IS NULL)
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(include)::text[]) AS word WHERE posts.title || ' ' || posts.description NOT ILIKE '%' || word || '%')
+    AND NOT EXISTS (SELECT 1 FROM unnest(sqlc.arg(exclude)::text[]) AS word WHERE posts.title || ' ' || posts.description ILIKE '%' || word || '%')
+    AND (sqlc.narg(cursor_at)::timestamp IS NULL OR (posts.created_at, posts.id) > (sqlc.narg(cursor_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY posts.created_at ASC, posts.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: CountPrunablePosts :one
+-- The number of posts PrunePosts would delete with the same arguments.
+SELECT COUNT(*) FROM posts WHERE posts.id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, COALESCE(p.published_at, p.created_at) AS sort_at,
+            ROW_NUMBER() OVER (ORDER BY COALESCE(p.published_at, p.created_at) DESC, p.id DESC) AS position
+        FROM posts p
+        WHERE p.feed_id = sqlc.arg(feed_id)
+    ) ranked
+    WHERE ((sqlc.narg(before)::timestamp IS NOT NULL AND ranked.sort_at < sqlc.narg(before))
+            OR (sqlc.narg(max_posts)::integer IS NOT NULL AND ranked.position > sqlc.narg(max_posts)))
+        AND NOT (sqlc.arg(keep_unread)::boolean AND EXISTS (
+            SELECT 1 FROM feed_follows ff
+            WHERE ff.feed_id = sqlc.arg(feed_id)
+                AND NOT EXISTS (SELECT 1 FROM post_reads r WHERE r.post_id = ranked.id AND r.user_id = ff.user_id)
+        ))
+        AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = ranked.id)
+);
+
+-- name: PrunePosts :one
+-- Deletes the feed's posts published (or fetched, without a date) before before, and the ones past the newest
+-- max_posts.  Kept posts still count towards max_posts.  Unread means some follower of the feed hasn't read it.
+-- Posts anyone starred are never deleted, the cascade would take their stars with them.  The deleted
+-- posts' guids go in pruned_posts so CreatePost doesn't bring them back while they're still in the feed.
+WITH pruned AS (
+    DELETE FROM posts WHERE posts.id IN (
+        SELECT ranked.id FROM (
+            SELECT p.id, COALESCE(p.published_at, p.created_at) AS sort_at,
+                ROW_NUMBER() OVER (ORDER BY COALESCE(p.published_at, p.created_at) DESC, p.id DESC) AS position
+            FROM posts p
+            WHERE p.feed_id = sqlc.arg(feed_id)
+        ) ranked
+        WHERE ((sqlc.narg(before)::timestamp IS NOT NULL AND ranked.sort_at < sqlc.narg(before))
+                OR (sqlc.narg(max_posts)::integer IS NOT NULL AND ranked.position > sqlc.narg(max_posts)))
+            AND NOT (sqlc.arg(keep_unread)::boolean AND EXISTS (
+                SELECT 1 FROM feed_follows ff
+                WHERE ff.feed_id = sqlc.arg(feed_id)
+                    AND NOT EXISTS (SELECT 1 FROM post_reads r WHERE r.post_id = ranked.id AND r.user_id = ff.user_id)
+            ))
+            AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = ranked.id)
+    )
+    RETURNING posts.feed_id, posts.guid
+), tombstones AS (
+    INSERT INTO pruned_posts (feed_id, guid)
+    SELECT feed_id, guid FROM pruned
+    ON CONFLICT DO NOTHING
+)
+SELECT COUNT(*) FROM pruned;
+
+-- name: ListPosts :many
+-- Pages through every post in id order, for backups.
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid
+FROM posts
+WHERE id > sqlc.arg(after)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: RestorePost :exec
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, guid)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    feed_id = EXCLUDED.feed_id,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    guid = EXCLUDED.guid;
+
+-- name: ListPrunedPosts :many
+SELECT * FROM pruned_posts ORDER BY feed_id, guid;
+
+-- name: RestorePrunedPost :exec
+INSERT INTO pruned_posts (feed_id, guid)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
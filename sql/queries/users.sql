@@ -15,4 +15,16 @@ SELECT * FROM users WHERE name = $1;
 SELECT name FROM users;
 
 -- name: ClearDB :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+
+-- name: ListUsers :many
+SELECT * FROM users ORDER BY created_at, id;
+
+-- name: RestoreUser :exec
+-- Inserts or replaces a user from a backup, keeping its id and timestamps.
+INSERT INTO users (id, created_at, updated_at, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    name = EXCLUDED.name;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts ADD author TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD categories TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE posts DROP categories;
+ALTER TABLE posts DROP author;
\ No newline at end of file
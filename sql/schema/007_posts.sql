@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE posts ADD guid TEXT;
+-- Existing rows have no recorded guid, the url is the best identity we have for them.  The scraper moves
+-- them onto the item's real guid the next time it sees the item, see AdoptLegacyPost.
+UPDATE posts SET guid = url;
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE(feed_id, guid);
+
+-- +goose Down
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE(url);
+ALTER TABLE posts DROP guid;
\ No newline at end of file
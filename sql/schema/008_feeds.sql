@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD last_modified TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD last_body_size BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds DROP last_body_size;
+ALTER TABLE feeds DROP last_modified;
+ALTER TABLE feeds DROP etag;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds ADD lease_owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD lease_expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds DROP lease_expires_at;
+ALTER TABLE feeds DROP lease_owner;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds ADD last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD last_error_at TIMESTAMP;
+ALTER TABLE feeds ADD consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD next_fetch_at TIMESTAMP;
+ALTER TABLE feeds ADD disabled BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE feeds DROP disabled;
+ALTER TABLE feeds DROP next_fetch_at;
+ALTER TABLE feeds DROP consecutive_failures;
+ALTER TABLE feeds DROP last_error_at;
+ALTER TABLE feeds DROP last_error;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds ADD hinted_interval_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD skip_hours INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE feeds ADD skip_days TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE feeds ADD interval_override_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds DROP interval_override_seconds;
+ALTER TABLE feeds DROP skip_days;
+ALTER TABLE feeds DROP skip_hours;
+ALTER TABLE feeds DROP hinted_interval_seconds;
\ No newline at end of file
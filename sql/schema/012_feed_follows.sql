@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE feed_follows ADD category TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE feed_follows DROP category;
\ No newline at end of file
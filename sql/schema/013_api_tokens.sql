@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE api_tokens(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    user_id UUID NOT NULL,
+    last_used_at TIMESTAMP,
+    CONSTRAINT fk_users FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
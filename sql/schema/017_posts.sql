@@ -0,0 +1,7 @@
+-- +goose Up
+CREATE INDEX posts_published_sort_idx ON posts ((COALESCE(published_at, created_at)), id);
+CREATE INDEX posts_created_at_sort_idx ON posts (created_at, id);
+
+-- +goose Down
+DROP INDEX posts_created_at_sort_idx;
+DROP INDEX posts_published_sort_idx;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Feeds added through the api, every fetch of them only connects to public addresses
+ALTER TABLE feeds ADD public_only BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE feeds DROP public_only;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Items whose posts prune deleted, so fetching the feed again doesn't store them as new posts
+CREATE TABLE pruned_posts(
+    feed_id UUID NOT NULL,
+    guid TEXT NOT NULL,
+    PRIMARY KEY(feed_id, guid),
+    CONSTRAINT fk_feeds FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
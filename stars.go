@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/sanitize"
+)
+
+func handlerStar(ctx context.Context, s *state, cmd command, usr database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("star expects an argument.  Usage: star POST_ID [NOTE]")
+	}
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+	post, err := s.db.GetPostForUser(ctx, database.GetPostForUserParams{ID: id, UserID: usr.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no post %v in the feeds you follow", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving post: %w", err)
+	}
+
+	// Without a NOTE an existing note is kept
+	note := sql.NullString{}
+	if len(cmd.args) > 1 {
+		note = sql.NullString{String: strings.Join(cmd.args[1:], " "), Valid: true}
+	}
+	star, err := s.db.StarPost(ctx, database.StarPostParams{
+		UserID:    usr.ID,
+		PostID:    post.ID,
+		Note:      note,
+		StarredAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error starring post: %w", err)
+	}
+
+	fmt.Printf("Starred: %v\n", post.Title)
+	if star.Note != "" {
+		fmt.Println("Note: ", star.Note)
+	}
+	return nil
+}
+
+func handlerUnstar(ctx context.Context, s *state, cmd command, usr database.User) error {
+	if len(cmd.args) < 1 {
+		return errors.New("unstar expects an argument.  Usage: unstar POST_ID")
+	}
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %w", err)
+	}
+	n, err := s.db.UnstarPost(ctx, database.UnstarPostParams{UserID: usr.ID, PostID: id})
+	if err != nil {
+		return fmt.Errorf("error unstarring post: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %v isn't starred", id)
+	}
+	fmt.Printf("Unstarred %v\n", id)
+	return nil
+}
+
+type starredExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	FeedName    string     `json:"feed_name"`
+	FeedURL     string     `json:"feed_url"`
+	Author      string     `json:"author"`
+	Categories  []string   `json:"categories"`
+	Description string     `json:"description"`
+	PublishedAt *time.Time `json:"published_at"`
+	StarredAt   time.Time  `json:"starred_at"`
+	Note        string     `json:"note"`
+}
+
+// handlerStarred lists starred posts, or with --export writes them as markdown or json to FILE or stdout.
+func handlerStarred(ctx context.Context, s *state, cmd command, usr database.User) error {
+	fs := flag.NewFlagSet("starred", flag.ContinueOnError)
+	export := fs.String("export", "", "write starred posts as markdown or json")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if *export != "" && *export != "markdown" && *export != "md" && *export != "json" {
+		return fmt.Errorf("unknown export format %q, expected markdown or json", *export)
+	}
+
+	stars, err := s.db.GetStarredPostsForUser(ctx, usr.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving starred posts: %w", err)
+	}
+
+	if *export == "" {
+		if len(stars) == 0 {
+			fmt.Println("No starred posts")
+			return nil
+		}
+		for _, star := range stars {
+			fmt.Printf("  *  %v\n", star.Title)
+			fmt.Printf("     %v\n", star.Url)
+			fmt.Printf("     id: %v (%v, starred %v)\n", star.ID, star.FeedName, star.StarredAt.Format(time.RFC1123))
+			if star.Note != "" {
+				fmt.Printf("     note: %v\n", star.Note)
+			}
+		}
+		return nil
+	}
+
+	var out io.Writer = os.Stdout
+	if len(args) > 0 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return fmt.Errorf("error creating export file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *export == "json" {
+		err = writeStarredJSON(out, stars)
+	} else {
+		err = writeStarredMarkdown(out, usr, stars)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing starred posts: %w", err)
+	}
+	return nil
+}
+
+func writeStarredJSON(out io.Writer, stars []database.GetStarredPostsForUserRow) error {
+	items := make([]starredExport, 0, len(stars))
+	for _, star := range stars {
+		item := starredExport{
+			ID:          star.ID,
+			Title:       star.Title,
+			URL:         star.Url,
+			FeedName:    star.FeedName,
+			FeedURL:     star.FeedUrl,
+			Author:      star.Author,
+			Categories:  star.Categories,
+			Description: star.Description,
+			StarredAt:   star.StarredAt,
+			Note:        star.Note,
+		}
+		if star.PublishedAt.Valid {
+			item.PublishedAt = &star.PublishedAt.Time
+		}
+		items = append(items, item)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(items)
+}
+
+func writeStarredMarkdown(out io.Writer, usr database.User, stars []database.GetStarredPostsForUserRow) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Starred posts for %v\n", usr.Name)
+	for _, star := range stars {
+		fmt.Fprintf(&sb, "\n## [%v](%v)\n\n", markdownEscape(postTitle(star.Title)), markdownURL(star.Url))
+
+		meta := []string{markdownEscape(star.FeedName)}
+		if star.Author != "" {
+			meta = append(meta, markdownEscape(star.Author))
+		}
+		if star.PublishedAt.Valid {
+			meta = append(meta, star.PublishedAt.Time.Format("2006-01-02"))
+		}
+		meta = append(meta, "starred "+star.StarredAt.Format("2006-01-02"))
+		fmt.Fprintf(&sb, "*%v*\n", strings.Join(meta, " · "))
+
+		if star.Note != "" {
+			fmt.Fprintf(&sb, "\n> %v\n", strings.ReplaceAll(star.Note, "\n", "\n> "))
+		}
+		if summary := truncate(sanitize.Text(star.Description), 500); summary != "" {
+			fmt.Fprintf(&sb, "\n%v\n", markdownEscape(summary))
+		}
+	}
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
+
+// markdownEscape keeps titles and summaries from turning into markdown links, emphasis or headings.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+		"[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+	).Replace(s)
+}
+
+func markdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestStarUnstar(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	post := env.post(feed, "Generics in Go", "", time.Now())
+	star := middlewareLoggedIn(handlerStar)
+	unstar := middlewareLoggedIn(handlerUnstar)
+	starred := middlewareLoggedIn(handlerStarred)
+
+	if out := env.mustRun(starred); out != "No starred posts\n" {
+		t.Errorf("starred printed %q with no stars", out)
+	}
+	if out := env.mustRun(star, post.ID.String(), "read", "later"); out != "Starred: Generics in Go\nNote:  read later\n" {
+		t.Errorf("star printed %q", out)
+	}
+	// Starring again without a note keeps the note
+	if out := env.mustRun(star, post.ID.String()); !strings.Contains(out, "Note:  read later") {
+		t.Errorf("star again printed %q", out)
+	}
+	out := env.mustRun(starred)
+	if !strings.Contains(out, "*  Generics in Go") || !strings.Contains(out, "note: read later") {
+		t.Errorf("starred printed %q", out)
+	}
+	env.runErr(star, "no post", uuid.NewString())
+	env.runErr(star, "invalid post id", "42")
+	env.runErr(star, "expects an argument")
+
+	if out := env.mustRun(unstar, post.ID.String()); out != "Unstarred "+post.ID.String()+"\n" {
+		t.Errorf("unstar printed %q", out)
+	}
+	env.runErr(unstar, "isn't starred", post.ID.String())
+	env.runErr(unstar, "invalid post id", "42")
+	env.runErr(unstar, "expects an argument")
+}
+
+func TestStarredExport(t *testing.T) {
+	env := newTestEnv(t)
+	feed := env.feed("Blog", "https://example.com/rss")
+	post := env.post(feed, "Generics *in* Go", "Type parameters", time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC))
+	env.mustRun(middlewareLoggedIn(handlerStar), post.ID.String(), "good one")
+	starred := middlewareLoggedIn(handlerStarred)
+
+	var items []starredExport
+	if err := json.Unmarshal([]byte(env.mustRun(starred, "--export", "json")), &items); err != nil {
+		t.Fatalf("decoding starred --export json: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != post.ID || items[0].Note != "good one" || items[0].FeedURL != feed.Url {
+		t.Errorf("starred --export json = %+v", items)
+	}
+
+	path := filepath.Join(t.TempDir(), "starred.md")
+	if out := env.mustRun(starred, "--export", "markdown", path); out != "" {
+		t.Errorf("starred --export to a file printed %q", out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	for _, want := range []string{"# Starred posts for alice\n", `## [Generics \*in\* Go](` + post.Url + ")", "*Blog · 2024-03-04 · starred ", "> good one\n", "Type parameters\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("markdown export %q is missing %q", data, want)
+		}
+	}
+
+	env.runErr(starred, "unknown export format", "--export", "html")
+	env.runErr(starred, "error creating export file", "--export", "json", filepath.Join(t.TempDir(), "missing", "starred.json"))
+}
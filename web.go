@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+	"github.com/striderjg/gator/internal/sanitize"
+)
+
+//go:embed web/templates web/static
+var webFS embed.FS
+
+const (
+	webPageSize   = 30
+	webSummaryLen = 280
+)
+
+// webApp is the reader UI for a single user, the one logged in when `gator web` was started.
+type webApp struct {
+	s         *state
+	usr       database.User
+	templates map[string]*template.Template
+}
+
+// webPage is handed to every template.  Fields a page doesn't use are left empty.
+type webPage struct {
+	Title   string
+	User    string
+	Follows []database.GetFeedFollowsForUserRow
+	FeedID  string
+
+	Posts   []webPost
+	PrevURL string
+	NextURL string
+
+	Post    *webPost
+	BackURL string
+
+	Message string
+}
+
+type webPost struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	FeedName   string
+	Title      string
+	URL        string
+	Author     string
+	Categories []string
+	Published  string
+	Read       bool
+	Summary    string
+	Content    template.HTML
+}
+
+func handlerWeb(ctx context.Context, s *state, cmd command, usr database.User) error {
+	flags := flag.NewFlagSet("web", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8081", "address to listen on")
+	if _, err := parseFlags(flags, cmd.args); err != nil {
+		return err
+	}
+
+	app, err := newWebApp(s, usr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           app.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Reading as %v at http://%v/\n", usr.Name, *addr)
+	return listenAndServe(ctx, srv)
+}
+
+func newWebApp(s *state, usr database.User) (*webApp, error) {
+	app := &webApp{s: s, usr: usr, templates: map[string]*template.Template{}}
+	for _, page := range []string{"river", "post", "error"} {
+		tmpl, err := template.ParseFS(webFS, "web/templates/layout.html", "web/templates/"+page+".html")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %v template: %w", page, err)
+		}
+		app.templates[page] = tmpl
+	}
+	return app, nil
+}
+
+func (app *webApp) routes() *http.ServeMux {
+	static, err := fs.Sub(webFS, "web/static")
+	if err != nil {
+		// web/static is embedded, it can't be missing
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /{$}", app.handleRiver)
+	mux.HandleFunc("GET /posts/{id}", app.handlePost)
+	mux.HandleFunc("POST /posts/{id}/read", app.handleMarkRead(true))
+	mux.HandleFunc("POST /posts/{id}/unread", app.handleMarkRead(false))
+	return mux
+}
+
+func (app *webApp) handleRiver(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	params := database.ListPostsForUserParams{
+		UserID: app.usr.ID,
+		// One extra row tells us whether there's an older page
+		Limit:  webPageSize + 1,
+		Offset: int32((page - 1) * webPageSize),
+	}
+
+	data, err := app.newPage(r.Context(), "All posts")
+	if err != nil {
+		app.renderError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if feedParam := r.URL.Query().Get("feed"); feedParam != "" {
+		feedID, err := uuid.Parse(feedParam)
+		if err != nil {
+			app.renderError(w, http.StatusBadRequest, "invalid feed id")
+			return
+		}
+		params.FeedID = uuid.NullUUID{UUID: feedID, Valid: true}
+		data.FeedID = feedID.String()
+		for _, ff := range data.Follows {
+			if ff.FeedID == feedID {
+				data.Title = ff.FeedName
+			}
+		}
+	}
+
+	posts, err := app.s.db.ListPostsForUser(r.Context(), params)
+	if err != nil {
+		app.renderError(w, http.StatusInternalServerError, "error retrieving posts")
+		return
+	}
+	if len(posts) > webPageSize {
+		posts = posts[:webPageSize]
+		data.NextURL = riverURL(data.FeedID, page+1)
+	}
+	if page > 1 {
+		data.PrevURL = riverURL(data.FeedID, page-1)
+	}
+	for _, post := range posts {
+		data.Posts = append(data.Posts, webPost{
+			ID:        post.ID,
+			FeedID:    post.FeedID,
+			FeedName:  post.FeedName,
+			Title:     postTitle(post.Title),
+			URL:       post.Url,
+			Published: formatPublished(post.PublishedAt),
+			Read:      post.Read,
+			Summary:   truncate(sanitize.Text(post.Description), webSummaryLen),
+		})
+	}
+	app.render(w, http.StatusOK, "river", data)
+}
+
+// handlePost shows a single post and marks it read.
+func (app *webApp) handlePost(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.renderError(w, http.StatusNotFound, "no such post")
+		return
+	}
+	post, err := app.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{ID: id, UserID: app.usr.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		app.renderError(w, http.StatusNotFound, "no such post")
+		return
+	}
+	if err != nil {
+		app.renderError(w, http.StatusInternalServerError, "error retrieving post")
+		return
+	}
+	if !post.Read {
+		if err := app.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+			UserID: app.usr.ID,
+			PostID: post.ID,
+			ReadAt: time.Now(),
+		}); err != nil {
+			app.renderError(w, http.StatusInternalServerError, "error marking post read")
+			return
+		}
+		post.Read = true
+	}
+
+	data, err := app.newPage(r.Context(), postTitle(post.Title))
+	if err != nil {
+		app.renderError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Relative links and images in the post are relative to the post, not to gator
+	base, err := url.Parse(post.Url)
+	if err != nil {
+		base = nil
+	}
+	data.FeedID = post.FeedID.String()
+	data.BackURL = "/"
+	if referer, err := url.Parse(r.Referer()); err == nil && referer.Host == r.Host && referer.Path == "/" {
+		data.BackURL = referer.RequestURI()
+	}
+	data.Post = &webPost{
+		ID:         post.ID,
+		FeedID:     post.FeedID,
+		FeedName:   post.FeedName,
+		Title:      postTitle(post.Title),
+		URL:        post.Url,
+		Author:     post.Author,
+		Categories: post.Categories,
+		Published:  formatPublished(post.PublishedAt),
+		Read:       post.Read,
+		Content:    template.HTML(sanitize.HTML(post.Description, base)),
+	}
+	app.render(w, http.StatusOK, "post", data)
+}
+
+// handleMarkRead answers fetch() calls from app.js with a 204 and plain form posts with a redirect.
+func (app *webApp) handleMarkRead(read bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			http.Error(w, "cross origin request refused", http.StatusForbidden)
+			return
+		}
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "no such post", http.StatusNotFound)
+			return
+		}
+		// Only posts from followed feeds can be marked
+		if _, err := app.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{ID: id, UserID: app.usr.ID}); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "no such post", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "error retrieving post", http.StatusInternalServerError)
+			return
+		}
+
+		if read {
+			err = app.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{UserID: app.usr.ID, PostID: id, ReadAt: time.Now()})
+		} else {
+			err = app.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{UserID: app.usr.ID, PostID: id})
+		}
+		if err != nil {
+			http.Error(w, "error updating read state", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Header.Get("X-Requested-With") == "fetch" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/posts/"+id.String(), http.StatusSeeOther)
+	}
+}
+
+func (app *webApp) newPage(ctx context.Context, title string) (webPage, error) {
+	follows, err := app.s.db.GetFeedFollowsForUser(ctx, app.usr.ID)
+	if err != nil {
+		return webPage{}, errors.New("error retrieving follows")
+	}
+	return webPage{Title: title, User: app.usr.Name, Follows: follows}, nil
+}
+
+// render executes into a buffer first so a template error doesn't leave half a page behind.
+func (app *webApp) render(w http.ResponseWriter, code int, name string, data webPage) {
+	var buf bytes.Buffer
+	if err := app.templates[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		// TODO:  LOG ERROR
+		fmt.Printf("error rendering %v: %v\n", name, err)
+		http.Error(w, "error rendering page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+func (app *webApp) renderError(w http.ResponseWriter, code int, msg string) {
+	app.render(w, code, "error", webPage{
+		Title:   http.StatusText(code),
+		User:    app.usr.Name,
+		Message: msg,
+	})
+}
+
+// sameOrigin refuses form posts from other sites.  Browsers send Origin on every cross-site POST.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func riverURL(feedID string, page int) string {
+	q := url.Values{}
+	if feedID != "" {
+		q.Set("feed", feedID)
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return "/"
+	}
+	return "/?" + q.Encode()
+}
+
+func postTitle(title string) string {
+	if title == "" {
+		return "(untitled)"
+	}
+	return title
+}
+
+func formatPublished(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Local().Format("Jan 2, 2006 15:04")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "…"
+}
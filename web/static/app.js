@@ -0,0 +1,127 @@
+// Keyboard navigation and mark-as-read for the gator reader.
+(function () {
+  "use strict";
+
+  function posts() {
+    return Array.from(document.querySelectorAll(".river .post"));
+  }
+
+  function selected() {
+    return document.querySelector(".river .post.selected");
+  }
+
+  function select(el) {
+    const current = selected();
+    if (current) {
+      current.classList.remove("selected");
+    }
+    if (el) {
+      el.classList.add("selected");
+      el.scrollIntoView({ block: "nearest" });
+    }
+  }
+
+  function move(step) {
+    const list = posts();
+    if (list.length === 0) {
+      return;
+    }
+    const index = list.indexOf(selected());
+    if (index === -1) {
+      select(list[step > 0 ? 0 : list.length - 1]);
+      return;
+    }
+    select(list[Math.min(Math.max(index + step, 0), list.length - 1)]);
+  }
+
+  // The post being acted on: the selected one in the river or the one being viewed.
+  function target() {
+    return selected() || document.querySelector(".post-view");
+  }
+
+  function setRead(el, read) {
+    return fetch("/posts/" + el.dataset.id + "/" + (read ? "read" : "unread"), {
+      method: "POST",
+      headers: { "X-Requested-With": "fetch" },
+    }).then(function (res) {
+      if (!res.ok) {
+        throw new Error(res.statusText);
+      }
+      el.classList.toggle("read", read);
+      const button = el.querySelector(".toggle-read button");
+      if (button) {
+        button.textContent = read ? "Mark unread" : "Mark read";
+        button.form.action = "/posts/" + el.dataset.id + "/" + (read ? "unread" : "read");
+      }
+    });
+  }
+
+  function follow(rel) {
+    const link = document.querySelector('a[rel="' + rel + '"]');
+    if (link) {
+      window.location = link.href;
+    }
+  }
+
+  document.addEventListener("keydown", function (e) {
+    if (e.ctrlKey || e.metaKey || e.altKey) {
+      return;
+    }
+    if (e.target.closest("input, textarea, select, [contenteditable]")) {
+      return;
+    }
+    const el = target();
+    switch (e.key) {
+      case "j":
+        move(1);
+        break;
+      case "k":
+        move(-1);
+        break;
+      case "o":
+      case "Enter":
+        if (selected()) {
+          window.location = "/posts/" + selected().dataset.id;
+        }
+        break;
+      case "v":
+        if (el && el.dataset.url) {
+          window.open(el.dataset.url, "_blank", "noopener");
+        }
+        break;
+      case "m":
+        if (el) {
+          setRead(el, !el.classList.contains("read")).catch(function (err) {
+            console.error("gator: marking post failed", err);
+          });
+        }
+        break;
+      case "n":
+        follow("next");
+        break;
+      case "p":
+        follow("prev");
+        break;
+      case "u":
+      case "Escape":
+        follow("up");
+        break;
+      default:
+        return;
+    }
+    e.preventDefault();
+  });
+
+  // Toggle read state in place instead of reloading the page.
+  document.addEventListener("submit", function (e) {
+    const form = e.target.closest(".toggle-read");
+    const el = form && form.closest(".post-view");
+    if (!el) {
+      return;
+    }
+    e.preventDefault();
+    setRead(el, !el.classList.contains("read")).catch(function () {
+      form.submit();
+    });
+  });
+})();
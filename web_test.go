@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/striderjg/gator/internal/database"
+)
+
+func TestWebApp(t *testing.T) {
+	env := newTestEnv(t)
+	blog := env.feed("Blog", "https://example.com/rss")
+	news := env.feed("News", "https://example.com/news")
+	post := env.post(blog, "Generics in Go", `<p>Type parameters <script>alert(1)</script><a href="/more">more</a></p>`, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+	env.post(news, "Headline", "", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	app, err := newWebApp(env.s, env.usr)
+	if err != nil {
+		t.Fatalf("newWebApp: %v", err)
+	}
+	srv := httptest.NewServer(app.routes())
+	t.Cleanup(srv.Close)
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %v: %v", path, err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+	read := func() bool {
+		got, _ := env.s.db.GetPostForUser(env.ctx, database.GetPostForUserParams{ID: post.ID, UserID: env.usr.ID})
+		return got.Read
+	}
+
+	code, body := get("/")
+	if code != http.StatusOK || !strings.Contains(body, "Generics in Go") || !strings.Contains(body, "Headline") {
+		t.Errorf("river = %v %q", code, body)
+	}
+	code, body = get("/?feed=" + news.ID.String())
+	if code != http.StatusOK || strings.Contains(body, "Generics in Go") || !strings.Contains(body, "Headline") {
+		t.Errorf("river for News = %v %q", code, body)
+	}
+	if code, _ := get("/?feed=42"); code != http.StatusBadRequest {
+		t.Errorf("river with a bad feed id = %v", code)
+	}
+	if code, body := get("/static/style.css"); code != http.StatusOK || body == "" {
+		t.Errorf("style.css = %v", code)
+	}
+
+	code, body = get("/posts/" + post.ID.String())
+	if code != http.StatusOK || !strings.Contains(body, "Type parameters") || strings.Contains(body, "<script>") ||
+		!strings.Contains(body, `href="https://example.com/more"`) {
+		t.Errorf("post = %v %q", code, body)
+	}
+	if !read() {
+		t.Error("post isn't marked read after viewing it")
+	}
+	if code, _ := get("/posts/" + uuid.NewString()); code != http.StatusNotFound {
+		t.Errorf("missing post = %v", code)
+	}
+	if code, _ := get("/posts/42"); code != http.StatusNotFound {
+		t.Errorf("post with a bad id = %v", code)
+	}
+
+	postForm := func(path string, header map[string]string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST %v: %v", path, err)
+		}
+		res.Body.Close()
+		return res
+	}
+	res := postForm("/posts/"+post.ID.String()+"/unread", map[string]string{"X-Requested-With": "fetch"})
+	if res.StatusCode != http.StatusNoContent || read() {
+		t.Errorf("mark unread = %v, read %v", res.StatusCode, read())
+	}
+	res = postForm("/posts/"+post.ID.String()+"/read", map[string]string{"Origin": srv.URL})
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/posts/"+post.ID.String() || !read() {
+		t.Errorf("mark read = %v %v, read %v", res.StatusCode, res.Header.Get("Location"), read())
+	}
+	if res := postForm("/posts/"+post.ID.String()+"/unread", map[string]string{"Origin": "https://evil.example.com"}); res.StatusCode != http.StatusForbidden || !read() {
+		t.Errorf("cross origin mark unread = %v", res.StatusCode)
+	}
+	if res := postForm("/posts/"+uuid.NewString()+"/read", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("mark missing post read = %v", res.StatusCode)
+	}
+}
+
+func TestWeb(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(env.ctx, 100*time.Millisecond)
+	defer cancel()
+	out, err := env.runContext(ctx, middlewareLoggedIn(handlerWeb), "--addr", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("web: %v", err)
+	}
+	if !strings.Contains(out, "Reading as alice at http://127.0.0.1:0/") || !strings.Contains(out, "server stopped") {
+		t.Errorf("web printed %q", out)
+	}
+	env.runErr(middlewareLoggedIn(handlerWeb), "flag provided but not defined", "--port", "8081")
+}